@@ -9,7 +9,6 @@ import (
     "strings"
     "google.golang.org/genai"
 
-    "github.com/fmount/ocstack/pkg/ocstack"
     "github.com/fmount/ocstack/tools"
 )
 
@@ -35,8 +34,8 @@ func (c *GeminiProvider) GenerateChat(
 
 	// Convert tools to Gemini function declarations (but not during collective processing)
 	var tools []*genai.Tool
-	if len(s.Tools) > 0 && !s.ProcessingCollective {
-		funcDeclarations, err := c.ConvertToGeminiFunctions(s.Tools)
+	if t := s.GetTools(); len(t) > 0 && !s.ProcessingCollective {
+		funcDeclarations, err := c.ConvertToGeminiFunctions(t)
 		if err == nil {
 			tools = []*genai.Tool{{FunctionDeclarations: funcDeclarations}}
 		}
@@ -64,9 +63,13 @@ func (c *GeminiProvider) GenerateChat(
 		}
 	}
 
-	// Add current user input
+	// Add current user input, along with the binary output of the tools
+	parts := []*genai.Part{genai.NewPartFromText(input)}
+	for _, m := range s.TakeAttachments() {
+		parts = append(parts, genai.NewPartFromBytes(m.Data, m.MimeType))
+	}
 	userContent := &genai.Content{
-		Parts: []*genai.Part{genai.NewPartFromText(input)},
+		Parts: parts,
 		Role: "user",
 	}
 	contents = append(contents, userContent)
@@ -75,6 +78,9 @@ func (c *GeminiProvider) GenerateChat(
 	config := &genai.GenerateContentConfig{
 		Tools: tools,
 	}
+	if s.MaxTokens > 0 {
+		config.MaxOutputTokens = int32(s.MaxTokens)
+	}
 	
 	// Enable function calling if tools are available
 	if len(tools) > 0 {
@@ -233,11 +239,9 @@ func (c *GeminiProvider) executeCollectiveFunctionCalls(ctx context.Context, s *
 	}
 	
 	var toolResults []*tools.FunctionCall
-	ns := s.GetConfig()[ocstack.NAMESPACE]
 	
-	// Execute all function calls and collect results
+	// Convert all function calls to tools.FunctionCall
 	for _, funcCall := range functionCalls {
-		// Convert function call to tools.FunctionCall
 		argsBytes, err := json.Marshal(funcCall.Args)
 		if err != nil {
 			continue
@@ -247,37 +251,17 @@ func (c *GeminiProvider) executeCollectiveFunctionCalls(ctx context.Context, s *
 		if err != nil {
 			continue
 		}
-		
-		var toolResult string
-		
-		// Only use MCP tools - no local tool fallback
-		if mcpRegistry := s.GetMCPRegistry(); mcpRegistry != nil && mcpRegistry.IsToolFromMCP(f.Name) {
-			// ALWAYS override namespace parameter with ocstack's configured
-			// namespace
-			if f.Arguments == nil {
-				f.Arguments = make(map[string]any)
-			}
-			f.Arguments["namespace"] = ns
-			// Execute MCP tool
-			toolResult = mcpRegistry.ExecuteMCPTool(f)
-			f.Result = toolResult
-		} else if mcpRegistry == nil {
-			toolResult = "MCP not connected. Use '/mcp connect' to enable tools."
-			f.Result = toolResult
-		} else {
-			// Tool not available in MCP
-			toolResult = fmt.Sprintf("Tool '%s' not available in MCP. Available tools can be seen with '/mcp tools'", f.Name)
-			f.Result = toolResult
-		}
-		
-		if s.Debug {
-			fmt.Printf("[DEBUG] |-->> %s\n", f.Name)
-			fmt.Printf("[DEBUG] | -->> out: %s\n", f.Result)
-		}
-		
-		// Add to collection for collective analysis
 		toolResults = append(toolResults, f)
 	}
+
+	// Execute them through MCP, only MCP tools are available
+	s.executeToolCalls(toolResults)
+
+	// Gemini is multimodal: images returned by the tools are sent along
+	// with the collective prompt
+	for _, f := range toolResults {
+		s.AddAttachments(f.Media...)
+	}
 	
 	// Process all tool results collectively using the template (like Ollama does)
 	if len(toolResults) > 0 {
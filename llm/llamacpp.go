@@ -11,7 +11,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/fmount/ocstack/pkg/ocstack"
 	"github.com/fmount/ocstack/tools"
 )
 
@@ -41,7 +40,8 @@ type LLamaPayload struct {
 	Messages []LLamaMessage `json:"messages"`
 	Stream   bool           `json:"stream"`
 	//History History
-	Tools []tools.Tool `json:"tools"`
+	Tools     []tools.Tool `json:"tools"`
+	MaxTokens int          `json:"max_tokens,omitempty"`
 }
 
 // ToLLamaCppTools -
@@ -163,7 +163,7 @@ func (c *LLamaCppProvider) GenerateChat(
 	var er error
 	var t []tools.Tool = []tools.Tool{}
 
-	t, er = ToLLamaCppTools(s.Tools)
+	t, er = ToLLamaCppTools(s.GetTools())
 	if er != nil {
 		return er
 	}
@@ -184,10 +184,11 @@ func (c *LLamaCppProvider) GenerateChat(
 	})
 
 	l := LLamaPayload{
-		Model:    s.Model,
-		Messages: msgs,
-		Stream:   false,
-		Tools:    t,
+		Model:     s.Model,
+		Messages:  msgs,
+		Stream:    false,
+		Tools:     t,
+		MaxTokens: s.MaxTokens,
 	}
 
 	var err error
@@ -230,7 +231,6 @@ func (c *LLamaCppProvider) GenerateChat(
 
 			// Collect all tool results before processing them collectively
 			var toolResults []*tools.FunctionCall
-			ns := s.GetConfig()[ocstack.NAMESPACE]
 
 			for _, toolCall := range completion.Choices[0].Message.ToolCalls {
 				// Build function Call
@@ -243,34 +243,13 @@ func (c *LLamaCppProvider) GenerateChat(
 					return fmt.Errorf("%v", err)
 				}
 
-				var toolResult string
-
-				// MCP tools take priority - check MCP first
-				if mcpRegistry := s.GetMCPRegistry(); mcpRegistry != nil && mcpRegistry.IsToolFromMCP(f.Name) {
-					// ALWAYS override namespace parameter with ocstack's configured namespace
-					// This ensures ocstack's namespace setting takes precedence over LLM-provided values
-					if f.Arguments == nil {
-						f.Arguments = make(map[string]interface{})
-					}
-					f.Arguments["namespace"] = ns
-					// Execute MCP tool (preferred)
-					toolResult = mcpRegistry.ExecuteMCPTool(f)
-					f.Result = toolResult
-				} else {
-					// Tool not found in MCP
-					toolResult = fmt.Sprintf("Tool '%s' not found in MCP", f.Name)
-					f.Result = toolResult
-				}
-
-				if s.Debug {
-					fmt.Printf("[DEBUG] |-->> %s\n", f.Name)
-					fmt.Printf("[DEBUG] | -->> out: %s\n", f.Result)
-				}
-
 				// Add to collection instead of processing immediately
 				toolResults = append(toolResults, f)
 			}
 
+			// MCP tools only, read-only tools run in parallel
+			s.executeToolCalls(toolResults)
+
 			// Process all tool results collectively for agentic reasoning
 			if len(toolResults) > 0 {
 				collectivePrompt := tools.RenderCollectiveExec(toolResults)
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"github.com/fmount/ocstack/pkg/ocstack"
 	tools "github.com/fmount/ocstack/tools"
 	"github.com/ollama/ollama/api"
 )
@@ -99,7 +98,7 @@ func (c *OllamaProvider) GenerateChat(
 	})
 
 	// Build ollama tools struct
-	t, erro := s.ToOllamaTools(s.Tools)
+	t, erro := s.ToOllamaTools(s.GetTools())
 	if erro != nil {
 		return fmt.Errorf("Can't get tools")
 	}
@@ -110,6 +109,9 @@ func (c *OllamaProvider) GenerateChat(
 		Stream:   new(bool),
 		Tools:    t,
 	}
+	if s.MaxTokens > 0 {
+		req.Options = map[string]any{"num_predict": s.MaxTokens}
+	}
 
 	var lastLLMResponse string
 
@@ -133,7 +135,6 @@ func (c *OllamaProvider) GenerateChat(
 
 		// Collect all tool results before processing them collectively
 		var toolResults []*tools.FunctionCall
-		ns := s.GetConfig()[ocstack.NAMESPACE]
 
 		for _, tool := range resp.Message.ToolCalls {
 			// Build function Call
@@ -146,37 +147,13 @@ func (c *OllamaProvider) GenerateChat(
 				return fmt.Errorf("%v", err)
 			}
 
-			var result string
-
-			// MCP tools take priority - check MCP first
-			if mcpRegistry := s.GetMCPRegistry(); mcpRegistry != nil && mcpRegistry.IsToolFromMCP(f.Name) {
-				// ALWAYS override namespace parameter with ocstack's configured namespace
-				// This ensures ocstack's namespace setting takes precedence over LLM-provided values
-				if f.Arguments == nil {
-					f.Arguments = make(map[string]interface{})
-				}
-				f.Arguments["namespace"] = ns
-				// Execute MCP tool (preferred)
-				result = mcpRegistry.ExecuteMCPTool(f)
-				f.Result = result
-			} else if mcpRegistry == nil {
-				result = fmt.Sprintf("MCP not connected. Use '/mcp connect' to enable tools.")
-				f.Result = result
-			} else {
-				// Tool not available in MCP
-				result = fmt.Sprintf("Tool '%s' not available in MCP. Available tools can be seen with '/mcp tools'", f.Name)
-				f.Result = result
-			}
-
-			if s.Debug {
-				fmt.Printf("[DEBUG] |-->> %s\n", f.Name)
-				fmt.Printf("[DEBUG] | -->> out: %s\n", f.Result)
-			}
-
 			// Add to collection instead of processing immediately
 			toolResults = append(toolResults, f)
 		}
 
+		// MCP tools only, read-only tools run in parallel
+		s.executeToolCalls(toolResults)
+
 		// Process all tool results collectively for agentic reasoning
 		if len(toolResults) > 0 {
 			collectivePrompt := tools.RenderCollectiveExec(toolResults)
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+
+	"github.com/fmount/ocstack/tools"
 )
 
 // MCPRegistryInterface defines the interface for MCP tool registry
@@ -11,6 +14,9 @@ type MCPRegistryInterface interface {
 	IsToolFromMCP(string) bool
 	ExecuteMCPTool(interface{}) string
 	GetAllTools() []byte
+	// Tool annotations, as declared by the MCP server
+	IsToolReadOnly(string) bool
+	IsToolDestructive(string) bool
 }
 
 const (
@@ -78,6 +84,14 @@ const (
 	StateExecuting            SessionState = "executing"
 )
 
+// Types of PendingAction
+const (
+	// feeds the recommendation of the LLM back to it
+	ActionExecuteRecommendation = "execute_recommendation"
+	// runs a destructive tool requested by the LLM
+	ActionExecuteTool = "execute_tool"
+)
+
 // PendingAction represents an action waiting for user confirmation
 type PendingAction struct {
 	Type        string                 `json:"type"`
@@ -96,6 +110,17 @@ type Session struct {
 	State                SessionState
 	PendingAction        *PendingAction
 	ProcessingCollective bool
+	// binary tool output sent along with the next message to multimodal
+	// providers
+	attachments []tools.Media
+	// results of the read-only tools
+	toolCache toolCache
+	// guards Tools, which MCP notifications replace from the connection
+	// goroutine: use GetTools and SetTools
+	toolsMu sync.RWMutex
+	// MaxTokens limits the length of the responses, the provider default
+	// applies when zero
+	MaxTokens int
 }
 
 type Message struct {
@@ -175,6 +200,20 @@ func NewSession(model string, tmpl string, h History, t []byte, d bool, c map[st
 	}, nil
 }
 
+// GetTools returns the definitions of the tools available to the LLM
+func (s *Session) GetTools() []byte {
+	s.toolsMu.RLock()
+	defer s.toolsMu.RUnlock()
+	return s.Tools
+}
+
+// SetTools replaces the definitions of the tools available to the LLM
+func (s *Session) SetTools(t []byte) {
+	s.toolsMu.Lock()
+	defer s.toolsMu.Unlock()
+	s.Tools = t
+}
+
 // SetMCPRegistry sets the MCP registry for the session
 func (s *Session) SetMCPRegistry(registry interface{}) {
 	s.mcpRegistry = registry
@@ -188,6 +227,18 @@ func (s *Session) GetMCPRegistry() MCPRegistryInterface {
 	return nil
 }
 
+// AddAttachments - queues binary content for the next LLM request
+func (s *Session) AddAttachments(m ...tools.Media) {
+	s.attachments = append(s.attachments, m...)
+}
+
+// TakeAttachments - returns and clears the queued binary content
+func (s *Session) TakeAttachments() []tools.Media {
+	m := s.attachments
+	s.attachments = nil
+	return m
+}
+
 // SaveSession -
 func (s *Session) SaveSession() error {
 	return nil
@@ -219,7 +270,7 @@ func (s *Session) DetectRecommendedAction(response string) *PendingAction {
 	}
 
 	return &PendingAction{
-		Type:        "execute_recommendation",
+		Type:        ActionExecuteRecommendation,
 		Description: recommendation,
 		Parameters: map[string]interface{}{
 			"recommendation": recommendation,
@@ -288,39 +339,61 @@ func (s *Session) HandleConfirmation(input string, client Client, ctx context.Co
 		return
 	}
 
+	action := s.PendingAction
 	input = strings.ToLower(strings.TrimSpace(input))
-	if input == "y" || input == "yes" {
-		fmt.Printf("Executing: %s\n", s.PendingAction.Description)
-
-		// Execute the pending action
-		switch s.PendingAction.Type {
-		case "execute_recommendation":
-			s.executeRecommendation(client, ctx)
-		default:
-			fmt.Printf("Unknown action type: %s\n", s.PendingAction.Type)
-		}
-
-	} else if input == "n" || input == "no" {
-		fmt.Println("Action cancelled")
-	} else {
+	if input != "y" && input != "yes" && input != "n" && input != "no" {
 		fmt.Println("Please respond with 'y' or 'n'")
 		return // Don't reset state, wait for valid input
 	}
 
-	// Reset session state
+	// Reset session state first, the action may request a new confirmation
 	s.State = StateNormal
 	s.PendingAction = nil
-}
 
-// executeRecommendation feeds the recommendation back to LLM for execution
-func (s *Session) executeRecommendation(client Client, ctx context.Context) {
-	if s.PendingAction == nil {
-		fmt.Println("No pending action to execute")
+	if input == "n" || input == "no" {
+		fmt.Println("Action cancelled")
+		if action.Type == ActionExecuteTool {
+			s.UpdateHistory(Message{
+				Role: "user",
+				Text: fmt.Sprintf("I declined the execution of '%s'", action.Parameters["tool"]),
+			})
+		}
 		return
 	}
 
+	fmt.Printf("Executing: %s\n", action.Description)
+	switch action.Type {
+	case ActionExecuteRecommendation:
+		s.executeRecommendation(action, client, ctx)
+	case ActionExecuteTool:
+		s.executeTool(action, client, ctx)
+	default:
+		fmt.Printf("Unknown action type: %s\n", action.Type)
+	}
+}
+
+// executeTool runs the destructive tool approved by the user, and feeds its
+// result back to the LLM
+func (s *Session) executeTool(action *PendingAction, client Client, ctx context.Context) {
+	name, _ := action.Parameters["tool"].(string)
+	args, _ := action.Parameters["arguments"].(map[string]any)
+	f := &tools.FunctionCall{
+		Name:      name,
+		Arguments: args,
+	}
+	s.CallTool(f)
+
+	s.ProcessingCollective = true
+	defer func() { s.ProcessingCollective = false }()
+	if err := client.GenerateChat(ctx, tools.RenderCollectiveExec([]*tools.FunctionCall{f}), s); err != nil {
+		fmt.Printf("Error processing the result of %s: %v\n", name, err)
+	}
+}
+
+// executeRecommendation feeds the recommendation back to LLM for execution
+func (s *Session) executeRecommendation(action *PendingAction, client Client, ctx context.Context) {
 	// Extract the original recommendation
-	recommendation, exists := s.PendingAction.Parameters["recommendation"].(string)
+	recommendation, exists := action.Parameters["recommendation"].(string)
 	if !exists {
 		fmt.Println("No recommendation found in pending action")
 		return
@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Sample runs a one-off completion outside of the user conversation. It is
+// used to fulfill the sampling requests coming from MCP servers: the
+// messages are processed in a throwaway session without tools, so the
+// server can't trigger tool calls through the LLM.
+func Sample(
+	ctx context.Context,
+	client Client,
+	model string,
+	systemPrompt string,
+	messages []Message,
+	maxTokens int,
+) (string, error) {
+	if client == nil {
+		return "", fmt.Errorf("no LLM client available")
+	}
+	if len(messages) == 0 {
+		return "", fmt.Errorf("no messages to process")
+	}
+
+	s, err := NewSession(model, systemPrompt, History{}, []byte("[]"), false, map[string]string{})
+	if err != nil {
+		return "", err
+	}
+	s.MaxTokens = maxTokens
+	// Always set the context, so GenerateChat does not add it after we
+	// record the history length
+	s.UpdateContext()
+
+	// The last message is the input, everything else is the history
+	last := messages[len(messages)-1]
+	for _, m := range messages[:len(messages)-1] {
+		s.UpdateHistory(m)
+	}
+	input, ok := last.Text.(string)
+	if !ok {
+		return "", fmt.Errorf("unsupported message content")
+	}
+	historyLen := len(s.GetHistory().Text)
+	if err := client.GenerateChat(ctx, input, s); err != nil {
+		return "", err
+	}
+
+	h := s.GetHistory().Text
+	if len(h) <= historyLen {
+		return "", fmt.Errorf("the LLM did not return any response")
+	}
+	response, _ := h[len(h)-1].Text.(string)
+	return response, nil
+}
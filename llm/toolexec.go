@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fmount/ocstack/pkg/ocstack"
+	"github.com/fmount/ocstack/tools"
+)
+
+// toolCacheTTL is how long the result of a read-only tool is reused for the
+// same arguments
+const toolCacheTTL = 30 * time.Second
+
+type toolCacheEntry struct {
+	result  string
+	media   []tools.Media
+	expires time.Time
+}
+
+// toolCache holds the results of read-only tools
+type toolCache struct {
+	mu      sync.Mutex
+	entries map[string]toolCacheEntry
+}
+
+func (c *toolCache) get(key string) (toolCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, exists := c.entries[key]
+	if !exists || time.Now().After(entry.expires) {
+		return toolCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *toolCache) set(key string, f *tools.FunctionCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]toolCacheEntry)
+	}
+	c.entries[key] = toolCacheEntry{
+		result:  f.Result,
+		media:   f.Media,
+		expires: time.Now().Add(toolCacheTTL),
+	}
+}
+
+// clear drops every entry: once a tool that is not read-only runs, the
+// cached results may be stale
+func (c *toolCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// executeToolCalls runs the tool calls requested by the LLM through the MCP
+// registry and stores the output in each FunctionCall. Consecutive read-only
+// tools run in parallel and their results are cached, while destructive
+// tools wait for the user confirmation, see HandleConfirmation. Calls keep
+// the order requested by the LLM with respect to tools that are not
+// read-only, so the calls following a destructive tool are not run either
+func (s *Session) executeToolCalls(calls []*tools.FunctionCall) {
+	registry := s.GetMCPRegistry()
+	ns := s.GetConfig()[ocstack.NAMESPACE]
+
+	var batch []*tools.FunctionCall
+	flush := func() {
+		s.runReadOnlyTools(registry, batch)
+		batch = nil
+	}
+
+	var awaiting *tools.FunctionCall
+	for _, f := range calls {
+		if registry == nil {
+			f.Result = "MCP not connected. Use '/mcp connect' to enable tools."
+			continue
+		}
+		if !registry.IsToolFromMCP(f.Name) {
+			f.Result = fmt.Sprintf("Tool '%s' not available in MCP. Available tools can be seen with '/mcp tools'", f.Name)
+			continue
+		}
+		if awaiting != nil {
+			f.Result = fmt.Sprintf("Not run, '%s' is waiting for the user confirmation", awaiting.Name)
+			continue
+		}
+
+		// ALWAYS override namespace parameter with ocstack's configured
+		// namespace, it takes precedence over LLM-provided values
+		if f.Arguments == nil {
+			f.Arguments = make(map[string]any)
+		}
+		f.Arguments["namespace"] = ns
+
+		if registry.IsToolReadOnly(f.Name) {
+			batch = append(batch, f)
+			continue
+		}
+		flush()
+		if registry.IsToolDestructive(f.Name) {
+			s.requestToolConfirmation(f)
+			awaiting = f
+			continue
+		}
+		s.CallTool(f)
+	}
+	flush()
+
+	if s.Debug {
+		for _, f := range calls {
+			fmt.Printf("[DEBUG] |-->> %s\n", f.Name)
+			fmt.Printf("[DEBUG] | -->> out: %s\n", f.Result)
+		}
+	}
+}
+
+// requestToolConfirmation makes the destructive tool f the pending action:
+// it runs once the user confirms it
+func (s *Session) requestToolConfirmation(f *tools.FunctionCall) {
+	args, _ := json.Marshal(f.Arguments)
+	s.State = StateAwaitingConfirmation
+	s.PendingAction = &PendingAction{
+		Type:        ActionExecuteTool,
+		Description: fmt.Sprintf("run the destructive tool '%s' with %s", f.Name, args),
+		Parameters: map[string]interface{}{
+			"tool":      f.Name,
+			"arguments": f.Arguments,
+		},
+	}
+	f.Result = fmt.Sprintf("'%s' is a destructive tool, it was not run: the user is asked to confirm it", f.Name)
+	fmt.Printf("\nT :> '%s' is a destructive tool, run it with %s? (y/n): ", f.Name, args)
+}
+
+// CallTool runs f through the MCP registry. The cached results are dropped
+// unless the tool is read-only, as they may be stale
+func (s *Session) CallTool(f *tools.FunctionCall) {
+	registry := s.GetMCPRegistry()
+	if registry == nil {
+		f.Result = "MCP not connected. Use '/mcp connect' to enable tools."
+		return
+	}
+	f.Result = registry.ExecuteMCPTool(f)
+	if !registry.IsToolReadOnly(f.Name) {
+		s.toolCache.clear()
+	}
+}
+
+// runReadOnlyTools executes read-only tools concurrently, reusing the cached
+// results when available
+func (s *Session) runReadOnlyTools(registry MCPRegistryInterface, calls []*tools.FunctionCall) {
+	var wg sync.WaitGroup
+	for _, f := range calls {
+		args, _ := json.Marshal(f.Arguments)
+		key := f.Name + string(args)
+
+		if entry, ok := s.toolCache.get(key); ok {
+			fmt.Printf("T :> %s (cached)\n", f.Name)
+			f.Result = entry.result
+			f.Media = entry.media
+			continue
+		}
+
+		wg.Add(1)
+		go func(f *tools.FunctionCall, key string) {
+			defer wg.Done()
+			f.Result = registry.ExecuteMCPTool(f)
+			s.toolCache.set(key, f)
+		}(f, key)
+	}
+	wg.Wait()
+}
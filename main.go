@@ -1,16 +1,27 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/fmount/ocstack/llm"
 	"github.com/fmount/ocstack/mcp"
+	"github.com/fmount/ocstack/mcp/gateway"
 	"github.com/fmount/ocstack/pkg/ocstack"
 	t "github.com/fmount/ocstack/template"
 	tools "github.com/fmount/ocstack/tools"
@@ -20,16 +31,34 @@ const (
 	DEBUG = true // switch to true to print additional information
 )
 
+// mcpTrace is the tracing enabled by /mcp trace
+type mcpTrace struct {
+	// type of the traced server, empty to trace any server
+	server string
+	tracer *mcp.Tracer
+	// nil when tracing to the terminal
+	file *os.File
+}
+
+var (
+	// activeTrace is applied to every connection to a matching server
+	activeTrace *mcpTrace
+	// type of the connected MCP server
+	connectedServer string
+)
+
 // handleConfirmation handles user confirmation for pending actions
 func handleConfirmation(input string, s *llm.Session, client llm.Client, ctx context.Context) {
 	s.HandleConfirmation(input, client, ctx)
 }
 
 // CliCommand -
-func CliCommand(q string, s *llm.Session) {
+func CliCommand(q string, s *llm.Session, client llm.Client, ctx context.Context) {
 	query := strings.ToLower(q)
 	tokens := strings.Split(query, " ")
 	tq := tokens[0]
+	// prompt arguments are case sensitive, keep the original input around
+	rawTokens := strings.Fields(q)
 	// tokenize and get the first item. Next items are passed as parameters to
 	// the selected case
 	switch {
@@ -64,12 +93,16 @@ func CliCommand(q string, s *llm.Session) {
 		// set or update namespace
 		s.SetConfig(ocstack.NAMESPACE, tokens[1])
 	case tq == "config":
-		// show config options
-		s.ShowConfig()
+		// show or set config options, values are case sensitive
+		if len(rawTokens) < 3 {
+			s.ShowConfig()
+			return
+		}
+		setConfig(s, tokens[1], rawTokens[2])
 	case tq == "mcp":
 		// MCP connection commands
 		if len(tokens) < 2 {
-			fmt.Println("Usage: /mcp connect <command> | /mcp disconnect | /mcp tools")
+			fmt.Println("Usage: /mcp connect <command> | /mcp disconnect | /mcp tools [name] | /mcp call <tool> [json]")
 			return
 		}
 		if s == nil {
@@ -85,28 +118,216 @@ func CliCommand(q string, s *llm.Session) {
 				return
 			}
 			var url string
-			if len(tokens) > 3 {
-				url = tokens[3]
+			// URLs are case sensitive
+			if len(rawTokens) > 3 {
+				url = rawTokens[3]
 			}
-			connectMCP(s, tokens[2], url)
+			connectMCP(s, client, tokens[2], url)
 		case "disconnect":
 			disconnectMCP(s)
 		case "tools":
+			if len(tokens) > 2 && tokens[2] == "refresh" {
+				refreshMCPTools(s)
+				return
+			}
+			// tool names are case sensitive
+			if len(rawTokens) > 2 {
+				showMCPTool(s, rawTokens[2])
+				return
+			}
 			listMCPTools(s)
+		case "call":
+			// drop "mcp call", the JSON arguments may contain spaces
+			args := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(q[len(rawTokens[0]):]), rawTokens[1]))
+			callMCPTool(s, args)
+		case "trace":
+			// trace file paths are case sensitive
+			traceMCP(s, rawTokens[2:])
+		case "loglevel":
+			if len(tokens) < 3 {
+				fmt.Println("Usage: /mcp loglevel <debug|info|notice|warning|error|critical|alert|emergency>")
+				return
+			}
+			setMCPLogLevel(s, tokens[2])
 		default:
-			fmt.Println("Unknown MCP command. Use: connect, disconnect, or tools")
+			fmt.Println("Unknown MCP command. Use: connect, disconnect, tools, call, trace or loglevel")
+		}
+	case tq == "prompt":
+		if s == nil {
+			ocstack.ShowWarn("No session")
+			return
 		}
+		if len(rawTokens) < 2 {
+			listMCPPrompts(s)
+			return
+		}
+		runMCPPrompt(s, client, ctx, rawTokens[1], rawTokens[2:])
+	case tq == "update":
+		// versions are case sensitive
+		runMinorUpdate(s, ctx, rawTokens[1:])
 	case tq == "help":
+		if len(tokens) > 1 {
+			ocstack.TermHelper(tokens[1])
+			return
+		}
 		ocstack.TermHelper("")
+		if s != nil {
+			listMCPPrompts(s)
+		}
 		return
 	default:
+		// MCP prompts are registered as dynamic slash commands
+		if registry := getToolRegistry(s); registry != nil {
+			if _, ok := registry.FindPrompt(tq); ok {
+				runMCPPrompt(s, client, ctx, rawTokens[0], rawTokens[1:])
+				return
+			}
+		}
 		fmt.Println("Default!")
 		return
 	}
 }
 
+// runMinorUpdate runs the guided minor update of the session namespace,
+// asking for confirmation before each phase
+func runMinorUpdate(s *llm.Session, ctx context.Context, args []string) {
+	ns := ocstack.DEFAULT_NAMESPACE
+	if s != nil {
+		ns = s.GetConfig()[ocstack.NAMESPACE]
+	}
+	update := &tools.MinorUpdate{
+		Namespace: ns,
+		Confirm: func(phase tools.UpdatePhase, description string) bool {
+			return ocstack.Confirm(fmt.Sprintf("U :> [%s] %s. Continue?", phase, description))
+		},
+		Logf: func(format string, args ...any) {
+			fmt.Printf("U :> "+format+"\n", args...)
+		},
+	}
+	if len(args) > 0 {
+		update.TargetVersion = args[0]
+	}
+
+	if err := update.Run(ctx); err != nil {
+		if errors.Is(err, tools.ErrUpdateStopped) {
+			fmt.Println("U :> Update stopped, run /update again to resume it")
+			return
+		}
+		ocstack.ShowWarn(fmt.Sprintf("Minor update failed: %v", err))
+	}
+}
+
+// getToolRegistry returns the MCP registry associated to the session, if any
+func getToolRegistry(s *llm.Session) *mcp.MCPToolRegistry {
+	if s == nil {
+		return nil
+	}
+	if registry, ok := s.GetMCPRegistry().(*mcp.MCPToolRegistry); ok {
+		return registry
+	}
+	return nil
+}
+
+func listMCPPrompts(s *llm.Session) {
+	registry := getToolRegistry(s)
+	if registry == nil {
+		fmt.Println("No MCP connection active. No prompts available.")
+		return
+	}
+	prompts := registry.GetPrompts()
+	if len(prompts) == 0 {
+		fmt.Println("The MCP server does not expose any prompt")
+		return
+	}
+	fmt.Println("Available prompts (MCP):")
+	for _, prompt := range prompts {
+		fmt.Printf("  %s\n", prompt.Usage())
+		if prompt.Description != "" {
+			fmt.Printf("      %s\n", prompt.Description)
+		}
+	}
+}
+
+// runMCPPrompt renders an MCP prompt, injects the resulting messages in the
+// session and asks the LLM to process them
+func runMCPPrompt(s *llm.Session, client llm.Client, ctx context.Context, name string, params []string) {
+	registry := getToolRegistry(s)
+	if registry == nil {
+		fmt.Println("No MCP connection active")
+		return
+	}
+
+	args := make(map[string]string)
+	for _, param := range params {
+		k, v, found := strings.Cut(param, "=")
+		if !found || k == "" {
+			ocstack.ShowWarn(fmt.Sprintf("Malformed argument '%s', expected key=value", param))
+			return
+		}
+		args[k] = v
+	}
+
+	rendered, err := registry.RenderPrompt(ctx, name, args)
+	if err != nil {
+		ocstack.ShowWarn(fmt.Sprintf("%v", err))
+		if prompt, ok := registry.FindPrompt(name); ok {
+			fmt.Printf("Usage: %s\n", prompt.Usage())
+		}
+		return
+	}
+	if len(rendered.Messages) == 0 {
+		ocstack.ShowWarn(fmt.Sprintf("Prompt '%s' returned no messages", name))
+		return
+	}
+
+	// Make sure the agent profile comes first in the conversation
+	if len(s.GetHistory().Text) == 0 {
+		s.UpdateContext()
+	}
+
+	// The last user message is sent as input, everything else is injected
+	// in the session history
+	messages := rendered.Messages
+	input := "Please follow the instructions above."
+	if last := messages[len(messages)-1]; last.Role == "user" {
+		input = last.ContentText()
+		messages = messages[:len(messages)-1]
+	}
+	for _, m := range messages {
+		s.UpdateHistory(llm.Message{
+			Role: m.Role,
+			Text: m.ContentText(),
+		})
+	}
+
+	if client == nil {
+		ocstack.ShowWarn("No LLM client available")
+		return
+	}
+	if err := client.GenerateChat(ctx, input, s); err != nil {
+		ocstack.ShowWarn(fmt.Sprintf("%v", err))
+	}
+}
+
 // MCP helper functions
-func connectMCP(s *llm.Session, serverType string, url string) {
+
+// remoteMCPConfig builds the config of HTTP and WebSocket servers: the token
+// of mcp-serve set in the environment is only sent to local servers, the
+// OAuth flow starts as soon as another server asks for credentials
+func remoteMCPConfig(transport mcp.TransportType, serverURL string) mcp.MCPConfig {
+	config := mcp.MCPConfig{
+		Transport: transport,
+		ServerURL: serverURL,
+	}
+	u, err := url.Parse(serverURL)
+	if token := os.Getenv(ocstack.MCP_TOKEN_ENV); token != "" && err == nil && isLoopback(u.Hostname()) {
+		config.BearerToken = token
+	} else {
+		config.OAuth = &mcp.OAuthConfig{}
+	}
+	return config
+}
+func connectMCP(s *llm.Session, llmClient llm.Client, serverType string, url string) {
 	fmt.Printf("Connecting to MCP server: %s...\n", serverType)
 
 	var config mcp.MCPConfig
@@ -124,28 +345,33 @@ func connectMCP(s *llm.Session, serverType string, url string) {
 			fmt.Println("Usage: /mcp connect http <url>")
 			return
 		}
-		config = mcp.MCPConfig{
-			Transport: mcp.TransportHTTP,
-			ServerURL: url,
-		}
+		config = remoteMCPConfig(mcp.TransportHTTP, url)
 	case "websocket":
 		if url == "" {
 			fmt.Println("Error: URL required for WebSocket connection")
 			fmt.Println("Usage: /mcp connect websocket <url>")
 			return
 		}
-		config = mcp.MCPConfig{
-			Transport: mcp.TransportWebSocket,
-			ServerURL: url,
-		}
+		config = remoteMCPConfig(mcp.TransportWebSocket, url)
 	default:
 		fmt.Printf("Unknown server type: %s\n", serverType)
 		fmt.Println("Available types: filesystem, brave-search, sqlite, http, websocket")
 		return
 	}
 
+	// Expose the workspace to the server
+	if ws, _ := s.GetConfigItem(ocstack.WORKSPACE); ws != "" {
+		config.Roots = []mcp.Root{{URI: "file://" + ws, Name: ocstack.WORKSPACE}}
+	}
+
 	// Create MCP client
 	client := mcp.NewClient(config)
+	client.SetSamplingHandler(samplingHandler(s, llmClient))
+	client.SetElicitationHandler(elicitationHandler)
+	// Trace the initialization too
+	if traceMatches(serverType) {
+		client.SetTracer(activeTrace.tracer)
+	}
 
 	// Connect
 	ctx := context.Background()
@@ -161,19 +387,193 @@ func connectMCP(s *llm.Session, serverType string, url string) {
 	// Local tools disabled - only use MCP tools
 	fmt.Println("Note: Local tools disabled, only MCP tools will be available")
 
+	// Only one server is used at a time, close the previous connection
+	if previous := getToolRegistry(s); previous != nil {
+		previous.Disconnect()
+	}
+
 	// Update session with combined tools (MCP tools take priority)
-	s.Tools = registry.GetAllTools()
+	s.SetTools(registry.GetAllTools())
 	s.SetMCPRegistry(registry)
+	connectedServer = serverType
+
+	// Keep the session tools in sync when the server notifies a change
+	client.OnToolsChanged(func() {
+		s.SetTools(registry.GetAllTools())
+		fmt.Println("I :> MCP tool list changed, session tools updated")
+	})
+
+	fmt.Printf("Successfully connected to MCP server: %s (protocol %s)\n", serverType, client.ProtocolVersion())
+}
+
+// samplingHandler fulfills the MCP sampling requests through the session LLM
+// client, once the user approves them
+func samplingHandler(s *llm.Session, client llm.Client) mcp.SamplingHandler {
+	return func(ctx context.Context, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		fmt.Println("\nI :> The MCP server requests an LLM completion")
+		if req.SystemPrompt != "" {
+			fmt.Printf("   System prompt: %s\n", req.SystemPrompt)
+		}
+		var messages []llm.Message
+		for _, m := range req.Messages {
+			if m.Content.Type != "text" {
+				return nil, fmt.Errorf("unsupported sampling content: %s", m.Content.Type)
+			}
+			fmt.Printf("   [%s] %s\n", m.Role, m.Content.Text)
+			messages = append(messages, llm.Message{
+				Role: m.Role,
+				Text: m.Content.Text,
+			})
+		}
+		fmt.Printf("   Max tokens: %d\n", req.MaxTokens)
+
+		if !ocstack.Confirm("Allow the MCP server to use the LLM?") {
+			return nil, mcp.ErrUserRejected
+		}
+
+		text, err := llm.Sample(ctx, client, s.Model, req.SystemPrompt, messages, req.MaxTokens)
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.CreateMessageResult{
+			Role:       "assistant",
+			Content:    mcp.ToolResult{Type: "text", Text: text},
+			Model:      s.Model,
+			StopReason: "endTurn",
+		}, nil
+	}
+}
+
+// elicitationHandler asks the user the information requested by the MCP
+// server through a terminal form built from the requested schema. Requests
+// are declined when stdin is not a terminal
+func elicitationHandler(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+	if !ocstack.IsTerminal() {
+		fmt.Println("I :> Declined MCP elicitation request: stdin is not a terminal")
+		return &mcp.ElicitResult{Action: mcp.ElicitDecline}, nil
+	}
+
+	fmt.Println("\nI :> The MCP server requests some information")
+	fmt.Printf("   %s\n", req.Message)
+	if !ocstack.Confirm("Provide the requested information?") {
+		return &mcp.ElicitResult{Action: mcp.ElicitDecline}, nil
+	}
+
+	content, err := ocstack.ReadForm(formFields(req.RequestedSchema))
+	if err == ocstack.ErrFormCancelled {
+		return &mcp.ElicitResult{Action: mcp.ElicitCancel}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.ElicitResult{Action: mcp.ElicitAccept, Content: content}, nil
+}
+
+// formFields converts the properties of an elicitation schema to form fields,
+// required ones first
+func formFields(schema mcp.ToolSchema) []ocstack.FormField {
+	required := make(map[string]bool)
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	var fields []ocstack.FormField
+	for name, p := range schema.Properties {
+		prop, _ := p.(map[string]interface{})
+		field := ocstack.FormField{
+			Name:     name,
+			Type:     "string",
+			Required: required[name],
+			Default:  prop["default"],
+			Validate: func(value interface{}) error {
+				return mcp.ValidateSchema(prop, value)
+			},
+		}
+		if v, ok := prop["type"].(string); ok {
+			field.Type = v
+		}
+		field.Title, _ = prop["title"].(string)
+		field.Description, _ = prop["description"].(string)
+		field.Enum = toStrings(prop["enum"])
+		field.EnumNames = toStrings(prop["enumNames"])
+		fields = append(fields, field)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].Required != fields[j].Required {
+			return fields[i].Required
+		}
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+func toStrings(v interface{}) []string {
+	items, _ := v.([]interface{})
+	var out []string
+	for _, item := range items {
+		out = append(out, fmt.Sprintf("%v", item))
+	}
+	return out
+}
+
+// setConfig sets a config option. A new workspace is exposed to the
+// connected MCP server, which is notified that its roots changed
+func setConfig(s *llm.Session, key string, value string) {
+	s.SetConfig(key, value)
+	if key != ocstack.WORKSPACE {
+		return
+	}
+	registry := getToolRegistry(s)
+	if registry == nil {
+		return
+	}
+	roots := []mcp.Root{{URI: "file://" + value, Name: ocstack.WORKSPACE}}
+	if err := registry.SetRoots(context.Background(), roots); err != nil {
+		ocstack.ShowWarn(fmt.Sprintf("Failed to update the MCP roots: %v", err))
+	}
+}
+
+func refreshMCPTools(s *llm.Session) {
+	registry := getToolRegistry(s)
+	if registry == nil {
+		fmt.Println("No MCP connection active")
+		return
+	}
+	if err := registry.RefreshTools(context.Background()); err != nil {
+		ocstack.ShowWarn(fmt.Sprintf("Failed to refresh MCP tools: %v", err))
+		return
+	}
+	s.SetTools(registry.GetAllTools())
+	fmt.Println("MCP tools refreshed")
+}
 
-	fmt.Printf("Successfully connected to MCP server: %s\n", serverType)
+func setMCPLogLevel(s *llm.Session, level string) {
+	registry := getToolRegistry(s)
+	if registry == nil {
+		fmt.Println("No MCP connection active")
+		return
+	}
+	if err := registry.SetLogLevel(context.Background(), level); err != nil {
+		ocstack.ShowWarn(fmt.Sprintf("%v", err))
+		return
+	}
+	fmt.Printf("MCP log level set to: %s\n", level)
 }
 
 func disconnectMCP(s *llm.Session) {
 	if mcpRegistry := s.GetMCPRegistry(); mcpRegistry != nil {
 		fmt.Println("Disconnecting MCP client...")
 		// No local tools fallback - no tools when MCP disconnected
-		s.Tools = []byte("[]") // No tools available
+		s.SetTools([]byte("[]")) // No tools available
+		if registry := getToolRegistry(s); registry != nil {
+			registry.SetTracer(nil)
+			if err := registry.Disconnect(); err != nil {
+				ocstack.ShowWarn(fmt.Sprintf("Failed to close the MCP connection: %v", err))
+			}
+		}
 		s.SetMCPRegistry(nil)
+		connectedServer = ""
 		fmt.Println("Disconnected from MCP server - no tools available (local tools disabled)")
 	} else {
 		fmt.Println("No MCP connection active")
@@ -199,6 +599,18 @@ func listMCPTools(s *llm.Session) {
 					fmt.Printf("%d. %s\n", i+1, name)
 					fmt.Printf("   Description: %s\n", description)
 
+					// Annotations declared by the server
+					if registry := getToolRegistry(s); registry != nil {
+						if mcpTool, ok := registry.GetTool(fmt.Sprintf("%v", name)); ok && mcpTool.Annotations != nil {
+							if mcpTool.Annotations.Title != "" {
+								fmt.Printf("   Title: %s\n", mcpTool.Annotations.Title)
+							}
+							if hints := mcpTool.Hints(); hints != "" {
+								fmt.Printf("   Hints: %s\n", hints)
+							}
+						}
+					}
+
 					if params, exists := toolFunc["parameters"].(map[string]any); exists {
 						if props, exists := params["properties"].(map[string]any); exists && len(props) > 0 {
 							fmt.Printf("   Parameters: ")
@@ -222,7 +634,524 @@ func listMCPTools(s *llm.Session) {
 	}
 }
 
+// showMCPTool prints the definition of a tool, including its full input and
+// output schemas
+func showMCPTool(s *llm.Session, name string) {
+	registry := getToolRegistry(s)
+	if registry == nil {
+		fmt.Println("No MCP connection active. No tools available (local tools disabled).")
+		return
+	}
+	tool, ok := registry.GetTool(name)
+	if !ok {
+		ocstack.ShowWarn(fmt.Sprintf("Tool '%s' not available in MCP", name))
+		return
+	}
+
+	fmt.Printf("%s\n", tool.Name)
+	if tool.Annotations != nil && tool.Annotations.Title != "" {
+		fmt.Printf("   Title: %s\n", tool.Annotations.Title)
+	}
+	if tool.Description != "" {
+		fmt.Printf("   Description: %s\n", tool.Description)
+	}
+	if hints := tool.Hints(); hints != "" {
+		fmt.Printf("   Hints: %s\n", hints)
+	}
+
+	fmt.Println("   Input:")
+	printSchema(schemaMap(tool.InputSchema), "     ")
+	if tool.OutputSchema != nil {
+		fmt.Println("   Output:")
+		printSchema(schemaMap(tool.OutputSchema), "     ")
+	}
+	fmt.Printf("   Usage: /mcp call [--context] %s {json}\n", tool.Name)
+}
+
+// schemaMap converts a tool schema to its generic JSON form
+func schemaMap(schema interface{}) map[string]interface{} {
+	var m map[string]interface{}
+	if data, err := json.Marshal(schema); err == nil {
+		json.Unmarshal(data, &m)
+	}
+	return m
+}
+
+// printSchema prints the properties of a JSON schema with their type,
+// constraints and enums. Nested objects and array items are indented
+func printSchema(schema map[string]interface{}, indent string) {
+	props, _ := schema["properties"].(map[string]interface{})
+	if len(props) == 0 {
+		fmt.Printf("%s(no parameters)\n", indent)
+		return
+	}
+	required := make(map[string]bool)
+	for _, name := range toStrings(schema["required"]) {
+		required[name] = true
+	}
+
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, _ := props[name].(map[string]interface{})
+		kind := schemaType(prop)
+		if required[name] {
+			kind += ", required"
+		}
+		fmt.Printf("%s- %s (%s)", indent, name, kind)
+		if description, _ := prop["description"].(string); description != "" {
+			fmt.Printf(": %s", description)
+		}
+		fmt.Println()
+
+		if enum := toStrings(prop["enum"]); len(enum) > 0 {
+			fmt.Printf("%s    one of: %s\n", indent, strings.Join(enum, ", "))
+		}
+		if def, exists := prop["default"]; exists {
+			fmt.Printf("%s    default: %v\n", indent, def)
+		}
+		var constraints []string
+		for _, key := range []string{"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "minLength", "maxLength", "minItems", "maxItems", "pattern", "format"} {
+			if v, exists := prop[key]; exists {
+				constraints = append(constraints, fmt.Sprintf("%s=%v", key, v))
+			}
+		}
+		if len(constraints) > 0 {
+			fmt.Printf("%s    constraints: %s\n", indent, strings.Join(constraints, ", "))
+		}
+
+		if _, nested := prop["properties"]; nested {
+			printSchema(prop, indent+"    ")
+		}
+		if items, ok := prop["items"].(map[string]interface{}); ok {
+			if _, nested := items["properties"]; nested {
+				printSchema(items, indent+"    ")
+			}
+		}
+	}
+}
+
+// schemaType describes the type of a schema property, e.g. "array of string"
+func schemaType(prop map[string]interface{}) string {
+	var kind string
+	switch t := prop["type"].(type) {
+	case string:
+		kind = t
+	case []interface{}:
+		kind = strings.Join(toStrings(t), "|")
+	default:
+		return "any"
+	}
+	if items, ok := prop["items"].(map[string]interface{}); ok && kind == "array" {
+		kind += " of " + schemaType(items)
+	}
+	return kind
+}
+
+// callMCPTool invokes a tool with the given JSON arguments, without going
+// through the LLM. With --context the result is added to the conversation,
+// so that the model can use it as trusted data
+func callMCPTool(s *llm.Session, args string) {
+	addToContext := false
+	if rest, found := strings.CutPrefix(args, "--context"); found {
+		addToContext = true
+		args = strings.TrimSpace(rest)
+	}
+	name, rawArgs, _ := strings.Cut(args, " ")
+	if name == "" {
+		fmt.Println("Usage: /mcp call [--context] <tool> [json arguments]")
+		return
+	}
+
+	registry := getToolRegistry(s)
+	if registry == nil {
+		fmt.Println("No MCP connection active. Use '/mcp connect <server-type>' first")
+		return
+	}
+	tool, ok := registry.GetTool(name)
+	if !ok {
+		ocstack.ShowWarn(fmt.Sprintf("Tool '%s' not available in MCP", name))
+		return
+	}
+
+	arguments := make(map[string]any)
+	if rawArgs = strings.TrimSpace(rawArgs); rawArgs != "" {
+		if err := json.Unmarshal([]byte(rawArgs), &arguments); err != nil {
+			ocstack.ShowWarn(fmt.Sprintf("Invalid JSON arguments: %v", err))
+			return
+		}
+	}
+	if err := tool.InputSchema.Validate(arguments); err != nil {
+		ocstack.ShowWarn(fmt.Sprintf("Invalid arguments for %s: %v", name, err))
+		fmt.Printf("Run '/mcp tools %s' to see its schema\n", name)
+		return
+	}
+
+	f := &tools.FunctionCall{
+		Name:      name,
+		Arguments: arguments,
+	}
+	s.CallTool(f)
+	fmt.Printf("T :> %s\n%s\n", name, f.Result)
+
+	if addToContext {
+		encoded, _ := json.Marshal(arguments)
+		s.UpdateHistory(llm.Message{
+			Role: "user",
+			Text: fmt.Sprintf("Result of the tool %s called with %s:\n%s", name, encoded, f.Result),
+		})
+		s.AddAttachments(f.Media...)
+		fmt.Println("I :> Tool result added to the conversation context")
+	}
+}
+
+// traceMCP handles /mcp trace on|off [server] [--file <path>]. Without
+// arguments it shows the tracing status
+func traceMCP(s *llm.Session, args []string) {
+	if len(args) == 0 {
+		if activeTrace == nil {
+			fmt.Println("MCP tracing is off")
+			return
+		}
+		target, output := "every server", "the terminal"
+		if activeTrace.server != "" {
+			target = activeTrace.server
+		}
+		if activeTrace.file != nil {
+			output = activeTrace.file.Name()
+		}
+		fmt.Printf("MCP tracing is on for %s, writing to %s\n", target, output)
+		return
+	}
+
+	var server, path string
+	for i := 1; i < len(args); i++ {
+		if args[i] == "--file" && i+1 < len(args) {
+			path = args[i+1]
+			i++
+			continue
+		}
+		server = strings.ToLower(args[i])
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "on":
+		trace := &mcpTrace{server: server}
+		var out io.Writer = os.Stdout
+		if path != "" {
+			file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+			if err != nil {
+				ocstack.ShowWarn(fmt.Sprintf("Failed to open the trace file: %v", err))
+				return
+			}
+			trace.file = file
+			out = file
+		}
+		trace.tracer = mcp.NewTracer(out)
+
+		stopMCPTrace(s)
+		activeTrace = trace
+		if registry := getToolRegistry(s); registry != nil && traceMatches(connectedServer) {
+			registry.SetTracer(trace.tracer)
+		}
+		fmt.Println("I :> MCP tracing enabled, secret headers are redacted")
+	case "off":
+		if activeTrace == nil {
+			fmt.Println("MCP tracing is off")
+			return
+		}
+		if server != "" && server != activeTrace.server {
+			ocstack.ShowWarn(fmt.Sprintf("MCP tracing is not enabled for %s", server))
+			return
+		}
+		stopMCPTrace(s)
+		fmt.Println("I :> MCP tracing disabled")
+	default:
+		fmt.Println("Usage: /mcp trace on|off [server] [--file <path>]")
+	}
+}
+
+// traceMatches returns true if the active trace covers the given server
+func traceMatches(serverType string) bool {
+	return activeTrace != nil && (activeTrace.server == "" || activeTrace.server == serverType)
+}
+
+// stopMCPTrace stops the active trace and closes its file
+func stopMCPTrace(s *llm.Session) {
+	if activeTrace == nil {
+		return
+	}
+	if registry := getToolRegistry(s); registry != nil {
+		registry.SetTracer(nil)
+	}
+	if activeTrace.file != nil {
+		activeTrace.file.Close()
+	}
+	activeTrace = nil
+}
+
+// commands are the built-in slash commands, completed along with the MCP
+// prompts
+var commands = []string{"exit", "quit", "read", "template", "namespace", "config", "mcp", "prompt", "update", "help"}
+
+// mcpCommands are the /mcp subcommands
+var mcpCommands = []string{"connect", "disconnect", "tools", "call", "trace", "loglevel"}
+
+// completionTimeout bounds the completion/complete requests sent on tab
+const completionTimeout = 2 * time.Second
+
+// completeInput completes the slash commands: command names, /mcp
+// subcommands, tool and prompt names, and prompt arguments. The values of
+// the prompt arguments are suggested by the MCP server
+func completeInput(s *llm.Session) ocstack.Completer {
+	return func(line string) []string {
+		if !strings.HasPrefix(line, "/") {
+			return nil
+		}
+		fields := strings.Fields(line[1:])
+		if len(fields) == 0 || strings.HasSuffix(line, " ") {
+			fields = append(fields, "")
+		}
+		word := fields[len(fields)-1]
+		registry := getToolRegistry(s)
+
+		var promptNames, toolNames []string
+		if registry != nil {
+			for _, prompt := range registry.GetPrompts() {
+				promptNames = append(promptNames, prompt.Name)
+			}
+			toolNames = mcpToolNames(registry)
+		}
+
+		switch {
+		case len(fields) == 1:
+			candidates := withPrefix(append(append([]string{}, commands...), promptNames...), word)
+			for i := range candidates {
+				candidates[i] = "/" + candidates[i]
+			}
+			return candidates
+		case fields[0] == "mcp" && len(fields) == 2:
+			return withPrefix(mcpCommands, word)
+		case fields[0] == "mcp" && len(fields) == 3 && fields[1] == "tools":
+			return withPrefix(append([]string{"refresh"}, toolNames...), word)
+		case fields[0] == "mcp" && len(fields) == 3 && fields[1] == "call":
+			return withPrefix(append([]string{"--context"}, toolNames...), word)
+		case fields[0] == "mcp" && len(fields) == 4 && fields[1] == "call" && fields[2] == "--context":
+			return withPrefix(toolNames, word)
+		case fields[0] == "mcp" && len(fields) == 3 && fields[1] == "trace":
+			return withPrefix([]string{"on", "off"}, word)
+		case fields[0] == "prompt" && len(fields) == 2:
+			return withPrefix(promptNames, word)
+		case fields[0] == "prompt" && registry != nil:
+			return completePromptArgument(registry, fields[1], fields[2:])
+		case registry != nil:
+			// MCP prompts invoked as dynamic slash commands
+			return completePromptArgument(registry, fields[0], fields[1:])
+		}
+		return nil
+	}
+}
+
+// completePromptArgument completes the last of the key=value arguments of a
+// prompt: argument names first, then the values suggested by the server,
+// which receives the other arguments as context
+func completePromptArgument(registry *mcp.MCPToolRegistry, name string, args []string) []string {
+	prompt, ok := registry.FindPrompt(name)
+	if !ok {
+		return nil
+	}
+	word := args[len(args)-1]
+	given := make(map[string]string)
+	for _, arg := range args[:len(args)-1] {
+		if key, value, found := strings.Cut(arg, "="); found {
+			given[key] = value
+		}
+	}
+
+	key, value, found := strings.Cut(word, "=")
+	if !found {
+		var names []string
+		for _, argument := range prompt.Arguments {
+			if _, exists := given[argument.Name]; !exists {
+				names = append(names, argument.Name+"=")
+			}
+		}
+		return withPrefix(names, word)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	values, err := registry.CompletePromptArgument(ctx, prompt.Name, key, value, given)
+	if err != nil {
+		return nil
+	}
+	candidates := make([]string, 0, len(values))
+	for _, v := range values {
+		candidates = append(candidates, key+"="+v)
+	}
+	return candidates
+}
+
+// mcpToolNames returns the names of the tools exposed by the MCP server
+func mcpToolNames(registry *mcp.MCPToolRegistry) []string {
+	var tools []mcp.Tool
+	if err := json.Unmarshal(registry.GetAllTools(), &tools); err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Function != nil {
+			names = append(names, tool.Function.Name)
+		}
+	}
+	return names
+}
+
+// withPrefix returns the values starting with prefix
+func withPrefix(values []string, prefix string) []string {
+	var matches []string
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			matches = append(matches, value)
+		}
+	}
+	return matches
+}
+
+// isLoopback returns true when host only designates the local machine
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback())
+}
+
+// httpServeToken checks that addr only accepts local connections, and returns
+// the bearer token the clients must send: the one set in the environment, or
+// a random one printed on stderr
+func httpServeToken(addr string) (string, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	if !isLoopback(host) {
+		return "", fmt.Errorf("refusing to serve on %s: only loopback addresses are allowed", addr)
+	}
+	if token := os.Getenv(ocstack.MCP_TOKEN_ENV); token != "" {
+		return token, nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate a token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	fmt.Fprintf(os.Stderr, "Clients must authenticate with: export %s=%s\n", ocstack.MCP_TOKEN_ENV, token)
+	return token, nil
+}
+
+// mcpServe - runs ocstack as an MCP server exposing the OpenStack tools
+func mcpServe(args []string) error {
+	flags := flag.NewFlagSet("mcp-serve", flag.ExitOnError)
+	transport := flags.String("transport", "stdio", "transport to serve: stdio or http")
+	addr := flags.String("addr", "localhost:8080", "listen address of the http transport")
+	namespace := flags.String("namespace", ocstack.DEFAULT_NAMESPACE, "default OpenStack namespace")
+	flags.Parse(args)
+
+	// stdout carries the protocol on stdio, report errors on stderr only
+	if _, err := tools.GetKubeConfig(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := tools.NewOpenStackServer(*namespace)
+	switch *transport {
+	case "stdio":
+		return server.ServeStdio(ctx, os.Stdin, os.Stdout)
+	case "http":
+		token, err := httpServeToken(*addr)
+		if err != nil {
+			return err
+		}
+		server.SetBearerToken(token)
+		return server.ListenAndServe(ctx, *addr)
+	}
+	return fmt.Errorf("unsupported transport: %s", *transport)
+}
+
+// mcpGateway - exposes the MCP servers listed in a config file through a
+// single MCP server
+func mcpGateway(args []string) error {
+	flags := flag.NewFlagSet("mcp-gateway", flag.ExitOnError)
+	configFile := flags.String("config", "", "JSON file describing the upstream MCP servers")
+	transport := flags.String("transport", "stdio", "transport to serve: stdio or http")
+	addr := flags.String("addr", "localhost:8080", "listen address of the http transport")
+	auditFile := flags.String("audit", "", "file receiving the audit trail (JSON lines), stderr when empty")
+	flags.Parse(args)
+
+	if *configFile == "" {
+		return fmt.Errorf("-config is required")
+	}
+	config, err := gateway.LoadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	audit := gateway.NewAuditLog(os.Stderr)
+	if *auditFile != "" {
+		file, err := os.OpenFile(*auditFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open the audit trail: %w", err)
+		}
+		defer file.Close()
+		audit = gateway.NewAuditLog(file)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	gw := gateway.New(*config, "ocstack-mcp-gateway", "1.0.0", audit)
+	if err := gw.Connect(ctx); err != nil {
+		return err
+	}
+	defer gw.Close()
+
+	switch *transport {
+	case "stdio":
+		return gw.Server().ServeStdio(ctx, os.Stdin, os.Stdout)
+	case "http":
+		token, err := httpServeToken(*addr)
+		if err != nil {
+			return err
+		}
+		gw.Server().SetBearerToken(token)
+		return gw.Server().ListenAndServe(ctx, *addr)
+	}
+	return fmt.Errorf("unsupported transport: %s", *transport)
+}
+
 func main() {
+	// A copy of ocstack started to run a stdio MCP server with limits
+	mcp.RunLimitedIfRequested()
+
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "mcp-serve" {
+		if err := mcpServe(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "mcp-serve: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "mcp-gateway" {
+		if err := mcpGateway(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "mcp-gateway: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Validate ocstack input required to access Tools
 	tools.ExitOnErrors()
@@ -260,10 +1189,11 @@ func main() {
 	ocstack.TermHeader("default")
 
 	for {
-		fmt.Printf("Q :> ")
 		// Read input
-		reader := bufio.NewReader(os.Stdin)
-		input, err := reader.ReadString('\n')
+		input, err := ocstack.ReadLine("Q :> ", completeInput(s))
+		if errors.Is(err, ocstack.ErrInterrupted) {
+			os.Exit(130)
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -283,7 +1213,7 @@ func main() {
 		if len(input) > 0 && strings.HasPrefix(input, "/") {
 			// Trim any whitespace from the input
 			q := strings.TrimSpace(input)
-			CliCommand(strings.TrimPrefix(q, "/"), s)
+			CliCommand(strings.TrimPrefix(q, "/"), s, client, ctx)
 			continue
 		}
 
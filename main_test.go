@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fmount/ocstack/mcp"
+	"github.com/fmount/ocstack/pkg/ocstack"
+)
+
+func TestRemoteMCPConfig(t *testing.T) {
+	t.Setenv(ocstack.MCP_TOKEN_ENV, "secret")
+
+	tests := []struct {
+		url   string
+		token bool
+	}{
+		{"http://localhost:8080/mcp", true},
+		{"http://127.0.0.1:8080/mcp", true},
+		{"ws://[::1]:8080/ws", true},
+		{"https://mcp.example.com/mcp", false},
+		{"http://localhost.example.com/mcp", false},
+		{"http://10.0.0.1:8080/mcp", false},
+		{"http://0.0.0.0:8080/mcp", false},
+		{"://invalid", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			config := remoteMCPConfig(mcp.TransportHTTP, tt.url)
+			if got := config.BearerToken != ""; got != tt.token {
+				t.Errorf("token sent = %v, want %v", got, tt.token)
+			}
+			if !tt.token && config.OAuth == nil {
+				t.Error("no OAuth for a server without the token")
+			}
+		})
+	}
+}
@@ -2,8 +2,10 @@ package mcp
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 )
 
@@ -76,21 +78,7 @@ func (a *ToolAdapter) ExecuteMCPTool(f any) string {
 		return "Error: unable to convert function call"
 	}
 	
-	if !a.client.IsConnected() {
-		return "Error: MCP client not connected"
-	}
-
-	ctx := context.Background()
-	response, err := a.client.CallTool(ctx, functionCall.Name, functionCall.Arguments)
-	if err != nil {
-		return fmt.Sprintf("Error calling MCP tool %s: %v", functionCall.Name, err)
-	}
-
-	if response.IsError {
-		return fmt.Sprintf("MCP tool %s returned error: %s", functionCall.Name, a.formatToolResults(response.Content))
-	}
-
-	return a.formatToolResults(response.Content)
+	return a.execute(functionCall.Name, functionCall.Arguments, f)
 }
 
 // convertAndExecute handles conversion from tools.FunctionCall to mcp.FunctionCall
@@ -112,30 +100,98 @@ func (a *ToolAdapter) convertAndExecute(f interface{}) string {
 	}
 	
 	// Now execute with the converted data
+	return a.execute(funcCall.Name, funcCall.Arguments, f)
+}
+
+// execute calls the tool and formats its result. Binary content is handed
+// over to the original function call when it implements MediaReceiver
+func (a *ToolAdapter) execute(name string, args map[string]any, f interface{}) string {
 	if !a.client.IsConnected() {
 		return "Error: MCP client not connected"
 	}
 
-	ctx := context.Background()
-	response, err := a.client.CallTool(ctx, funcCall.Name, funcCall.Arguments)
+	ctx, cancel := context.WithTimeout(context.Background(), a.client.ToolTimeout(name))
+	defer cancel()
+	response, err := a.client.CallTool(ctx, name, args)
 	if err != nil {
-		return fmt.Sprintf("Error calling MCP tool %s: %v", funcCall.Name, err)
+		return fmt.Sprintf("Error calling MCP tool %s: %v", name, err)
+	}
+
+	if receiver, ok := f.(MediaReceiver); ok {
+		a.forwardMedia(response.Content, receiver)
 	}
 
 	if response.IsError {
-		return fmt.Sprintf("MCP tool %s returned error: %s", funcCall.Name, a.formatToolResults(response.Content))
+		return fmt.Sprintf("MCP tool %s returned error: %s", name, a.formatToolResults(response.Content))
+	}
+
+	result := a.formatToolResults(response.Content)
+	if response.StructuredContent != nil && a.client.Supports(FeatureStructuredOutput) {
+		result = a.appendStructuredContent(name, result, response.StructuredContent)
 	}
+	return result
+}
 
-	return a.formatToolResults(response.Content)
+// MediaReceiver is implemented by function calls able to carry binary tool
+// output (e.g. images) to multimodal LLM providers
+type MediaReceiver interface {
+	AddMedia(mimeType string, data []byte)
+}
+
+// forwardMedia decodes image and audio content and passes it to the receiver
+func (a *ToolAdapter) forwardMedia(content []ToolResult, receiver MediaReceiver) {
+	for _, result := range content {
+		if result.Type != ContentImage && result.Type != ContentAudio {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(result.Data)
+		if err != nil {
+			continue
+		}
+		receiver.AddMedia(result.MimeType, data)
+	}
 }
 
-// formatToolResults converts MCP tool results to string format
+// appendStructuredContent validates the structured output against the tool
+// output schema. The JSON is used as result when the tool did not return any
+// text, as recommended by the specification for backwards compatibility
+func (a *ToolAdapter) appendStructuredContent(name string, result string, structured interface{}) string {
+	if strings.TrimSpace(result) == "" {
+		if b, err := json.MarshalIndent(structured, "", "  "); err == nil {
+			result = string(b)
+		}
+	}
+
+	if tool, ok := a.client.GetTool(name); ok && tool.OutputSchema != nil {
+		if err := tool.OutputSchema.Validate(structured); err != nil {
+			result = fmt.Sprintf("%s\nWarning: the structured output of %s does not match its output schema: %v", result, name, err)
+		}
+	}
+	return result
+}
+
+// formatToolResults converts MCP tool results to string format. Content that
+// can't be represented as text is summarized, so text-only models still know
+// it was returned
 func (a *ToolAdapter) formatToolResults(content []ToolResult) string {
 	var results []string
 	for _, result := range content {
 		switch result.Type {
-		case "text":
+		case ContentText:
 			results = append(results, result.Text)
+		case ContentImage, ContentAudio:
+			results = append(results, fmt.Sprintf("[%s: %s, %s]", result.Type, result.MimeType, formatSize(decodedSize(result.Data))))
+		case ContentResource:
+			results = append(results, formatResource(result.Resource))
+		case ContentResourceLink:
+			link := fmt.Sprintf("[resource link: %s <%s>", result.Name, result.URI)
+			if result.MimeType != "" {
+				link += ", " + result.MimeType
+			}
+			if result.Description != "" {
+				link += " - " + result.Description
+			}
+			results = append(results, link+"]")
 		default:
 			// Handle other types as needed
 			results = append(results, result.Text)
@@ -144,6 +200,36 @@ func (a *ToolAdapter) formatToolResults(content []ToolResult) string {
 	return strings.Join(results, "\n")
 }
 
+func formatResource(resource *ResourceContents) string {
+	if resource == nil {
+		return "[empty resource]"
+	}
+	if resource.Text != "" {
+		return fmt.Sprintf("Resource %s:\n%s", resource.URI, resource.Text)
+	}
+	mimeType := resource.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return fmt.Sprintf("[resource: %s, %s, %s]", resource.URI, mimeType, formatSize(decodedSize(resource.Blob)))
+}
+
+// decodedSize returns the size of base64 encoded data without decoding it
+func decodedSize(data string) int {
+	return base64.StdEncoding.DecodedLen(len(data)) - strings.Count(data, "=")
+}
+
+func formatSize(size int) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%d bytes", size)
+	}
+}
+
 // MCPToolRegistry manages MCP tools alongside local tools
 type MCPToolRegistry struct {
 	mcpClient   Client
@@ -203,16 +289,16 @@ func (r *MCPToolRegistry) GetAllTools() []byte {
 
 	result, err := json.Marshal(allTools)
 	if err != nil {
-		fmt.Printf("Error marshaling combined tools: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error marshaling combined tools: %v\n", err)
 		return r.localTools // fallback to local tools only
 	}
 
 	return result
 }
 
-// IsToolFromMCP checks if a tool name comes from MCP
+// IsToolFromMCP checks if a tool name comes from MCP. The lookup is served by
+// the client tool catalog, no request is sent to the server
 func (r *MCPToolRegistry) IsToolFromMCP(toolName string) bool {
-	
 	if !r.mcpEnabled || r.mcpClient == nil {
 		return false
 	}
@@ -221,25 +307,143 @@ func (r *MCPToolRegistry) IsToolFromMCP(toolName string) bool {
 		return false
 	}
 
-	// The GetAvailableTools() method seems to be broken, so let's use a different approach
-	// If MCP is enabled and connected, and we can see tools via /mcp tools, then assume
-	// any tool request should go to MCP first since that's the intended behavior
-	
-	// Try to call ListTools directly on the client to see if the tool exists
-	ctx := context.Background()
-	tools, err := r.mcpClient.ListTools(ctx)
+	return r.mcpClient.HasTool(toolName)
+}
+
+// RefreshTools fetches the tool list from the MCP server and updates the
+// catalog
+func (r *MCPToolRegistry) RefreshTools(ctx context.Context) error {
+	if !r.mcpEnabled || r.mcpClient == nil {
+		return fmt.Errorf("MCP client not connected")
+	}
+	return r.mcpClient.RefreshTools(ctx)
+}
+
+// SetLogLevel sets the minimum level of the MCP server log messages shown to
+// the user
+func (r *MCPToolRegistry) SetLogLevel(ctx context.Context, level string) error {
+	if !r.mcpEnabled || r.mcpClient == nil {
+		return fmt.Errorf("MCP client not connected")
+	}
+	return r.mcpClient.SetLogLevel(ctx, level)
+}
+
+// SetRoots updates the roots exposed to the MCP server
+func (r *MCPToolRegistry) SetRoots(ctx context.Context, roots []Root) error {
+	if !r.mcpEnabled || r.mcpClient == nil {
+		return fmt.Errorf("MCP client not connected")
+	}
+	return r.mcpClient.SetRoots(ctx, roots)
+}
+
+// Disconnect closes the connection to the MCP server, which stops the
+// reconnection attempts and the stdio server process
+func (r *MCPToolRegistry) Disconnect() error {
+	if r.mcpClient == nil {
+		return nil
+	}
+	return r.mcpClient.Disconnect()
+}
+
+// SetTracer traces the frames exchanged with the MCP server, a nil tracer
+// stops tracing
+func (r *MCPToolRegistry) SetTracer(tracer *Tracer) {
+	if r.mcpClient != nil {
+		r.mcpClient.SetTracer(tracer)
+	}
+}
+
+// GetPrompts returns the prompts exposed by the connected MCP server
+func (r *MCPToolRegistry) GetPrompts() []Prompt {
+	if !r.mcpEnabled || r.mcpClient == nil || !r.mcpClient.IsConnected() {
+		return nil
+	}
+	return r.mcpClient.GetAvailablePrompts()
+}
+
+// FindPrompt looks up a prompt by name (case insensitive)
+func (r *MCPToolRegistry) FindPrompt(name string) (*Prompt, bool) {
+	for _, prompt := range r.GetPrompts() {
+		if strings.EqualFold(prompt.Name, name) {
+			return &prompt, true
+		}
+	}
+	return nil, false
+}
+
+// CompletePromptArgument returns the values suggested by the server for an
+// argument of a prompt, given its partial value and the other arguments
+func (r *MCPToolRegistry) CompletePromptArgument(ctx context.Context, prompt string, argument string, value string, args map[string]string) ([]string, error) {
+	if !r.mcpEnabled || r.mcpClient == nil {
+		return nil, fmt.Errorf("MCP client not connected")
+	}
+	completion, err := r.mcpClient.Complete(ctx,
+		CompletionReference{Type: RefPrompt, Name: prompt},
+		CompletionArgument{Name: argument, Value: value},
+		args)
 	if err != nil {
-		fmt.Printf("[WARN] - Error listing MCP tools: %v\n", err)
-		return false
+		return nil, err
 	}
-	
-	for _, tool := range tools {
-		if tool.Name == toolName {
-			return true
+	return completion.Values, nil
+}
+
+// RenderPrompt validates the arguments and renders the prompt on the MCP
+// server
+func (r *MCPToolRegistry) RenderPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResponse, error) {
+	prompt, ok := r.FindPrompt(name)
+	if !ok {
+		return nil, fmt.Errorf("prompt '%s' not available", name)
+	}
+	if err := prompt.ValidateArguments(args); err != nil {
+		return nil, err
+	}
+	return r.mcpClient.GetPrompt(ctx, prompt.Name, args)
+}
+
+// ValidateArguments checks that all the required arguments are provided and
+// no unknown argument is passed to the prompt
+func (p *Prompt) ValidateArguments(args map[string]string) error {
+	known := make(map[string]bool)
+	var missing []string
+	for _, arg := range p.Arguments {
+		known[arg.Name] = true
+		if v, exists := args[arg.Name]; arg.Required && (!exists || v == "") {
+			missing = append(missing, arg.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("prompt '%s' requires: %s", p.Name, strings.Join(missing, ", "))
+	}
+	for name := range args {
+		if !known[name] {
+			return fmt.Errorf("prompt '%s' has no argument '%s'", p.Name, name)
 		}
 	}
+	return nil
+}
 
-	return false
+// ContentText returns the text carried by a prompt message
+func (m *PromptMessage) ContentText() string {
+	switch m.Content.Type {
+	case ContentText:
+		return m.Content.Text
+	case ContentResource:
+		return formatResource(m.Content.Resource)
+	}
+	return fmt.Sprintf("[%s content]", m.Content.Type)
+}
+
+// Usage returns a one line usage string for the prompt
+func (p *Prompt) Usage() string {
+	usage := []string{"/" + p.Name}
+	for _, arg := range p.Arguments {
+		if arg.Required {
+			usage = append(usage, fmt.Sprintf("%s=<value>", arg.Name))
+		} else {
+			usage = append(usage, fmt.Sprintf("[%s=<value>]", arg.Name))
+		}
+	}
+	return strings.Join(usage, " ")
 }
 
 // Sample MCP configurations for common servers
@@ -250,12 +454,13 @@ var (
 		Command:   []string{"npx", "-y", "@modelcontextprotocol/server-filesystem", "/tmp"},
 	}
 
-	// Example configuration for brave search MCP server (stdio)
+	// Example configuration for brave search MCP server (stdio), the
+	// BRAVE_API_KEY env var is inherited by the server
 	BraveSearchMCPConfig = MCPConfig{
 		Transport: TransportStdio,
 		Command:   []string{"npx", "-y", "@modelcontextprotocol/server-brave-search"},
-		Env: map[string]string{
-			"BRAVE_API_KEY": "", // Set this to your actual API key
+		Process: &ProcessConfig{
+			EnvAllowlist: append([]string{"BRAVE_API_KEY"}, DefaultEnvAllowlist...),
 		},
 	}
 
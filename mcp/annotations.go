@@ -0,0 +1,97 @@
+package mcp
+
+import "strings"
+
+// IsReadOnly returns true if the tool declares it does not modify its
+// environment
+func (t *MCPTool) IsReadOnly() bool {
+	return t.Annotations != nil && t.Annotations.ReadOnlyHint != nil && *t.Annotations.ReadOnlyHint
+}
+
+// IsDestructive returns true if the tool may perform destructive updates. As
+// defined by the specification, destructiveHint defaults to true for tools
+// that are not read-only. Tools without annotations are not classified
+func (t *MCPTool) IsDestructive() bool {
+	if t.Annotations == nil || t.IsReadOnly() {
+		return false
+	}
+	if t.Annotations.DestructiveHint == nil {
+		return true
+	}
+	return *t.Annotations.DestructiveHint
+}
+
+// IsIdempotent returns true if calling the tool repeatedly with the same
+// arguments has no additional effect
+func (t *MCPTool) IsIdempotent() bool {
+	if t.IsReadOnly() {
+		return true
+	}
+	return t.Annotations != nil && t.Annotations.IdempotentHint != nil && *t.Annotations.IdempotentHint
+}
+
+// Title returns the human readable name of the tool
+func (t *MCPTool) Title() string {
+	if t.Annotations != nil && t.Annotations.Title != "" {
+		return t.Annotations.Title
+	}
+	return t.Name
+}
+
+// Hints returns the annotations of the tool as short labels, e.g.
+// "read-only, closed-world"
+func (t *MCPTool) Hints() string {
+	if t.Annotations == nil {
+		return ""
+	}
+
+	var hints []string
+	switch {
+	case t.IsReadOnly():
+		hints = append(hints, "read-only")
+	case t.IsDestructive():
+		hints = append(hints, "destructive")
+	}
+	if t.IsIdempotent() && !t.IsReadOnly() {
+		hints = append(hints, "idempotent")
+	}
+	if h := t.Annotations.OpenWorldHint; h != nil {
+		if *h {
+			hints = append(hints, "open-world")
+		} else {
+			hints = append(hints, "closed-world")
+		}
+	}
+	return strings.Join(hints, ", ")
+}
+
+// annotatedTool returns the tool when its annotations can be trusted: the
+// server must have negotiated a protocol revision defining them
+func (r *MCPToolRegistry) annotatedTool(name string) (*MCPTool, bool) {
+	if !r.mcpEnabled || r.mcpClient == nil || !r.mcpClient.Supports(FeatureToolAnnotations) {
+		return nil, false
+	}
+	return r.mcpClient.GetTool(name)
+}
+
+// GetTool returns the definition of an MCP tool
+func (r *MCPToolRegistry) GetTool(name string) (*MCPTool, bool) {
+	if !r.mcpEnabled || r.mcpClient == nil {
+		return nil, false
+	}
+	return r.mcpClient.GetTool(name)
+}
+
+// IsToolReadOnly returns true if the tool declares itself as read-only
+// (implements the llm registry interface)
+func (r *MCPToolRegistry) IsToolReadOnly(name string) bool {
+	tool, ok := r.annotatedTool(name)
+	return ok && tool.IsReadOnly()
+}
+
+// IsToolDestructive returns true if the tool may perform destructive updates
+// (implements the llm registry interface)
+func (r *MCPToolRegistry) IsToolDestructive(name string) bool {
+	tool, ok := r.annotatedTool(name)
+	return ok && tool.IsDestructive()
+}
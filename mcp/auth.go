@@ -0,0 +1,677 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// authorizationTimeout bounds the time the user has to complete the
+	// authorization in the browser
+	authorizationTimeout = 5 * time.Minute
+	// tokenExpiryDelta refreshes tokens a bit before they actually expire
+	tokenExpiryDelta = 30 * time.Second
+	// clientName is sent during dynamic client registration
+	clientName = "ocstack"
+)
+
+// Authenticator provides the credentials used by remote transports
+type Authenticator interface {
+	// Token returns the access token to send, or an empty string when no
+	// token is available yet
+	Token(ctx context.Context) (string, error)
+	// Authorize obtains a new token, it is called when the server answers
+	// with 401 and receives the WWW-Authenticate header
+	Authorize(ctx context.Context, challenge string) error
+}
+
+// OAuthConfig configures the MCP authorization flow: OAuth 2.1 authorization
+// code grant with PKCE, with discovery of the authorization server through
+// the protected resource metadata
+type OAuthConfig struct {
+	// Pre-registered client, dynamic client registration is used when empty
+	ClientID     string `json:"clientId,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Local port of the redirect listener, a random port is used when 0
+	RedirectPort int `json:"redirectPort,omitempty"`
+
+	// Where tokens are stored, defaults to
+	// <user config dir>/ocstack/mcp-tokens.json
+	TokenFile string `json:"tokenFile,omitempty"`
+
+	// OpenURL is called with the authorization URL the user has to visit,
+	// by default the URL is printed to Output
+	OpenURL func(authURL string) error `json:"-"`
+
+	// Where the warnings and the authorization URL are printed, os.Stderr
+	// when nil
+	Output io.Writer `json:"-"`
+}
+
+// ProtectedResourceMetadata is defined by RFC 9728
+type ProtectedResourceMetadata struct {
+	Resource             string   `json:"resource"`
+	AuthorizationServers []string `json:"authorization_servers"`
+	ScopesSupported      []string `json:"scopes_supported,omitempty"`
+}
+
+// AuthorizationServerMetadata is defined by RFC 8414
+type AuthorizationServerMetadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	RegistrationEndpoint          string   `json:"registration_endpoint,omitempty"`
+	ScopesSupported               []string `json:"scopes_supported,omitempty"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported,omitempty"`
+}
+
+// OAuthToken is the token endpoint response, Expiry is computed on receipt
+type OAuthToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// Valid returns true if the token can still be used
+func (t *OAuthToken) Valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(t.Expiry)
+}
+
+// OAuthCredentials is what we persist for a given server
+type OAuthCredentials struct {
+	ClientID      string      `json:"clientId,omitempty"`
+	ClientSecret  string      `json:"clientSecret,omitempty"`
+	TokenEndpoint string      `json:"tokenEndpoint,omitempty"`
+	Token         *OAuthToken `json:"token,omitempty"`
+}
+
+// TokenStore persists the OAuth credentials of MCP servers
+type TokenStore interface {
+	Load(key string) (*OAuthCredentials, error)
+	Save(key string, credentials *OAuthCredentials) error
+}
+
+// FileTokenStore stores credentials in a JSON file only readable by the user
+type FileTokenStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileTokenStore creates a store backed by the given file
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+// DefaultTokenFile returns the default location of the token store
+func DefaultTokenFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "ocstack", "mcp-tokens.json")
+}
+
+func (f *FileTokenStore) Load(key string) (*OAuthCredentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	all, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return all[key], nil
+}
+
+func (f *FileTokenStore) Save(key string, credentials *OAuthCredentials) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	all, err := f.read()
+	if err != nil {
+		return err
+	}
+	all[key] = credentials
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tokens: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create token dir: %w", err)
+	}
+	if err := os.WriteFile(f.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write tokens: %w", err)
+	}
+	return nil
+}
+
+func (f *FileTokenStore) read() (map[string]*OAuthCredentials, error) {
+	all := make(map[string]*OAuthCredentials)
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokens: %w", err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to parse tokens: %w", err)
+	}
+	return all, nil
+}
+
+// OAuthAuthenticator implements the MCP authorization flow for a server
+type OAuthAuthenticator struct {
+	serverURL  string
+	config     OAuthConfig
+	httpClient *http.Client
+	store      TokenStore
+
+	mu          sync.Mutex
+	credentials *OAuthCredentials
+}
+
+// NewOAuthAuthenticator creates an authenticator for the given server. The
+// HTTP client is used for discovery and token requests, so it should share
+// the TLS settings of the transport
+func NewOAuthAuthenticator(serverURL string, config OAuthConfig, httpClient *http.Client) *OAuthAuthenticator {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if config.TokenFile == "" {
+		config.TokenFile = DefaultTokenFile()
+	}
+	if config.Output == nil {
+		config.Output = os.Stderr
+	}
+	return &OAuthAuthenticator{
+		serverURL:  serverURL,
+		config:     config,
+		httpClient: httpClient,
+		store:      NewFileTokenStore(config.TokenFile),
+	}
+}
+
+// SetTokenStore replaces the default file based store
+func (a *OAuthAuthenticator) SetTokenStore(store TokenStore) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.store = store
+	a.credentials = nil
+}
+
+// Token returns a valid access token, refreshing it if it expired
+func (a *OAuthAuthenticator) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.credentials == nil {
+		credentials, err := a.store.Load(a.serverURL)
+		if err != nil {
+			return "", err
+		}
+		a.credentials = credentials
+	}
+	if a.credentials == nil || a.credentials.Token == nil {
+		return "", nil
+	}
+	if a.credentials.Token.Valid() {
+		return a.credentials.Token.AccessToken, nil
+	}
+	if a.credentials.Token.RefreshToken == "" || a.credentials.TokenEndpoint == "" {
+		return "", nil
+	}
+
+	token, err := a.refresh(ctx)
+	if err != nil {
+		// The refresh token may have been revoked, a new authorization is
+		// triggered by the next 401
+		a.printf("Warning: failed to refresh MCP access token: %v\n", err)
+		a.credentials.Token = nil
+		return "", nil
+	}
+	a.credentials.Token = token
+	if err := a.store.Save(a.serverURL, a.credentials); err != nil {
+		a.printf("Warning: failed to store MCP access token: %v\n", err)
+	}
+	return token.AccessToken, nil
+}
+
+// Authorize discovers the authorization server and runs the authorization
+// code flow with PKCE
+func (a *OAuthAuthenticator) Authorize(ctx context.Context, challenge string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	resourceMetadata := a.discoverResource(ctx, challengeParam(challenge, "resource_metadata"))
+	issuer := originOf(a.serverURL)
+	if resourceMetadata != nil && len(resourceMetadata.AuthorizationServers) > 0 {
+		issuer = resourceMetadata.AuthorizationServers[0]
+	}
+
+	metadata, err := a.discoverAuthorizationServer(ctx, issuer)
+	if err != nil {
+		return err
+	}
+	if len(metadata.CodeChallengeMethodsSupported) > 0 && !contains(metadata.CodeChallengeMethodsSupported, "S256") {
+		return fmt.Errorf("authorization server %s does not support PKCE S256", issuer)
+	}
+
+	scopes := a.config.Scopes
+	if len(scopes) == 0 {
+		if scope := challengeParam(challenge, "scope"); scope != "" {
+			scopes = strings.Fields(scope)
+		} else if resourceMetadata != nil {
+			scopes = resourceMetadata.ScopesSupported
+		}
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", a.config.RedirectPort))
+	if err != nil {
+		return fmt.Errorf("failed to start the OAuth redirect listener: %w", err)
+	}
+	defer listener.Close()
+	redirectURI := fmt.Sprintf("http://%s/callback", listener.Addr().String())
+
+	credentials, err := a.clientCredentials(ctx, metadata, redirectURI)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := randomString(32)
+	if err != nil {
+		return err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return err
+	}
+
+	code, err := a.requestAuthorizationCode(ctx, listener, metadata, credentials.ClientID, redirectURI, scopes, verifier, state)
+	if err != nil {
+		return err
+	}
+
+	token, err := a.tokenRequest(ctx, metadata.TokenEndpoint, credentials, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+		"resource":      {a.serverURL},
+	})
+	if err != nil {
+		return err
+	}
+
+	credentials.TokenEndpoint = metadata.TokenEndpoint
+	credentials.Token = token
+	a.credentials = credentials
+	if err := a.store.Save(a.serverURL, credentials); err != nil {
+		a.printf("Warning: failed to store MCP access token: %v\n", err)
+	}
+	return nil
+}
+
+// discoverResource fetches the protected resource metadata, either from the
+// URL advertised by the server or from the well-known locations
+func (a *OAuthAuthenticator) discoverResource(ctx context.Context, metadataURL string) *ProtectedResourceMetadata {
+	candidates := []string{}
+	if metadataURL != "" {
+		candidates = append(candidates, metadataURL)
+	}
+	if u, err := url.Parse(a.serverURL); err == nil {
+		origin := originOf(a.serverURL)
+		if path := strings.TrimSuffix(u.Path, "/"); path != "" {
+			candidates = append(candidates, origin+"/.well-known/oauth-protected-resource"+path)
+		}
+		candidates = append(candidates, origin+"/.well-known/oauth-protected-resource")
+	}
+
+	for _, candidate := range candidates {
+		var metadata ProtectedResourceMetadata
+		if err := a.getJSON(ctx, candidate, &metadata); err == nil {
+			return &metadata
+		}
+	}
+	return nil
+}
+
+// discoverAuthorizationServer fetches the authorization server metadata. When
+// the server does not publish it, the default endpoints are used
+func (a *OAuthAuthenticator) discoverAuthorizationServer(ctx context.Context, issuer string) (*AuthorizationServerMetadata, error) {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorization server %s: %w", issuer, err)
+	}
+	origin := originOf(issuer)
+	path := strings.TrimSuffix(u.Path, "/")
+
+	candidates := []string{
+		origin + "/.well-known/oauth-authorization-server" + path,
+		origin + "/.well-known/openid-configuration" + path,
+		strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration",
+	}
+	for _, candidate := range candidates {
+		var metadata AuthorizationServerMetadata
+		if err := a.getJSON(ctx, candidate, &metadata); err == nil && metadata.TokenEndpoint != "" {
+			return &metadata, nil
+		}
+	}
+
+	return &AuthorizationServerMetadata{
+		Issuer:                origin,
+		AuthorizationEndpoint: origin + "/authorize",
+		TokenEndpoint:         origin + "/token",
+		RegistrationEndpoint:  origin + "/register",
+	}, nil
+}
+
+// clientCredentials returns the configured or stored client, registering a
+// new one when the authorization server supports it
+func (a *OAuthAuthenticator) clientCredentials(ctx context.Context, metadata *AuthorizationServerMetadata, redirectURI string) (*OAuthCredentials, error) {
+	if a.config.ClientID != "" {
+		return &OAuthCredentials{
+			ClientID:     a.config.ClientID,
+			ClientSecret: a.config.ClientSecret,
+		}, nil
+	}
+
+	// Loopback redirect URIs only differ by port, a client registered with
+	// a fixed port can be reused
+	stored, _ := a.store.Load(a.serverURL)
+	if stored != nil && stored.ClientID != "" && a.config.RedirectPort != 0 {
+		return &OAuthCredentials{
+			ClientID:     stored.ClientID,
+			ClientSecret: stored.ClientSecret,
+		}, nil
+	}
+
+	if metadata.RegistrationEndpoint == "" {
+		return nil, fmt.Errorf("no OAuth client configured and the authorization server does not support dynamic registration")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"client_name":                clientName,
+		"redirect_uris":              []string{redirectURI},
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.RegistrationEndpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var registration struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret,omitempty"`
+	}
+	if err := a.doJSON(req, &registration); err != nil {
+		return nil, fmt.Errorf("dynamic client registration failed: %w", err)
+	}
+	if registration.ClientID == "" {
+		return nil, fmt.Errorf("dynamic client registration returned no client_id")
+	}
+	return &OAuthCredentials{
+		ClientID:     registration.ClientID,
+		ClientSecret: registration.ClientSecret,
+	}, nil
+}
+
+// requestAuthorizationCode sends the user to the authorization endpoint and
+// waits for the redirect carrying the code
+func (a *OAuthAuthenticator) requestAuthorizationCode(
+	ctx context.Context,
+	listener net.Listener,
+	metadata *AuthorizationServerMetadata,
+	clientID string,
+	redirectURI string,
+	scopes []string,
+	verifier string,
+	state string,
+) (string, error) {
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+		"state":                 {state},
+		"resource":              {a.serverURL},
+	}
+	if len(scopes) > 0 {
+		params.Set("scope", strings.Join(scopes, " "))
+	}
+	authURL := metadata.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + params.Encode()
+	} else {
+		authURL += "?" + params.Encode()
+	}
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var res result
+		switch {
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("authorization failed: %s %s", q.Get("error"), q.Get("error_description"))
+		case q.Get("state") != state:
+			res.err = fmt.Errorf("authorization failed: state mismatch")
+		case q.Get("code") == "":
+			res.err = fmt.Errorf("authorization failed: no code returned")
+		default:
+			res.code = q.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "ocstack authorization complete, you can close this window.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	openURL := a.config.OpenURL
+	if openURL == nil {
+		openURL = a.printAuthorizationURL
+	}
+	if err := openURL(authURL); err != nil {
+		return "", fmt.Errorf("failed to open the authorization URL: %w", err)
+	}
+
+	select {
+	case res := <-results:
+		return res.code, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(authorizationTimeout):
+		return "", fmt.Errorf("authorization timed out")
+	}
+}
+
+// refresh exchanges the refresh token for a new access token
+func (a *OAuthAuthenticator) refresh(ctx context.Context) (*OAuthToken, error) {
+	token, err := a.tokenRequest(ctx, a.credentials.TokenEndpoint, a.credentials, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {a.credentials.Token.RefreshToken},
+		"resource":      {a.serverURL},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Refresh tokens are not always rotated
+	if token.RefreshToken == "" {
+		token.RefreshToken = a.credentials.Token.RefreshToken
+	}
+	return token, nil
+}
+
+func (a *OAuthAuthenticator) tokenRequest(ctx context.Context, endpoint string, credentials *OAuthCredentials, form url.Values) (*OAuthToken, error) {
+	form.Set("client_id", credentials.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if credentials.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(credentials.ClientID), url.QueryEscape(credentials.ClientSecret))
+	}
+
+	var token OAuthToken
+	if err := a.doJSON(req, &token); err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token request returned no access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return &token, nil
+}
+
+func (a *OAuthAuthenticator) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return a.doJSON(req, v)
+}
+
+func (a *OAuthAuthenticator) doJSON(req *http.Request, v interface{}) error {
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d: %s", req.URL, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// staticTokenAuthenticator sends a preconfigured bearer token
+type staticTokenAuthenticator struct {
+	token string
+}
+
+func (s *staticTokenAuthenticator) Token(ctx context.Context) (string, error) {
+	return s.token, nil
+}
+
+func (s *staticTokenAuthenticator) Authorize(ctx context.Context, challenge string) error {
+	return fmt.Errorf("the configured bearer token was rejected by the server")
+}
+
+// newAuthenticator returns the authenticator configured for remote
+// transports, if any
+func newAuthenticator(config MCPConfig, httpClient *http.Client) Authenticator {
+	switch {
+	case config.BearerToken != "":
+		return &staticTokenAuthenticator{token: config.BearerToken}
+	case config.OAuth != nil:
+		oauth := *config.OAuth
+		if oauth.Output == nil {
+			oauth.Output = config.Output
+		}
+		return NewOAuthAuthenticator(config.ServerURL, oauth, httpClient)
+	}
+	return nil
+}
+
+// printAuthorizationURL asks the user to visit authURL
+func (a *OAuthAuthenticator) printAuthorizationURL(authURL string) error {
+	a.printf("I :> The MCP server requires authorization.\n")
+	a.printf("I :> Open the following URL in your browser to authorize ocstack:\n")
+	a.printf("%s\n", authURL)
+	return nil
+}
+
+// printf writes a message for the user to the configured Output
+func (a *OAuthAuthenticator) printf(format string, args ...any) {
+	fmt.Fprintf(a.config.Output, format, args...)
+}
+
+var challengeParamRegexp = regexp.MustCompile(`([a-zA-Z_]+)="([^"]*)"`)
+
+// challengeParam extracts a parameter from a WWW-Authenticate header
+func challengeParam(challenge string, name string) string {
+	for _, match := range challengeParamRegexp.FindAllStringSubmatch(challenge, -1) {
+		if match[1] == name {
+			return match[2]
+		}
+	}
+	return ""
+}
+
+func originOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random data: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
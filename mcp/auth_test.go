@@ -0,0 +1,365 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// authServer is a stand-in for both the authorization server, under /as, and
+// the protected MCP endpoint, under /mcp
+type authServer struct {
+	*httptest.Server
+	t *testing.T
+
+	// methods advertised in the authorization server metadata
+	challengeMethods []string
+	// serve the protected resource metadata
+	resourceMetadata bool
+
+	mu            sync.Mutex
+	registrations int
+	challenge     string
+	redirectURI   string
+	code          string
+	accessToken   string
+	refreshToken  string
+	issued        int
+	grants        []string
+	unauthorized  int
+}
+
+func newAuthServer(t *testing.T) *authServer {
+	s := &authServer{
+		t:                t,
+		challengeMethods: []string{"S256"},
+		resourceMetadata: true,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/oauth-protected-resource/mcp", s.handleResourceMetadata)
+	mux.HandleFunc("/.well-known/oauth-authorization-server/as", s.handleServerMetadata)
+	mux.HandleFunc("/as/register", s.handleRegister)
+	mux.HandleFunc("/as/authorize", s.handleAuthorize)
+	mux.HandleFunc("/as/token", s.handleToken)
+	mux.HandleFunc("/mcp", s.handleMCP)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *authServer) handleResourceMetadata(w http.ResponseWriter, r *http.Request) {
+	if !s.resourceMetadata {
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(ProtectedResourceMetadata{
+		Resource:             s.URL + "/mcp",
+		AuthorizationServers: []string{s.URL + "/as"},
+		ScopesSupported:      []string{"mcp"},
+	})
+}
+
+func (s *authServer) handleServerMetadata(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(AuthorizationServerMetadata{
+		Issuer:                        s.URL + "/as",
+		AuthorizationEndpoint:         s.URL + "/as/authorize",
+		TokenEndpoint:                 s.URL + "/as/token",
+		RegistrationEndpoint:          s.URL + "/as/register",
+		CodeChallengeMethodsSupported: s.challengeMethods,
+	})
+}
+
+func (s *authServer) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var registration struct {
+		RedirectURIs []string `json:"redirect_uris"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil || len(registration.RedirectURIs) != 1 {
+		http.Error(w, "invalid registration", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.registrations++
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"client_id": "ocstack-test"})
+}
+
+func (s *authServer) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != "ocstack-test" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" ||
+		q.Get("resource") != s.URL+"/mcp" || q.Get("scope") != "mcp" {
+		http.Error(w, "invalid authorization request: "+r.URL.RawQuery, http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.challenge = q.Get("code_challenge")
+	s.redirectURI = q.Get("redirect_uri")
+	s.code = fmt.Sprintf("code-%d", s.issued)
+	code := s.code
+	s.mu.Unlock()
+
+	redirect := q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (s *authServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("client_id") != "ocstack-test" {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant := r.PostForm.Get("grant_type")
+	s.grants = append(s.grants, grant)
+	switch grant {
+	case "authorization_code":
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != s.code || s.code == "" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != s.challenge ||
+			r.PostForm.Get("redirect_uri") != s.redirectURI {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		// codes are single use
+		s.code = ""
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") != s.refreshToken || s.refreshToken == "" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("resource") != s.URL+"/mcp" {
+		http.Error(w, `{"error":"invalid_target"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.issued++
+	s.accessToken = fmt.Sprintf("access-%d", s.issued)
+	s.refreshToken = fmt.Sprintf("refresh-%d", s.issued)
+	json.NewEncoder(w).Encode(OAuthToken{
+		AccessToken:  s.accessToken,
+		TokenType:    "Bearer",
+		RefreshToken: s.refreshToken,
+		ExpiresIn:    3600,
+	})
+}
+
+func (s *authServer) handleMCP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	authorized := s.accessToken != "" && r.Header.Get("Authorization") == "Bearer "+s.accessToken
+	if !authorized {
+		s.unauthorized++
+	}
+	s.mu.Unlock()
+	if !authorized {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+			`Bearer resource_metadata="%s/.well-known/oauth-protected-resource/mcp", scope="mcp"`, s.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+}
+
+// newTestAuthenticator returns an authenticator completing the user step by
+// following the authorization URL, like a browser would
+func newTestAuthenticator(t *testing.T, s *authServer) *OAuthAuthenticator {
+	auth := NewOAuthAuthenticator(s.URL+"/mcp", OAuthConfig{
+		TokenFile: filepath.Join(t.TempDir(), "tokens.json"),
+		OpenURL: func(authURL string) error {
+			resp, err := http.Get(authURL)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("authorization returned %s", resp.Status)
+			}
+			return nil
+		},
+	}, s.Client())
+	return auth
+}
+
+func TestHTTPTransportAuthorizesOn401(t *testing.T) {
+	s := newAuthServer(t)
+	auth := newTestAuthenticator(t, s)
+
+	transport := NewHTTPTransport(s.URL+"/mcp", 5*time.Second)
+	transport.SetAuthenticator(auth)
+	ctx := context.Background()
+	if err := transport.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Disconnect()
+
+	if err := transport.WriteMessage(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	message, err := transport.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(message), `"result"`) {
+		t.Fatalf("unexpected response %s", message)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unauthorized != 1 {
+		t.Errorf("got %d unauthorized requests, want 1", s.unauthorized)
+	}
+	if s.registrations != 1 {
+		t.Errorf("got %d client registrations, want 1", s.registrations)
+	}
+	if len(s.grants) != 1 || s.grants[0] != "authorization_code" {
+		t.Errorf("got grants %v, want [authorization_code]", s.grants)
+	}
+
+	// The token is persisted along with the client
+	stored, err := NewFileTokenStore(auth.config.TokenFile).Load(s.URL + "/mcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || stored.ClientID != "ocstack-test" || stored.Token == nil || stored.Token.AccessToken != s.accessToken {
+		t.Errorf("unexpected stored credentials %+v", stored)
+	}
+}
+
+func TestOAuthTokenRefresh(t *testing.T) {
+	s := newAuthServer(t)
+	auth := newTestAuthenticator(t, s)
+	var output strings.Builder
+	auth.config.Output = &output
+	ctx := context.Background()
+
+	if err := auth.Authorize(ctx, ""); err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	token, err := auth.Token(ctx)
+	if err != nil || token != "access-1" {
+		t.Fatalf("got token %q, %v, want access-1", token, err)
+	}
+
+	// Expire the access token, the refresh token is used
+	auth.mu.Lock()
+	auth.credentials.Token.Expiry = time.Now().Add(-time.Minute)
+	auth.mu.Unlock()
+	token, err = auth.Token(ctx)
+	if err != nil || token != "access-2" {
+		t.Fatalf("got token %q, %v, want access-2", token, err)
+	}
+	s.mu.Lock()
+	grants := append([]string{}, s.grants...)
+	s.mu.Unlock()
+	if len(grants) != 2 || grants[1] != "refresh_token" {
+		t.Errorf("got grants %v, want [authorization_code refresh_token]", grants)
+	}
+
+	// A rejected refresh drops the token, the next 401 authorizes again
+	auth.mu.Lock()
+	auth.credentials.Token.Expiry = time.Now().Add(-time.Minute)
+	auth.credentials.Token.RefreshToken = "revoked"
+	auth.mu.Unlock()
+	token, err = auth.Token(ctx)
+	if err != nil || token != "" {
+		t.Fatalf("got token %q, %v, want none", token, err)
+	}
+	if !strings.Contains(output.String(), "failed to refresh MCP access token") {
+		t.Errorf("got output %q, want the refresh warning", output.String())
+	}
+}
+
+func TestAuthorizationURLOutput(t *testing.T) {
+	var output strings.Builder
+	auth := NewOAuthAuthenticator("https://mcp.example.com", OAuthConfig{Output: &output}, nil)
+	if err := auth.printAuthorizationURL("https://as.example.com/authorize?state=1"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(output.String(), "\nhttps://as.example.com/authorize?state=1\n") {
+		t.Errorf("got output %q, want the authorization URL", output.String())
+	}
+
+	// The authenticators of the client print to its Output
+	client := NewClient(MCPConfig{ServerURL: "https://mcp.example.com", OAuth: &OAuthConfig{}, Output: &output})
+	if oauth, ok := newAuthenticator(client.config, nil).(*OAuthAuthenticator); !ok || oauth.config.Output != &output {
+		t.Error("the authenticator does not print to the Output of the client")
+	}
+}
+
+func TestOAuthDiscovery(t *testing.T) {
+	tests := []struct {
+		name             string
+		challenge        string
+		resourceMetadata bool
+		challengeMethods []string
+		wantErr          string
+	}{
+		{
+			name:             "metadata URL from the challenge",
+			challenge:        `Bearer resource_metadata="%s/.well-known/oauth-protected-resource/mcp"`,
+			resourceMetadata: true,
+			challengeMethods: []string{"S256"},
+		},
+		{
+			name:             "well-known resource metadata",
+			resourceMetadata: true,
+			challengeMethods: []string{"plain", "S256"},
+		},
+		{
+			name:             "PKCE S256 not supported",
+			resourceMetadata: true,
+			challengeMethods: []string{"plain"},
+			wantErr:          "does not support PKCE S256",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAuthServer(t)
+			s.resourceMetadata = tt.resourceMetadata
+			s.challengeMethods = tt.challengeMethods
+			auth := newTestAuthenticator(t, s)
+
+			challenge := tt.challenge
+			if strings.Contains(challenge, "%s") {
+				challenge = fmt.Sprintf(challenge, s.URL)
+			}
+			err := auth.Authorize(context.Background(), challenge)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Authorize: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChallengeParam(t *testing.T) {
+	challenge := `Bearer error="invalid_token", resource_metadata="https://mcp.example.com/.well-known/oauth-protected-resource", scope="read write"`
+	for name, want := range map[string]string{
+		"resource_metadata": "https://mcp.example.com/.well-known/oauth-protected-resource",
+		"scope":             "read write",
+		"error":             "invalid_token",
+		"realm":             "",
+	} {
+		if got := challengeParam(challenge, name); got != want {
+			t.Errorf("challengeParam(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package mcp
+
+import (
+	"sync"
+	"time"
+)
+
+// maxListPages bounds the number of pages fetched by a list request, so a
+// misbehaving server can't keep the client looping on cursors
+const maxListPages = 100
+
+// ToolCatalog is a cached and indexed view of the tools exposed by a server.
+// It is filled on connect, refreshed on list_changed notifications or on
+// demand, and serves tool lookups without network round trips
+type ToolCatalog struct {
+	mu        sync.RWMutex
+	tools     []MCPTool
+	index     map[string]int
+	updatedAt time.Time
+}
+
+// NewToolCatalog creates an empty catalog
+func NewToolCatalog() *ToolCatalog {
+	return &ToolCatalog{
+		index: make(map[string]int),
+	}
+}
+
+// Set replaces the content of the catalog
+func (t *ToolCatalog) Set(tools []MCPTool) {
+	index := make(map[string]int, len(tools))
+	for i, tool := range tools {
+		index[tool.Name] = i
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tools = tools
+	t.index = index
+	t.updatedAt = time.Now()
+}
+
+// Get returns the tool with the given name
+func (t *ToolCatalog) Get(name string) (*MCPTool, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	i, exists := t.index[name]
+	if !exists {
+		return nil, false
+	}
+	tool := t.tools[i]
+	return &tool, true
+}
+
+// Has returns true if the catalog contains the given tool
+func (t *ToolCatalog) Has(name string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, exists := t.index[name]
+	return exists
+}
+
+// List returns a copy of the tools, in the order returned by the server
+func (t *ToolCatalog) List() []MCPTool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]MCPTool(nil), t.tools...)
+}
+
+// Len returns the number of tools in the catalog
+func (t *ToolCatalog) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.tools)
+}
+
+// UpdatedAt returns the time of the last refresh
+func (t *ToolCatalog) UpdatedAt() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.updatedAt
+}
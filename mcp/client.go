@@ -1,17 +1,17 @@
 package mcp
 
 import (
-	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io"
-	"os/exec"
+	"os"
 	"strings"
 	"sync"
 	"time"
 )
 
+
 // Client interface defines the MCP client operations
 type Client interface {
 	Connect(ctx context.Context) error
@@ -19,7 +19,20 @@ type Client interface {
 	ListTools(ctx context.Context) ([]MCPTool, error)
 	CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResponse, error)
 	GetAvailableTools() []byte // Returns tools in the format expected by your existing system
+	RefreshTools(ctx context.Context) error
+	HasTool(name string) bool
+	GetTool(name string) (*MCPTool, bool)
+	ListPrompts(ctx context.Context) ([]Prompt, error)
+	GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResponse, error)
+	GetAvailablePrompts() []Prompt
+	Complete(ctx context.Context, ref CompletionReference, argument CompletionArgument, context map[string]string) (*Completion, error)
+	SetLogLevel(ctx context.Context, level string) error
+	SetRoots(ctx context.Context, roots []Root) error
+	ToolTimeout(name string) time.Duration
+	ProtocolVersion() string
+	Supports(feature ProtocolFeature) bool
 	IsConnected() bool
+	SetTracer(tracer *Tracer)
 }
 
 // MCPClient implements the MCP client
@@ -28,15 +41,35 @@ type MCPClient struct {
 	state        ConnectionState
 	serverInfo   *ServerInfo
 	capabilities *ServerCapabilities
-	tools        []MCPTool
+	// protocol revision negotiated during initialize
+	protocolVersion string
+	// capabilities advertised to the server during initialize
+	clientCapabilities ClientCapabilities
+	catalog      *ToolCatalog
+	prompts      []Prompt
 	
 	// Transport abstraction
 	transport Transport
 	
-	// JSON-RPC for stdio transport
+	// JSON-RPC session of the current connection
 	requestID int
-	responses map[interface{}]chan JSONRPCResponse
+	conn      *Conn
 	mu        sync.RWMutex
+
+	// Server notifications
+	notificationHandlers  map[string]NotificationHandler
+	toolsChangedCallbacks []func()
+	progressTokens        map[string]string
+
+	// Server requests
+	requestHandlers    map[string]RequestHandler
+	samplingHandler    SamplingHandler
+	elicitationHandler ElicitationHandler
+
+	reconnecting bool
+
+	// traces the JSON-RPC frames of the connection
+	trace traceHook
 	
 	// Context and cancellation
 	ctx    context.Context
@@ -51,88 +84,179 @@ func NewClient(config MCPConfig) *MCPClient {
 	if config.MaxRetries == 0 {
 		config.MaxRetries = 3
 	}
+	if config.Output == nil {
+		config.Output = os.Stderr
+	}
 	
 	// Set default transport if not specified
 	if config.Transport == "" {
-		if config.ServerURL != "" {
+		if config.InProcess != nil {
+			config.Transport = TransportInProcess
+		} else if config.ServerURL != "" {
 			config.Transport = TransportHTTP
 		} else {
 			config.Transport = TransportStdio
 		}
 	}
 	
-	return &MCPClient{
-		config:    config,
-		state:     StateDisconnected,
-		responses: make(map[interface{}]chan JSONRPCResponse),
+	c := &MCPClient{
+		config:               config,
+		state:                StateDisconnected,
+		catalog:              NewToolCatalog(),
+		notificationHandlers: make(map[string]NotificationHandler),
+		progressTokens:       make(map[string]string),
+		requestHandlers:      make(map[string]RequestHandler),
 	}
+	c.registerDefaultNotificationHandlers()
+	c.registerDefaultRequestHandlers()
+	return c
 }
 
 // Connect establishes connection to the MCP server
-func (c *MCPClient) Connect(ctx context.Context) error {
+func (c *MCPClient) Connect(ctx context.Context) (err error) {
+	c.mu.Lock()
 	if c.state != StateDisconnected {
+		c.mu.Unlock()
 		return fmt.Errorf("client already connected or connecting")
 	}
-	
-	c.setState(StateConnecting)
-	
-	// Create context with timeout
-	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.state = StateConnecting
+	// The context lives as long as the connection
+	var cancel context.CancelFunc
+	c.ctx, cancel = context.WithCancel(ctx)
+	c.cancel = cancel
+	c.mu.Unlock()
+
+	// Every failure releases the context, and the transport once connected
+	var connected Transport
+	defer func() {
+		if err != nil {
+			c.setState(StateDisconnected)
+			cancel()
+			if connected != nil {
+				connected.Disconnect()
+			}
+		}
+	}()
 	
 	// Create and connect transport
 	if err := c.createTransport(); err != nil {
-		c.setState(StateDisconnected)
 		return fmt.Errorf("failed to create transport: %w", err)
 	}
+	transport := c.currentTransport()
 	
-	if err := c.transport.Connect(c.ctx); err != nil {
-		c.setState(StateDisconnected)
+	if err := transport.Connect(c.ctx); err != nil {
 		return fmt.Errorf("failed to connect transport: %w", err)
 	}
+	connected = transport
 	
-	// Start message handling for stdio transport only
-	if c.config.Transport == TransportStdio {
-		go c.handleMessages()
-	}
+	c.startMessageLoop()
 	
 	// Initialize MCP protocol
 	if err := c.initialize(); err != nil {
-		c.setState(StateDisconnected)
 		return fmt.Errorf("failed to initialize MCP protocol: %w", err)
 	}
 	
 	c.setState(StateConnected)
+	c.syncServerState()
 	
+	return nil
+}
+
+// syncServerState fetches the tools and prompts and restores the log level,
+// after the connection has been initialized
+func (c *MCPClient) syncServerState() {
 	// List available tools after setting connected state
 	if err := c.refreshTools(); err != nil {
 		// Don't fail connection if tool listing fails, just log
-		fmt.Printf("Warning: failed to refresh tools: %v\n", err)
+		c.printf("Warning: failed to refresh tools: %v\n", err)
+	}
+
+	// Prompts are optional, only fetch them when the server supports them
+	if c.supportsPrompts() {
+		if err := c.refreshPrompts(); err != nil {
+			c.printf("Warning: failed to refresh prompts: %v\n", err)
+		}
+	}
+
+	// Ask the server to only send the log messages we are going to show
+	if c.config.LogLevel != "" {
+		if err := c.SetLogLevel(c.ctx, c.config.LogLevel); err != nil {
+			c.printf("Warning: failed to set log level: %v\n", err)
+		}
 	}
-	
-	return nil
 }
 
 // Disconnect closes the connection to the MCP server
 func (c *MCPClient) Disconnect() error {
+	c.mu.Lock()
 	if c.state == StateDisconnected || c.state == StateClosed {
+		c.mu.Unlock()
 		return nil
 	}
+	c.state = StateClosed
+	cancel, transport := c.cancel, c.transport
+	c.mu.Unlock()
 	
-	c.setState(StateClosed)
-	
-	// Cancel context
-	if c.cancel != nil {
-		c.cancel()
+	// Cancel context, which stops the reconnection attempts
+	if cancel != nil {
+		cancel()
 	}
 	
 	// Disconnect transport
-	if c.transport != nil {
-		c.transport.Disconnect()
+	if transport != nil {
+		transport.Disconnect()
 	}
 	
 	return nil
 }
 
+// SetRoots replaces the roots returned to the server on roots/list, and
+// notifies the server of the change when connected
+func (c *MCPClient) SetRoots(ctx context.Context, roots []Root) error {
+	c.mu.Lock()
+	c.config.Roots = append([]Root{}, roots...)
+	c.mu.Unlock()
+	conn := c.currentConn()
+	if conn == nil || !c.IsConnected() {
+		return nil
+	}
+	err := conn.Notify(ctx, JSONRPCRequest{
+		JSONRpc: "2.0",
+		Method:  NotificationRootsListChanged,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	return nil
+}
+
+// SetTracer starts tracing the frames exchanged with the server, along with
+// the transport details (HTTP headers, stderr of stdio servers). A nil
+// tracer stops tracing. It can be called before Connect to trace the
+// initialization
+func (c *MCPClient) SetTracer(tracer *Tracer) {
+	c.trace.SetTracer(tracer)
+	c.mu.RLock()
+	transport := c.transport
+	c.mu.RUnlock()
+	if traced, ok := transport.(interface{ SetTracer(*Tracer) }); ok {
+		traced.SetTracer(tracer)
+	}
+}
+
+// ProtocolVersion returns the protocol revision negotiated with the server
+func (c *MCPClient) ProtocolVersion() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.protocolVersion
+}
+
+// Supports returns true if the feature is available with the negotiated
+// protocol revision
+func (c *MCPClient) Supports(feature ProtocolFeature) bool {
+	return VersionSupports(c.ProtocolVersion(), feature)
+}
+
 // IsConnected returns true if the client is connected
 func (c *MCPClient) IsConnected() bool {
 	c.mu.RLock()
@@ -140,39 +264,76 @@ func (c *MCPClient) IsConnected() bool {
 	return c.state == StateConnected
 }
 
-// ListTools returns the available tools from the MCP server
+// ListTools returns the available tools from the MCP server, following the
+// pagination cursors until the last page
 func (c *MCPClient) ListTools(ctx context.Context) ([]MCPTool, error) {
 	if !c.IsConnected() {
 		return nil, fmt.Errorf("client not connected")
 	}
 	
-	request := JSONRPCRequest{
-		JSONRpc: "2.0",
-		ID:      c.nextRequestID(),
-		Method:  "tools/list",
-		Params:  ListToolsRequest{},
-	}
-	
-	response, err := c.sendRequest(ctx, request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send tools/list request: %w", err)
-	}
-	
-	if response.Error != nil {
-		return nil, fmt.Errorf("tools/list failed: %s", response.Error.Message)
+	var tools []MCPTool
+	cursor := ""
+	for page := 0; page < maxListPages; page++ {
+		request := JSONRPCRequest{
+			JSONRpc: "2.0",
+			ID:      c.nextRequestID(),
+			Method:  "tools/list",
+			Params:  ListToolsRequest{Cursor: cursor},
+		}
+		
+		response, err := c.sendRequest(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send tools/list request: %w", err)
+		}
+		
+		if response.Error != nil {
+			return nil, fmt.Errorf("tools/list failed: %s", response.Error.Message)
+		}
+		
+		var toolsResponse ListToolsResponse
+		resultBytes, err := json.Marshal(response.Result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal tools response: %w", err)
+		}
+		
+		if err := json.Unmarshal(resultBytes, &toolsResponse); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tools response: %w", err)
+		}
+		
+		tools = append(tools, toolsResponse.Tools...)
+		if toolsResponse.NextCursor == "" || toolsResponse.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = toolsResponse.NextCursor
 	}
 	
-	var toolsResponse ListToolsResponse
-	resultBytes, err := json.Marshal(response.Result)
+	return nil, fmt.Errorf("tools/list returned more than %d pages", maxListPages)
+}
+
+// RefreshTools fetches the tool list from the server and updates the catalog
+func (c *MCPClient) RefreshTools(ctx context.Context) error {
+	tools, err := c.ListTools(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal tools response: %w", err)
-	}
-	
-	if err := json.Unmarshal(resultBytes, &toolsResponse); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal tools response: %w", err)
+		return err
 	}
-	
-	return toolsResponse.Tools, nil
+	c.catalog.Set(tools)
+	return nil
+}
+
+// HasTool returns true if the server exposes the given tool. It is served by
+// the catalog, no request is sent to the server
+func (c *MCPClient) HasTool(name string) bool {
+	return c.catalog.Has(name)
+}
+
+// GetTool returns the cached definition of the given tool
+func (c *MCPClient) GetTool(name string) (*MCPTool, bool) {
+	return c.catalog.Get(name)
+}
+
+// Catalog returns the tool catalog of the connection
+func (c *MCPClient) Catalog() *ToolCatalog {
+	return c.catalog
 }
 
 // CallTool executes a tool on the MCP server
@@ -181,15 +342,35 @@ func (c *MCPClient) CallTool(ctx context.Context, name string, args map[string]i
 		return nil, fmt.Errorf("client not connected")
 	}
 	
+	// Never wait forever for a tool
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.ToolTimeout(name))
+		defer cancel()
+	}
+	
+	// The request id doubles as progress token, so progress notifications
+	// can be associated to the running tool
+	id := c.nextRequestID()
 	request := JSONRPCRequest{
 		JSONRpc: "2.0",
-		ID:      c.nextRequestID(),
+		ID:      id,
 		Method:  "tools/call",
 		Params: CallToolRequest{
 			Name:      name,
 			Arguments: args,
+			Meta:      &RequestMeta{ProgressToken: id},
 		},
 	}
+
+	c.mu.Lock()
+	c.progressTokens[idKey(id)] = name
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.progressTokens, idKey(id))
+		c.mu.Unlock()
+	}()
 	
 	response, err := c.sendRequest(ctx, request)
 	if err != nil {
@@ -213,14 +394,149 @@ func (c *MCPClient) CallTool(ctx context.Context, name string, args map[string]i
 	return &callResponse, nil
 }
 
-// GetAvailableTools returns tools in the format expected by your existing system
-func (c *MCPClient) GetAvailableTools() []byte {
+// ListPrompts returns the prompts exposed by the MCP server, following the
+// pagination cursors until the last page
+func (c *MCPClient) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	if !c.IsConnected() {
+		return nil, fmt.Errorf("client not connected")
+	}
+
+	var prompts []Prompt
+	cursor := ""
+	for page := 0; page < maxListPages; page++ {
+		request := JSONRPCRequest{
+			JSONRpc: "2.0",
+			ID:      c.nextRequestID(),
+			Method:  "prompts/list",
+			Params:  ListPromptsRequest{Cursor: cursor},
+		}
+
+		response, err := c.sendRequest(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send prompts/list request: %w", err)
+		}
+
+		if response.Error != nil {
+			return nil, fmt.Errorf("prompts/list failed: %s", response.Error.Message)
+		}
+
+		var promptsResponse ListPromptsResponse
+		resultBytes, err := json.Marshal(response.Result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal prompts response: %w", err)
+		}
+
+		if err := json.Unmarshal(resultBytes, &promptsResponse); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal prompts response: %w", err)
+		}
+
+		prompts = append(prompts, promptsResponse.Prompts...)
+		if promptsResponse.NextCursor == "" || promptsResponse.NextCursor == cursor {
+			return prompts, nil
+		}
+		cursor = promptsResponse.NextCursor
+	}
+
+	return nil, fmt.Errorf("prompts/list returned more than %d pages", maxListPages)
+}
+
+// GetPrompt renders a prompt on the MCP server with the given arguments
+func (c *MCPClient) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResponse, error) {
+	if !c.IsConnected() {
+		return nil, fmt.Errorf("client not connected")
+	}
+
+	request := JSONRPCRequest{
+		JSONRpc: "2.0",
+		ID:      c.nextRequestID(),
+		Method:  "prompts/get",
+		Params: GetPromptRequest{
+			Name:      name,
+			Arguments: args,
+		},
+	}
+
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send prompts/get request: %w", err)
+	}
+
+	if response.Error != nil {
+		return nil, fmt.Errorf("prompts/get failed: %s", response.Error.Message)
+	}
+
+	var promptResponse GetPromptResponse
+	resultBytes, err := json.Marshal(response.Result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal prompt response: %w", err)
+	}
+
+	if err := json.Unmarshal(resultBytes, &promptResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal prompt response: %w", err)
+	}
+
+	return &promptResponse, nil
+}
+
+// Complete asks the server the values suggested for an argument of a prompt
+// or resource template, given its partial value. The arguments already
+// provided are sent as context when the protocol version supports it
+func (c *MCPClient) Complete(ctx context.Context, ref CompletionReference, argument CompletionArgument, context map[string]string) (*Completion, error) {
+	if !c.IsConnected() {
+		return nil, fmt.Errorf("client not connected")
+	}
+	if !c.supportsCompletions() {
+		return nil, fmt.Errorf("the MCP server does not support completions")
+	}
+
+	params := CompleteRequest{
+		Ref:      ref,
+		Argument: argument,
+	}
+	if len(context) > 0 && c.Supports(FeatureCompletionContext) {
+		params.Context = &CompletionContext{Arguments: context}
+	}
+	request := JSONRPCRequest{
+		JSONRpc: "2.0",
+		ID:      c.nextRequestID(),
+		Method:  "completion/complete",
+		Params:  params,
+	}
+
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send completion/complete request: %w", err)
+	}
+
+	if response.Error != nil {
+		return nil, fmt.Errorf("completion/complete failed: %s", response.Error.Message)
+	}
+
+	var completeResponse CompleteResponse
+	resultBytes, err := json.Marshal(response.Result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal completion response: %w", err)
+	}
+
+	if err := json.Unmarshal(resultBytes, &completeResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal completion response: %w", err)
+	}
+
+	return &completeResponse.Completion, nil
+}
+
+// GetAvailablePrompts returns the prompts cached at connection time
+func (c *MCPClient) GetAvailablePrompts() []Prompt {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	
+	return append([]Prompt(nil), c.prompts...)
+}
+
+// GetAvailableTools returns tools in the format expected by your existing system
+func (c *MCPClient) GetAvailableTools() []byte {
 	var convertedTools []Tool
 	
-	for _, mcpTool := range c.tools {
+	for _, mcpTool := range c.catalog.List() {
 		tool := Tool{
 			Type: "function",
 			Function: &Function{
@@ -234,15 +550,79 @@ func (c *MCPClient) GetAvailableTools() []byte {
 	
 	data, err := json.Marshal(convertedTools)
 	if err != nil {
-		fmt.Printf("Error marshaling tools: %v\n", err)
+		c.printf("Error marshaling tools: %v\n", err)
 		return []byte("[]")
 	}
 	
 	return data
 }
 
+// SendBatch sends several requests and notifications (requests without ID)
+// and returns the responses in the same order, nil for notifications. They
+// are sent as a single JSON-RPC batch when the negotiated protocol revision
+// allows it, one by one otherwise
+func (c *MCPClient) SendBatch(ctx context.Context, requests []JSONRPCRequest) ([]*JSONRPCResponse, error) {
+	if !c.IsConnected() {
+		return nil, fmt.Errorf("client not connected")
+	}
+
+	if c.Supports(FeatureBatching) {
+		if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+			defer cancel()
+		}
+		conn := c.currentConn()
+		if conn == nil {
+			return nil, &TransportError{Err: fmt.Errorf("not connected")}
+		}
+		return conn.Batch(ctx, requests)
+	}
+
+	responses := make([]*JSONRPCResponse, len(requests))
+	for i, request := range requests {
+		if request.ID == nil {
+			if err := c.sendNotification(request); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		response, err := c.sendRequest(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		responses[i] = response
+	}
+	return responses, nil
+}
+
+// NewRequest returns a request with the next free ID, to be used with
+// SendBatch
+func (c *MCPClient) NewRequest(method string, params interface{}) JSONRPCRequest {
+	return JSONRPCRequest{
+		JSONRpc: "2.0",
+		ID:      c.nextRequestID(),
+		Method:  method,
+		Params:  params,
+	}
+}
+
 // Private methods
 
+// printf writes a message for the user to the configured Output
+func (c *MCPClient) printf(format string, args ...any) {
+	fmt.Fprintf(c.config.Output, format, args...)
+}
+
+// tlsConfig returns the TLS settings of the remote transports
+func (c *MCPClient) tlsConfig() (*tls.Config, error) {
+	config, err := c.config.TLS.ClientConfig()
+	if err == nil && c.config.TLS != nil && c.config.TLS.InsecureSkipVerify {
+		c.printf("Warning: TLS certificate verification disabled for the MCP server\n")
+	}
+	return config, err
+}
+
 func (c *MCPClient) setState(state ConnectionState) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -250,12 +630,21 @@ func (c *MCPClient) setState(state ConnectionState) {
 }
 
 func (c *MCPClient) createTransport() error {
+	var t Transport
 	switch c.config.Transport {
 	case TransportHTTP:
 		if c.config.ServerURL == "" {
 			return fmt.Errorf("ServerURL required for HTTP transport")
 		}
-		c.transport = NewHTTPTransport(c.config.ServerURL, c.config.Timeout)
+		tlsConfig, err := c.tlsConfig()
+		if err != nil {
+			return err
+		}
+		transport := NewHTTPTransport(c.config.ServerURL, c.config.Timeout)
+		transport.SetTLSConfig(tlsConfig)
+		transport.SetHeaders(c.config.Headers)
+		transport.SetAuthenticator(newAuthenticator(c.config, transport.HTTPClient()))
+		t = transport
 		
 	case TransportWebSocket:
 		if c.config.ServerURL == "" {
@@ -268,131 +657,79 @@ func (c *MCPClient) createTransport() error {
 		} else if strings.HasPrefix(wsURL, "https://") {
 			wsURL = strings.Replace(wsURL, "https://", "wss://", 1)
 		}
-		c.transport = NewWebSocketTransport(wsURL)
+		tlsConfig, err := c.tlsConfig()
+		if err != nil {
+			return err
+		}
+		transport := NewWebSocketTransport(wsURL)
+		transport.SetOutput(c.config.Output)
+		transport.SetTLSConfig(tlsConfig)
+		transport.SetHeaders(c.config.Headers)
+		transport.SetAuthenticator(newAuthenticator(c.config, newHTTPClient(tlsConfig)))
+		t = transport
 		
 	case TransportStdio:
 		if len(c.config.Command) == 0 {
 			return fmt.Errorf("Command required for stdio transport")
 		}
-		// For stdio, we'll create a legacy transport that wraps the existing logic
-		return c.createStdioTransport()
+		transport := NewStdioTransport(c.config.Command, c.config.Env)
+		transport.SetProcessConfig(c.config.Process)
+		t = transport
+
+	case TransportInProcess:
+		if c.config.InProcess == nil {
+			return fmt.Errorf("InProcess server required for in-process transport")
+		}
+		t = NewInProcessTransport(c.config.InProcess)
 		
 	default:
 		return fmt.Errorf("unsupported transport type: %s", c.config.Transport)
 	}
-	
-	return nil
-}
 
-func (c *MCPClient) createStdioTransport() error {
-	// This is a temporary implementation - the stdio logic should be properly 
-	// moved to StdioTransport struct
-	cmd := exec.CommandContext(c.ctx, c.config.Command[0], c.config.Command[1:]...)
-	
-	// Set environment variables
-	if c.config.Env != nil {
-		for key, value := range c.config.Env {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
-		}
-	}
-	
-	var err error
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe: %w", err)
-	}
-	
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
-	}
-	
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
-	}
-	
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start command: %w", err)
-	}
-	
-	// Create a simple stdio transport wrapper
-	c.transport = &stdioTransportWrapper{
-		cmd:    cmd,
-		stdin:  stdin,
-		stdout: stdout,
-		stderr: stderr,
+	if traced, ok := t.(interface{ SetTracer(*Tracer) }); ok {
+		traced.SetTracer(c.trace.current())
 	}
-	
-	return nil
-}
 
-// Temporary wrapper for stdio transport
-type stdioTransportWrapper struct {
-	cmd    *exec.Cmd
-	stdin  io.WriteCloser
-	stdout io.ReadCloser
-	stderr io.ReadCloser
-}
-
-func (s *stdioTransportWrapper) Connect(ctx context.Context) error {
-	// Already connected when created
+	c.mu.Lock()
+	c.transport = t
+	c.mu.Unlock()
 	return nil
 }
 
-func (s *stdioTransportWrapper) Disconnect() error {
-	if s.stdin != nil {
-		s.stdin.Close()
-	}
-	if s.stdout != nil {
-		s.stdout.Close()
-	}
-	if s.stderr != nil {
-		s.stderr.Close()
+
+func (c *MCPClient) initialize() error {
+	c.setState(StateInitializing)
+	
+	capabilities := ClientCapabilities{
+		Roots: &RootsCapability{
+			ListChanged: true,
+		},
 	}
-	if s.cmd != nil && s.cmd.Process != nil {
-		s.cmd.Process.Kill()
-		s.cmd.Wait()
+	c.mu.RLock()
+	if c.samplingHandler != nil {
+		capabilities.Sampling = &SamplingCapability{}
 	}
-	return nil
-}
+	elicitation := c.elicitationHandler != nil
+	c.mu.RUnlock()
 
-func (s *stdioTransportWrapper) Send(request JSONRPCRequest) error {
-	requestBytes, err := json.Marshal(request)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+	offered := c.config.ProtocolVersion
+	if offered == "" {
+		offered = LatestProtocolVersion
 	}
-	
-	if _, err := s.stdin.Write(append(requestBytes, '\n')); err != nil {
-		return fmt.Errorf("failed to write request: %w", err)
+	if !IsSupportedProtocolVersion(offered) {
+		return fmt.Errorf("unsupported MCP protocol version %s", offered)
+	}
+	if elicitation && VersionSupports(offered, FeatureElicitation) {
+		capabilities.Elicitation = &ElicitationCapability{}
 	}
-	return nil
-}
-
-func (s *stdioTransportWrapper) Receive() (*JSONRPCResponse, error) {
-	// This should not be used directly for stdio
-	return nil, fmt.Errorf("stdio transport uses async messaging")
-}
-
-func (s *stdioTransportWrapper) IsConnected() bool {
-	return s.cmd != nil && s.cmd.Process != nil
-}
-
-
-func (c *MCPClient) initialize() error {
-	c.setState(StateInitializing)
 	
 	request := JSONRPCRequest{
 		JSONRpc: "2.0",
 		ID:      c.nextRequestID(),
 		Method:  "initialize",
 		Params: InitializeRequest{
-			ProtocolVersion: "2024-11-05",
-			Capabilities: ClientCapabilities{
-				Roots: &RootsCapability{
-					ListChanged: true,
-				},
-			},
+			ProtocolVersion: offered,
+			Capabilities:    capabilities,
 			ClientInfo: ClientInfo{
 				Name:    "ocstack-mcp-client",
 				Version: "1.0.0",
@@ -422,10 +759,23 @@ func (c *MCPClient) initialize() error {
 		return fmt.Errorf("failed to unmarshal initialize response: %w", err)
 	}
 	
+	// The server answers with the version it wants to use, which may be
+	// older than the offered one
+	version, err := negotiateVersion(offered, initResponse.ProtocolVersion)
+	if err != nil {
+		return err
+	}
+	
 	c.mu.Lock()
 	c.serverInfo = &initResponse.ServerInfo
 	c.capabilities = &initResponse.Capabilities
+	c.protocolVersion = version
+	c.clientCapabilities = capabilities
 	c.mu.Unlock()
+
+	if httpTransport, ok := c.currentTransport().(*HTTPTransport); ok {
+		httpTransport.SetProtocolVersion(version)
+	}
 	
 	// Send initialized notification
 	notification := JSONRPCRequest{
@@ -437,120 +787,155 @@ func (c *MCPClient) initialize() error {
 }
 
 func (c *MCPClient) refreshTools() error {
-	tools, err := c.ListTools(c.ctx)
+	return c.RefreshTools(c.ctx)
+}
+
+func (c *MCPClient) refreshPrompts() error {
+	prompts, err := c.ListPrompts(c.ctx)
 	if err != nil {
 		return err
 	}
-	
+
 	c.mu.Lock()
-	c.tools = tools
+	c.prompts = prompts
 	c.mu.Unlock()
-	
+
 	return nil
 }
 
-func (c *MCPClient) handleMessages() {
-	// Get stdout from the stdio transport wrapper
-	var stdout io.ReadCloser
-	if stdioWrapper, ok := c.transport.(*stdioTransportWrapper); ok {
-		stdout = stdioWrapper.stdout
-	} else {
-		fmt.Printf("Error: handleMessages called on non-stdio transport\n")
-		return
-	}
-	
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
-		}
-		
-		var response JSONRPCResponse
-		if err := json.Unmarshal([]byte(line), &response); err != nil {
-			fmt.Printf("Failed to parse JSON-RPC response: %v\n", err)
-			continue
-		}
-		
-		c.mu.RLock()
-		ch, exists := c.responses[response.ID]
-		c.mu.RUnlock()
-		
-		if exists {
-			select {
-			case ch <- response:
-			case <-c.ctx.Done():
-				return
-			}
-		}
-	}
+func (c *MCPClient) supportsPrompts() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.capabilities != nil && c.capabilities.Prompts != nil
 }
 
-func (c *MCPClient) sendRequest(ctx context.Context, request JSONRPCRequest) (*JSONRPCResponse, error) {
-	switch c.config.Transport {
-	case TransportHTTP:
-		// For HTTP, use synchronous request/response
-		if httpTransport, ok := c.transport.(*HTTPTransport); ok {
-			return httpTransport.SendRequest(ctx, request)
-		}
-		return nil, fmt.Errorf("HTTP transport not properly initialized")
-		
-	case TransportWebSocket, TransportStdio:
-		// For WebSocket and stdio, use async messaging
-		return c.sendAsyncRequest(ctx, request)
-		
-	default:
-		return nil, fmt.Errorf("unsupported transport type: %s", c.config.Transport)
+// supportsCompletions returns true if completion/complete can be sent. The
+// capability is only advertised since 2025-03-26, older servers are tried
+func (c *MCPClient) supportsCompletions() bool {
+	if !c.Supports(FeatureCompletionsCapability) {
+		return true
 	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.capabilities != nil && c.capabilities.Completions != nil
 }
 
-func (c *MCPClient) sendAsyncRequest(ctx context.Context, request JSONRPCRequest) (*JSONRPCResponse, error) {
-	// Create response channel
-	responseCh := make(chan JSONRPCResponse, 1)
+// startMessageLoop starts the JSON-RPC session of the current connection
+func (c *MCPClient) startMessageLoop() {
+	conn := NewConn(tracedStream{c.currentTransport(), &c.trace}, clientRouter{c})
 	c.mu.Lock()
-	c.responses[request.ID] = responseCh
+	c.conn = conn
 	c.mu.Unlock()
-	
+
+	go func() {
+		conn.Run(c.ctx)
+		c.connectionLost(conn)
+	}()
+}
+
+// connectionLost is called when the session of a connection stops. Unless
+// the client is being closed, WebSocket connections are established again,
+// while a stdio server that exited leaves the client disconnected
+func (c *MCPClient) connectionLost(conn *Conn) {
+	c.mu.Lock()
+	ignore := c.conn != conn || c.reconnecting ||
+		c.state == StateClosed || c.state == StateDisconnected || c.ctx.Err() != nil
+	if !ignore {
+		c.state = StateConnecting
+		c.reconnecting = c.config.Transport == TransportWebSocket
+	}
+	c.mu.Unlock()
+	if ignore {
+		return
+	}
+
+	if c.config.Transport == TransportWebSocket {
+		c.printf("Warning: connection to the MCP server lost, reconnecting...\n")
+		go c.reconnect()
+		return
+	}
+
+	c.printf("Warning: the MCP server exited\n")
+	c.setState(StateDisconnected)
+	c.currentTransport().Disconnect()
+}
+
+// reconnect establishes the connection again and repeats the initialization,
+// with exponential backoff between attempts
+func (c *MCPClient) reconnect() {
+	attempts := c.config.MaxReconnects
+	if attempts == 0 {
+		attempts = DefaultMaxReconnects
+	}
 	defer func() {
 		c.mu.Lock()
-		delete(c.responses, request.ID)
+		c.reconnecting = false
 		c.mu.Unlock()
 	}()
-	
-	// Send request
-	if err := c.transport.Send(request); err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	
-	// Wait for response
-	select {
-	case response := <-responseCh:
-		return &response, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-c.ctx.Done():
-		return nil, c.ctx.Err()
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		select {
+		case <-time.After(c.backoff(attempt)):
+		case <-c.ctx.Done():
+			return
+		}
+
+		if err := c.currentTransport().Connect(c.ctx); err != nil {
+			c.printf("Warning: reconnection attempt %d/%d failed: %v\n", attempt, attempts, err)
+			continue
+		}
+		c.startMessageLoop()
+
+		if err := c.initialize(); err != nil {
+			c.printf("Warning: reconnection attempt %d/%d failed: %v\n", attempt, attempts, err)
+			c.setState(StateConnecting)
+			c.currentTransport().Disconnect()
+			continue
+		}
+		c.setState(StateConnected)
+		c.syncServerState()
+		c.printf("I :> Reconnected to the MCP server\n")
+		return
 	}
+
+	c.printf("Warning: unable to reconnect to the MCP server\n")
+	c.setState(StateDisconnected)
+	c.currentTransport().Disconnect()
+}
+
+// roundTrip sends a single request on the current connection and waits for
+// its response
+func (c *MCPClient) roundTrip(ctx context.Context, request JSONRPCRequest) (*JSONRPCResponse, error) {
+	conn := c.currentConn()
+	if conn == nil {
+		return nil, &TransportError{Err: fmt.Errorf("not connected")}
+	}
+	return conn.Call(ctx, request)
 }
 
 func (c *MCPClient) sendNotification(notification JSONRPCRequest) error {
-	switch c.config.Transport {
-	case TransportHTTP:
-		// For HTTP, we need to use the synchronous method
-		if httpTransport, ok := c.transport.(*HTTPTransport); ok {
-			ctx, cancel := context.WithTimeout(c.ctx, c.config.Timeout)
-			defer cancel()
-			_, err := httpTransport.SendRequest(ctx, notification)
-			return err
-		}
-		return fmt.Errorf("HTTP transport not properly initialized")
-	default:
-		// For other transports, use the async method
-		if err := c.transport.Send(notification); err != nil {
-			return fmt.Errorf("failed to send notification: %w", err)
-		}
-		return nil
+	conn := c.currentConn()
+	if conn == nil {
+		return fmt.Errorf("not connected")
 	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.config.Timeout)
+	defer cancel()
+	if err := conn.Notify(ctx, notification); err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	return nil
+}
+
+func (c *MCPClient) currentTransport() Transport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.transport
+}
+
+func (c *MCPClient) currentConn() *Conn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn
 }
 
 func (c *MCPClient) nextRequestID() int {
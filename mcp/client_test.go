@@ -0,0 +1,38 @@
+package mcp
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestConnectFailureCancelsContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		config MCPConfig
+	}{
+		{name: "transport not created", config: MCPConfig{Transport: TransportHTTP}},
+		{name: "transport not connected", config: MCPConfig{Command: []string{"ocstack-no-such-server"}}},
+		{
+			name: "initialize failed",
+			config: MCPConfig{InProcess: func(ctx context.Context, stream MessageStream) error {
+				return nil
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Output = io.Discard
+			client := NewClient(tt.config)
+			if err := client.Connect(context.Background()); err == nil {
+				t.Fatal("Connect() succeeded")
+			}
+			if client.ctx.Err() == nil {
+				t.Error("the context of the client is not cancelled")
+			}
+			if client.IsConnected() || client.state != StateDisconnected {
+				t.Errorf("state = %s, want disconnected", client.state)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Outcomes of the audited operations
+const (
+	OutcomeOK = "ok"
+	// the upstream tool returned a result with isError set
+	OutcomeToolError = "tool_error"
+	// the operation failed (transport, JSON-RPC error, timeout)
+	OutcomeError = "error"
+)
+
+// AuditEntry records an operation forwarded to an upstream server
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Upstream string    `json:"upstream"`
+	// tools/call, prompts/get or resources/read
+	Method string `json:"method"`
+	// Name exposed by the gateway, and the name of the tool or prompt (or
+	// the resource URI) on the upstream server
+	Name         string      `json:"name"`
+	UpstreamName string      `json:"upstreamName"`
+	Arguments    interface{} `json:"arguments,omitempty"`
+	DurationMs   int64       `json:"durationMs"`
+	Outcome      string      `json:"outcome"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// AuditLog writes one JSON entry per line. It is safe for concurrent use
+type AuditLog struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewAuditLog creates an audit trail written to w
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{encoder: json.NewEncoder(w)}
+}
+
+// Record appends an entry to the trail
+func (a *AuditLog) Record(entry AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.encoder.Encode(entry)
+}
@@ -0,0 +1,271 @@
+// Package gateway aggregates several MCP servers behind a single MCP server:
+// the tools, prompts and resources of the upstream servers are exposed with
+// prefixed names, and every forwarded operation is recorded in an audit
+// trail
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fmount/ocstack/mcp"
+	"github.com/fmount/ocstack/mcp/server"
+)
+
+// Config describes the upstream servers, by name
+type Config struct {
+	Upstreams map[string]Upstream `json:"upstreams"`
+}
+
+// Upstream is an MCP server exposed by the gateway
+type Upstream struct {
+	mcp.MCPConfig
+
+	// Prefix of the exposed tool and prompt names, joined with "_". The
+	// upstream name is used when nil, and names are kept as is when empty
+	Prefix *string `json:"prefix,omitempty"`
+
+	// Glob patterns (path.Match) of the upstream tools to expose, every
+	// tool is exposed when empty
+	AllowTools []string `json:"allowTools,omitempty"`
+}
+
+// LoadConfig reads a JSON configuration file
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gateway config: %w", err)
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse gateway config %s: %w", file, err)
+	}
+	if len(config.Upstreams) == 0 {
+		return nil, fmt.Errorf("no upstream server configured in %s", file)
+	}
+	return &config, nil
+}
+
+// Gateway exposes the upstream servers through a single server
+type Gateway struct {
+	server    *server.Server
+	upstreams []*upstream
+	audit     *AuditLog
+	logger    *log.Logger
+
+	// owner of every exposed tool name, to detect collisions
+	mu    sync.Mutex
+	owner map[string]string
+}
+
+type upstream struct {
+	name   string
+	config Upstream
+	client *mcp.MCPClient
+	// exposed names of the tools currently registered
+	tools []string
+}
+
+// New creates a gateway exposing the upstreams through a server with the
+// given name and version. Operations are recorded to audit
+func New(config Config, name string, version string, audit *AuditLog) *Gateway {
+	g := &Gateway{
+		server: server.New(name, version),
+		audit:  audit,
+		logger: log.New(os.Stderr, "["+name+"] ", log.LstdFlags),
+		owner:  make(map[string]string),
+	}
+
+	names := make([]string, 0, len(config.Upstreams))
+	for name := range config.Upstreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// The messages of the clients go to our log, stdout may carry the
+		// protocol
+		mcpConfig := config.Upstreams[name].MCPConfig
+		mcpConfig.Output = g.logger.Writer()
+		g.upstreams = append(g.upstreams, &upstream{
+			name:   name,
+			config: config.Upstreams[name],
+			client: mcp.NewClient(mcpConfig),
+		})
+	}
+	return g
+}
+
+// Server returns the server to serve over stdio or HTTP
+func (g *Gateway) Server() *server.Server {
+	return g.server
+}
+
+// Connect connects the upstream servers and registers their tools, prompts
+// and resources. Unreachable upstreams are skipped, an error is only
+// returned when none can be reached
+func (g *Gateway) Connect(ctx context.Context) error {
+	connected := 0
+	for _, u := range g.upstreams {
+		if err := u.client.Connect(ctx); err != nil {
+			g.logger.Printf("upstream %s unavailable: %v", u.name, err)
+			continue
+		}
+		connected++
+
+		g.registerTools(u)
+		u.client.OnToolsChanged(func() {
+			g.registerTools(u)
+		})
+		g.registerPrompts(u)
+		g.registerResources(ctx, u)
+	}
+	if connected == 0 {
+		return fmt.Errorf("no upstream MCP server available")
+	}
+	return nil
+}
+
+// Close disconnects the upstream servers
+func (g *Gateway) Close() {
+	for _, u := range g.upstreams {
+		u.client.Disconnect()
+	}
+}
+
+// exposedName returns the name of an upstream tool or prompt on the gateway
+func (u *upstream) exposedName(name string) string {
+	prefix := u.name
+	if u.config.Prefix != nil {
+		prefix = *u.config.Prefix
+	}
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// allowed returns true if the upstream tool can be exposed
+func (u *upstream) allowed(tool string) bool {
+	if len(u.config.AllowTools) == 0 {
+		return true
+	}
+	for _, pattern := range u.config.AllowTools {
+		if matched, _ := path.Match(pattern, tool); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// registerTools replaces the tools of the upstream with its current catalog
+func (g *Gateway) registerTools(u *upstream) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, name := range u.tools {
+		g.server.RemoveTool(name)
+		delete(g.owner, name)
+	}
+	u.tools = nil
+
+	for _, tool := range u.client.Catalog().List() {
+		if !u.allowed(tool.Name) {
+			continue
+		}
+		exposed := u.exposedName(tool.Name)
+		if owner, taken := g.owner[exposed]; taken {
+			g.logger.Printf("tool %s of %s hidden, the name is already used by %s", tool.Name, u.name, owner)
+			continue
+		}
+
+		upstreamName := tool.Name
+		tool.Name = exposed
+		g.server.AddTool(tool, func(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResponse, error) {
+			start := time.Now()
+			result, err := u.client.CallTool(ctx, upstreamName, args)
+			outcome := OutcomeOK
+			if err == nil && result.IsError {
+				outcome = OutcomeToolError
+			}
+			g.record(u, "tools/call", exposed, upstreamName, args, start, outcome, err)
+			return result, err
+		})
+		g.owner[exposed] = u.name
+		u.tools = append(u.tools, exposed)
+	}
+}
+
+func (g *Gateway) registerPrompts(u *upstream) {
+	for _, prompt := range u.client.GetAvailablePrompts() {
+		upstreamName := prompt.Name
+		prompt.Name = u.exposedName(prompt.Name)
+		exposed := prompt.Name
+		g.server.AddPrompt(prompt, func(ctx context.Context, args map[string]string) (*mcp.GetPromptResponse, error) {
+			start := time.Now()
+			result, err := u.client.GetPrompt(ctx, upstreamName, args)
+			g.record(u, "prompts/get", exposed, upstreamName, args, start, OutcomeOK, err)
+			return result, err
+		})
+	}
+}
+
+// registerResources exposes the resources and templates of the upstream
+// with their URIs, which identify them across servers
+func (g *Gateway) registerResources(ctx context.Context, u *upstream) {
+	if capabilities := u.client.Capabilities(); capabilities == nil || capabilities.Resources == nil {
+		return
+	}
+
+	read := func(ctx context.Context, uri string) (*mcp.ReadResourceResponse, error) {
+		start := time.Now()
+		result, err := u.client.ReadResource(ctx, uri)
+		g.record(u, "resources/read", uri, uri, nil, start, OutcomeOK, err)
+		return result, err
+	}
+
+	resources, err := u.client.ListResources(ctx)
+	if err != nil {
+		g.logger.Printf("failed to list the resources of %s: %v", u.name, err)
+	}
+	for _, resource := range resources {
+		g.server.AddResource(resource, read)
+	}
+
+	templates, err := u.client.ListResourceTemplates(ctx)
+	if err != nil {
+		g.logger.Printf("failed to list the resource templates of %s: %v", u.name, err)
+	}
+	for _, template := range templates {
+		g.server.AddResourceTemplate(template, read)
+	}
+}
+
+func (g *Gateway) record(u *upstream, method string, name string, upstreamName string, args interface{}, start time.Time, outcome string, err error) {
+	if g.audit == nil {
+		return
+	}
+	entry := AuditEntry{
+		Time:         start.UTC(),
+		Upstream:     u.name,
+		Method:       method,
+		Name:         name,
+		UpstreamName: upstreamName,
+		Arguments:    args,
+		DurationMs:   time.Since(start).Milliseconds(),
+		Outcome:      outcome,
+	}
+	if err != nil {
+		entry.Outcome = OutcomeError
+		entry.Error = err.Error()
+	}
+	if err := g.audit.Record(entry); err != nil {
+		g.logger.Printf("failed to write the audit trail: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ServeFunc serves an MCP session on stream until it is closed or ctx is
+// cancelled, e.g. the Serve method of a server.Server
+type ServeFunc func(ctx context.Context, stream MessageStream) error
+
+// InProcessTransport connects to a server running in the same process: the
+// messages are exchanged through a pair of in-memory queues, without
+// subprocess or socket. Every connection starts a new session of the server
+type InProcessTransport struct {
+	serve ServeFunc
+
+	mu        sync.RWMutex
+	connected bool
+	// messages sent to the server, and received from it
+	outbox *messageQueue
+	inbox  *messageQueue
+	cancel context.CancelFunc
+	// closed when the server session returns
+	done chan struct{}
+}
+
+// NewInProcessTransport creates a transport connecting to the server run by
+// serve
+func NewInProcessTransport(serve ServeFunc) *InProcessTransport {
+	return &InProcessTransport{serve: serve}
+}
+
+// Connect starts a server session. The session outlives ctx, it ends on
+// Disconnect
+func (t *InProcessTransport) Connect(ctx context.Context) error {
+	if t.serve == nil {
+		return fmt.Errorf("server required for in-process transport")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.connected {
+		return nil
+	}
+
+	outbox, inbox := newMessageQueue(), newMessageQueue()
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// A server returning early closes the connection
+		defer inbox.close()
+		t.serve(ctx, &queueStream{in: outbox, out: inbox})
+	}()
+
+	t.outbox, t.inbox = outbox, inbox
+	t.cancel, t.done = cancel, done
+	t.connected = true
+	return nil
+}
+
+// Disconnect ends the server session and waits for it to return
+func (t *InProcessTransport) Disconnect() error {
+	t.mu.Lock()
+	if !t.connected {
+		t.mu.Unlock()
+		return nil
+	}
+	t.connected = false
+	t.outbox.close()
+	t.cancel()
+	done := t.done
+	t.mu.Unlock()
+
+	<-done
+	return nil
+}
+
+func (t *InProcessTransport) IsConnected() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.connected
+}
+
+// ReadMessage returns the next message sent by the server
+func (t *InProcessTransport) ReadMessage() ([]byte, error) {
+	t.mu.RLock()
+	inbox := t.inbox
+	t.mu.RUnlock()
+	if inbox == nil {
+		return nil, fmt.Errorf("in-process transport not connected")
+	}
+	return inbox.pop()
+}
+
+// WriteMessage queues a message for the server
+func (t *InProcessTransport) WriteMessage(ctx context.Context, data []byte) error {
+	t.mu.RLock()
+	connected, outbox := t.connected, t.outbox
+	t.mu.RUnlock()
+	if !connected {
+		return fmt.Errorf("in-process transport not connected")
+	}
+	outbox.push(data)
+	return nil
+}
+
+// queueStream is the server end of an in-process connection
+type queueStream struct {
+	in  *messageQueue
+	out *messageQueue
+}
+
+func (s *queueStream) ReadMessage() ([]byte, error) {
+	return s.in.pop()
+}
+
+func (s *queueStream) WriteMessage(ctx context.Context, data []byte) error {
+	if s.out.isClosed() {
+		return fmt.Errorf("in-process connection closed")
+	}
+	s.out.push(data)
+	return nil
+}
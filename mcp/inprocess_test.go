@@ -0,0 +1,241 @@
+package mcp_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fmount/ocstack/mcp"
+	"github.com/fmount/ocstack/mcp/server"
+)
+
+// testServer is an in-process server recording the calls of its slow tool
+type testServer struct {
+	*server.Server
+	started   chan struct{}
+	cancelled chan struct{}
+	// closed when the session started by the last Connect returns
+	done chan struct{}
+}
+
+func newTestServer() *testServer {
+	s := &testServer{
+		Server:    server.New("test", "1.0.0"),
+		started:   make(chan struct{}, 1),
+		cancelled: make(chan struct{}, 1),
+	}
+	object := mcp.ToolSchema{Type: "object"}
+
+	s.AddTool(mcp.MCPTool{Name: "echo", InputSchema: mcp.ToolSchema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"text": map[string]interface{}{"type": "string"},
+		},
+		Required: []string{"text"},
+	}}, func(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResponse, error) {
+		return server.TextResult(fmt.Sprint(args["text"])), nil
+	})
+
+	s.AddTool(mcp.MCPTool{Name: "count", InputSchema: object}, func(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResponse, error) {
+		for i := 1; i <= 3; i++ {
+			if err := server.Progress(ctx, float64(i), 3, fmt.Sprintf("step %d", i)); err != nil {
+				return nil, err
+			}
+		}
+		return server.TextResult("counted"), nil
+	})
+
+	s.AddTool(mcp.MCPTool{Name: "slow", InputSchema: object}, func(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResponse, error) {
+		s.started <- struct{}{}
+		select {
+		case <-ctx.Done():
+			s.cancelled <- struct{}{}
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Second):
+			return server.TextResult("done"), nil
+		}
+	})
+	return s
+}
+
+// serve starts a session of the server, done is closed once it returns
+func (s *testServer) serve(ctx context.Context, stream mcp.MessageStream) error {
+	done := make(chan struct{})
+	s.done = done
+	defer close(done)
+	return s.Serve(ctx, stream)
+}
+
+func connect(t *testing.T, s *testServer) *mcp.MCPClient {
+	t.Helper()
+	// The client lives as long as the context given to Connect
+	client := mcp.NewClient(mcp.MCPConfig{InProcess: s.serve})
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect() })
+	return client
+}
+
+func TestInProcessInitialize(t *testing.T) {
+	s := newTestServer()
+	client := connect(t, s)
+
+	if !client.IsConnected() {
+		t.Fatal("client not connected")
+	}
+	if got := client.ProtocolVersion(); got != mcp.LatestProtocolVersion {
+		t.Errorf("ProtocolVersion() = %s, want %s", got, mcp.LatestProtocolVersion)
+	}
+
+	// Disconnect ends the session of the server
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Disconnect() error = %v", err)
+	}
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server session still running after Disconnect")
+	}
+	if client.IsConnected() {
+		t.Error("client still connected after Disconnect")
+	}
+}
+
+func TestInProcessListTools(t *testing.T) {
+	client := connect(t, newTestServer())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools() error = %v", err)
+	}
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	if got, want := strings.Join(names, ","), "count,echo,slow"; got != want {
+		t.Errorf("ListTools() = %s, want %s", got, want)
+	}
+	if !client.HasTool("echo") {
+		t.Error("HasTool(echo) = false after initialize")
+	}
+}
+
+func TestInProcessCallTool(t *testing.T) {
+	client := connect(t, newTestServer())
+
+	tests := []struct {
+		name    string
+		tool    string
+		args    map[string]interface{}
+		want    string
+		isError bool
+		wantErr bool
+	}{
+		{name: "result", tool: "echo", args: map[string]interface{}{"text": "hello"}, want: "hello"},
+		{name: "invalid arguments", tool: "echo", args: map[string]interface{}{}, want: "invalid arguments", isError: true},
+		{name: "unknown tool", tool: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			result, err := client.CallTool(ctx, tt.tool, tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("CallTool() = %+v, want an error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CallTool() error = %v", err)
+			}
+			if result.IsError != tt.isError {
+				t.Errorf("isError = %v, want %v", result.IsError, tt.isError)
+			}
+			if len(result.Content) != 1 || !strings.Contains(result.Content[0].Text, tt.want) {
+				t.Errorf("CallTool() = %+v, want %q", result.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestInProcessNotifications(t *testing.T) {
+	client := connect(t, newTestServer())
+	progress := make(chan mcp.ProgressNotification, 3)
+	client.OnNotification(mcp.NotificationProgress, func(params json.RawMessage) {
+		var notification mcp.ProgressNotification
+		if err := json.Unmarshal(params, &notification); err == nil {
+			progress <- notification
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := client.CallTool(ctx, "count", nil)
+	if err != nil {
+		t.Fatalf("CallTool() error = %v", err)
+	}
+	if len(result.Content) != 1 || result.Content[0].Text != "counted" {
+		t.Errorf("CallTool() = %+v, want counted", result.Content)
+	}
+
+	// Notifications are sent before the response, in order
+	for i := 1; i <= 3; i++ {
+		select {
+		case notification := <-progress:
+			if notification.Progress != float64(i) || notification.Total != 3 {
+				t.Errorf("progress = %g/%g, want %d/3", notification.Progress, notification.Total, i)
+			}
+			if notification.ProgressToken == nil {
+				t.Error("progress notification without token")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("progress notification %d not received", i)
+		}
+	}
+}
+
+func TestInProcessCancellation(t *testing.T) {
+	s := newTestServer()
+	client := connect(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := client.CallTool(ctx, "slow", nil)
+		errs <- err
+	}()
+
+	select {
+	case <-s.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow tool not started")
+	}
+	cancel()
+
+	if err := <-errs; err == nil {
+		t.Error("CallTool() succeeded after its context was cancelled")
+	}
+	// notifications/cancelled stops the handler on the server
+	select {
+	case <-s.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server handler not cancelled")
+	}
+
+	// The session is still usable
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.CallTool(ctx, "echo", map[string]interface{}{"text": "again"}); err != nil {
+		t.Errorf("CallTool() after a cancellation error = %v", err)
+	}
+}
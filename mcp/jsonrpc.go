@@ -0,0 +1,440 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// maxMessageSize is the largest JSON-RPC message accepted from a server
+const maxMessageSize = 16 * 1024 * 1024
+
+// ErrConnClosed is returned by the calls made on a closed Conn
+var ErrConnClosed = fmt.Errorf("JSON-RPC connection closed")
+
+// MessageStream carries encoded JSON-RPC messages. A message is either a
+// single object or a batch (an array of objects)
+type MessageStream interface {
+	// ReadMessage blocks until the next message is received, and fails once
+	// the stream is closed
+	ReadMessage() ([]byte, error)
+	// WriteMessage sends a message, ctx bounds the time spent sending it
+	WriteMessage(ctx context.Context, data []byte) error
+}
+
+// Router handles the requests and notifications received on a Conn
+type Router interface {
+	HandleRequest(ctx context.Context, method string, params json.RawMessage) (interface{}, *JSONRPCError)
+	HandleNotification(method string, params json.RawMessage)
+}
+
+// Conn is a bidirectional JSON-RPC 2.0 session running on any
+// MessageStream: it correlates responses with the pending calls, routes the
+// incoming requests and notifications, and supports batches in both
+// directions. It is safe for concurrent use
+type Conn struct {
+	stream  MessageStream
+	router  Router
+	mu      sync.Mutex
+	pending map[string]chan JSONRPCResponse
+	done    chan struct{}
+	err     error
+
+	// incoming requests being handled, cancelled by notifications/cancelled
+	inflight map[string]context.CancelFunc
+	handlers sync.WaitGroup
+
+	// reports the responses that could not be sent
+	errorLog *log.Logger
+}
+
+// NewConn creates a session on stream. Incoming messages are only processed
+// while Run is running
+func NewConn(stream MessageStream, router Router) *Conn {
+	return &Conn{
+		stream:   stream,
+		router:   router,
+		pending:  make(map[string]chan JSONRPCResponse),
+		done:     make(chan struct{}),
+		inflight: make(map[string]context.CancelFunc),
+		errorLog: log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// SetErrorLog replaces the stderr logger reporting the responses that could
+// not be sent. It must be called before Run
+func (c *Conn) SetErrorLog(logger *log.Logger) {
+	c.errorLog = logger
+}
+
+// Run reads and dispatches the incoming messages until the stream fails.
+// Requests are handled concurrently with ctx, notifications in the order
+// they are received. When Run returns, the pending calls fail with a
+// TransportError
+func (c *Conn) Run(ctx context.Context) error {
+	for {
+		data, err := c.stream.ReadMessage()
+		if err != nil {
+			c.close(err)
+			return err
+		}
+		c.dispatch(ctx, data)
+	}
+}
+
+// Wait blocks until the incoming requests being handled are answered
+func (c *Conn) Wait() {
+	c.handlers.Wait()
+}
+
+// Done is closed when Run returns
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error that stopped Run, if any
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Conn) close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	c.err = err
+	close(c.done)
+}
+
+// Call sends a request and waits for its response
+func (c *Conn) Call(ctx context.Context, request JSONRPCRequest) (*JSONRPCResponse, error) {
+	responses, err := c.Batch(ctx, []JSONRPCRequest{request})
+	if err != nil {
+		return nil, err
+	}
+	return responses[0], nil
+}
+
+// Notify sends a notification, which has no response
+func (c *Conn) Notify(ctx context.Context, notification JSONRPCRequest) error {
+	notification.ID = nil
+	return c.write(ctx, notification)
+}
+
+// Batch sends the requests in a single message and waits for all their
+// responses. Responses are returned in the order of the requests, with nil
+// for notifications
+func (c *Conn) Batch(ctx context.Context, requests []JSONRPCRequest) ([]*JSONRPCResponse, error) {
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("empty JSON-RPC batch")
+	}
+
+	channels := make([]chan JSONRPCResponse, len(requests))
+	c.mu.Lock()
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		return nil, &TransportError{Err: ErrConnClosed}
+	default:
+	}
+	for i, request := range requests {
+		if request.ID == nil {
+			continue
+		}
+		key := idKey(request.ID)
+		if _, exists := c.pending[key]; exists {
+			c.mu.Unlock()
+			c.forget(requests[:i])
+			return nil, fmt.Errorf("duplicate JSON-RPC request id %v", request.ID)
+		}
+		channels[i] = make(chan JSONRPCResponse, 1)
+		c.pending[key] = channels[i]
+	}
+	c.mu.Unlock()
+	defer c.forget(requests)
+
+	var message interface{} = requests
+	if len(requests) == 1 {
+		message = requests[0]
+	}
+	if err := c.write(ctx, message); err != nil {
+		return nil, err
+	}
+
+	responses := make([]*JSONRPCResponse, len(requests))
+	for i, ch := range channels {
+		if ch == nil {
+			continue
+		}
+		select {
+		case response := <-ch:
+			responses[i] = &response
+		case <-c.done:
+			// Deliver what was received before the connection dropped
+			select {
+			case response := <-ch:
+				responses[i] = &response
+				continue
+			default:
+			}
+			return nil, &TransportError{Err: ErrConnectionLost}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return responses, nil
+}
+
+// forget drops the pending calls of the given requests
+func (c *Conn) forget(requests []JSONRPCRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, request := range requests {
+		if request.ID != nil {
+			delete(c.pending, idKey(request.ID))
+		}
+	}
+}
+
+func (c *Conn) write(ctx context.Context, message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return c.stream.WriteMessage(ctx, data)
+}
+
+// dispatch routes a single message or a batch
+func (c *Conn) dispatch(ctx context.Context, data []byte) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return
+	}
+
+	if data[0] != '[' {
+		var message JSONRPCMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			c.reply(ctx, parseError(err))
+			return
+		}
+		if request := c.route(message); request != nil {
+			// Requests may need user interaction, don't block the read
+			// loop while they are processed
+			c.handlers.Add(1)
+			go func() {
+				defer c.handlers.Done()
+				if response := c.handle(ctx, *request); response != nil {
+					c.reply(ctx, response)
+				}
+			}()
+		}
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		c.reply(ctx, parseError(err))
+		return
+	}
+	if len(batch) == 0 {
+		c.reply(ctx, &JSONRPCResponse{
+			JSONRpc: "2.0",
+			Error:   &JSONRPCError{Code: ErrCodeInvalidRequest, Message: "empty batch"},
+		})
+		return
+	}
+
+	var requests []JSONRPCMessage
+	for _, raw := range batch {
+		var message JSONRPCMessage
+		if err := json.Unmarshal(raw, &message); err != nil {
+			c.reply(ctx, parseError(err))
+			continue
+		}
+		if request := c.route(message); request != nil {
+			requests = append(requests, *request)
+		}
+	}
+	if len(requests) == 0 {
+		return
+	}
+
+	// The responses to a batch are sent back in a single batch
+	c.handlers.Add(1)
+	go func() {
+		defer c.handlers.Done()
+		responses := make([]*JSONRPCResponse, len(requests))
+		var wg sync.WaitGroup
+		for i, message := range requests {
+			wg.Add(1)
+			go func(i int, message JSONRPCMessage) {
+				defer wg.Done()
+				responses[i] = c.handle(ctx, message)
+			}(i, message)
+		}
+		wg.Wait()
+
+		var batch []*JSONRPCResponse
+		for _, response := range responses {
+			if response != nil {
+				batch = append(batch, response)
+			}
+		}
+		if len(batch) > 0 {
+			c.reply(ctx, batch)
+		}
+	}()
+}
+
+// route delivers responses and notifications, and returns the requests that
+// must be handled
+func (c *Conn) route(message JSONRPCMessage) *JSONRPCMessage {
+	switch {
+	case message.IsResponse():
+		c.deliver(message.Response())
+	case message.IsNotification():
+		if message.Method == NotificationCancelled {
+			c.cancel(message.Params)
+		}
+		if c.router != nil {
+			c.router.HandleNotification(message.Method, message.Params)
+		}
+	default:
+		return &message
+	}
+	return nil
+}
+
+// deliver routes a response to the call waiting for it. Responses to unknown
+// or abandoned calls are dropped
+func (c *Conn) deliver(response JSONRPCResponse) {
+	c.mu.Lock()
+	ch, exists := c.pending[idKey(response.ID)]
+	delete(c.pending, idKey(response.ID))
+	c.mu.Unlock()
+
+	if exists {
+		ch <- response
+	}
+}
+
+// handle runs the router for an incoming request. It returns nil when the
+// request is cancelled, since no response must be sent in that case
+func (c *Conn) handle(ctx context.Context, message JSONRPCMessage) *JSONRPCResponse {
+	key := idKey(message.ID)
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	c.inflight[key] = cancel
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		c.mu.Unlock()
+		cancel()
+	}()
+
+	response := &JSONRPCResponse{
+		JSONRpc: "2.0",
+		ID:      message.ID,
+	}
+	if c.router == nil {
+		response.Error = &JSONRPCError{
+			Code:    ErrCodeMethodNotFound,
+			Message: fmt.Sprintf("method not found: %s", message.Method),
+		}
+		return response
+	}
+
+	result, rpcErr := c.router.HandleRequest(ctx, message.Method, message.Params)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if rpcErr != nil {
+		response.Error = rpcErr
+	} else {
+		response.Result = result
+	}
+	return response
+}
+
+// cancel stops the handling of the request referenced by a
+// notifications/cancelled
+func (c *Conn) cancel(params json.RawMessage) {
+	var notification CancelledNotification
+	if err := json.Unmarshal(params, &notification); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel, exists := c.inflight[idKey(notification.RequestID)]; exists {
+		cancel()
+	}
+}
+
+func (c *Conn) reply(ctx context.Context, message interface{}) {
+	if err := c.write(ctx, message); err != nil {
+		c.errorLog.Printf("failed to send JSON-RPC response: %v", err)
+	}
+}
+
+func parseError(err error) *JSONRPCResponse {
+	return &JSONRPCResponse{
+		JSONRpc: "2.0",
+		Error: &JSONRPCError{
+			Code:    ErrCodeParse,
+			Message: fmt.Sprintf("parse error: %v", err),
+		},
+	}
+}
+
+// lineStream frames messages as lines of a byte stream, as done by the stdio
+// transport
+type lineStream struct {
+	scanner *bufio.Scanner
+	w       io.Writer
+	writeMu sync.Mutex
+}
+
+// NewLineStream returns a MessageStream exchanging newline delimited
+// messages over r and w
+func NewLineStream(r io.Reader, w io.Writer) MessageStream {
+	scanner := bufio.NewScanner(r)
+	// Tool results can be much larger than the default 64KB token size
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	return &lineStream{scanner: scanner, w: w}
+}
+
+func (s *lineStream) ReadMessage() ([]byte, error) {
+	for s.scanner.Scan() {
+		line := s.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		return append([]byte{}, line...), nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (s *lineStream) WriteMessage(ctx context.Context, data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return &TransportError{Err: fmt.Errorf("failed to write message: %w", err)}
+	}
+	return nil
+}
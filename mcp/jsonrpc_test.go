@@ -0,0 +1,344 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRouter handles the requests with handle, and records the notifications
+type testRouter struct {
+	handle        func(ctx context.Context, method string, params json.RawMessage) (interface{}, *JSONRPCError)
+	notifications chan string
+}
+
+func (r *testRouter) HandleRequest(ctx context.Context, method string, params json.RawMessage) (interface{}, *JSONRPCError) {
+	return r.handle(ctx, method, params)
+}
+
+func (r *testRouter) HandleNotification(method string, params json.RawMessage) {
+	if r.notifications != nil {
+		r.notifications <- method
+	}
+}
+
+// echo returns the params of the request
+func echo(ctx context.Context, method string, params json.RawMessage) (interface{}, *JSONRPCError) {
+	return params, nil
+}
+
+// connPair connects two Conns through in-memory queues, and runs them until
+// the test ends
+func connPair(t *testing.T, router Router) (client *Conn, server *Conn) {
+	t.Helper()
+	toServer, toClient := newMessageQueue(), newMessageQueue()
+	client = NewConn(&queueStream{in: toClient, out: toServer}, nil)
+	server = NewConn(&queueStream{in: toServer, out: toClient}, router)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go client.Run(ctx)
+	go server.Run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		toServer.close()
+		toClient.close()
+		server.Wait()
+	})
+	return client, server
+}
+
+// rawPeer exchanges raw messages with a Conn, to control their content and
+// order
+type rawPeer struct {
+	t    *testing.T
+	in   *messageQueue
+	out  *messageQueue
+	conn *Conn
+}
+
+func newRawPeer(t *testing.T, router Router) *rawPeer {
+	t.Helper()
+	p := &rawPeer{t: t, in: newMessageQueue(), out: newMessageQueue()}
+	p.conn = NewConn(&queueStream{in: p.out, out: p.in}, router)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.conn.Run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		p.out.close()
+		p.conn.Wait()
+	})
+	return p
+}
+
+func (p *rawPeer) send(message string) {
+	p.out.push([]byte(message))
+}
+
+// receive returns the next message sent by the Conn
+func (p *rawPeer) receive() []byte {
+	p.t.Helper()
+	received := make(chan []byte, 1)
+	go func() {
+		message, _ := p.in.pop()
+		received <- message
+	}()
+	select {
+	case message := <-received:
+		return message
+	case <-time.After(5 * time.Second):
+		p.t.Fatal("no message received")
+		return nil
+	}
+}
+
+func request(id interface{}, method string, params interface{}) JSONRPCRequest {
+	return JSONRPCRequest{JSONRpc: "2.0", ID: id, Method: method, Params: params}
+}
+
+func TestConnConcurrentCalls(t *testing.T) {
+	client, _ := connPair(t, &testRouter{handle: echo})
+
+	const calls = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, calls)
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			response, err := client.Call(ctx, request(i, "echo", map[string]int{"n": i}))
+			if err != nil {
+				errs <- err
+				return
+			}
+			var result map[string]int
+			data, _ := json.Marshal(response.Result)
+			if err := json.Unmarshal(data, &result); err != nil || result["n"] != i {
+				errs <- fmt.Errorf("call %d got %s", i, data)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConnOutOfOrderResponses(t *testing.T) {
+	peer := newRawPeer(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	type result struct {
+		response *JSONRPCResponse
+		err      error
+	}
+	results := make([]chan result, 2)
+	for i := range results {
+		results[i] = make(chan result, 1)
+		go func(i int) {
+			response, err := peer.conn.Call(ctx, request(i+1, "slow", nil))
+			results[i] <- result{response, err}
+		}(i)
+		peer.receive()
+	}
+
+	// A string id must not be taken for the number it spells
+	peer.send(`{"jsonrpc":"2.0","id":"1","result":"wrong"}`)
+	peer.send(`{"jsonrpc":"2.0","id":2,"result":"second"}`)
+	peer.send(`{"jsonrpc":"2.0","id":1,"result":"first"}`)
+
+	for i, want := range []string{"first", "second"} {
+		r := <-results[i]
+		if r.err != nil {
+			t.Fatalf("call %d: %v", i+1, r.err)
+		}
+		if r.response.Result != want {
+			t.Errorf("call %d result = %v, want %s", i+1, r.response.Result, want)
+		}
+	}
+}
+
+func TestConnBatch(t *testing.T) {
+	notifications := make(chan string, 1)
+	client, _ := connPair(t, &testRouter{handle: echo, notifications: notifications})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	responses, err := client.Batch(ctx, []JSONRPCRequest{
+		request(1, "echo", "one"),
+		request(nil, "notifications/test", nil),
+		request("two", "echo", "two"),
+	})
+	if err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("Batch() returned %d responses, want 3", len(responses))
+	}
+	if responses[0] == nil || responses[0].Result != "one" {
+		t.Errorf("responses[0] = %+v, want one", responses[0])
+	}
+	if responses[1] != nil {
+		t.Errorf("responses[1] = %+v, want nil for the notification", responses[1])
+	}
+	if responses[2] == nil || responses[2].Result != "two" {
+		t.Errorf("responses[2] = %+v, want two", responses[2])
+	}
+	if method := <-notifications; method != "notifications/test" {
+		t.Errorf("notification = %s", method)
+	}
+
+	if _, err := client.Batch(ctx, []JSONRPCRequest{request(3, "echo", nil), request(3, "echo", nil)}); err == nil {
+		t.Error("Batch() with duplicate ids succeeded")
+	}
+}
+
+func TestConnIncomingBatch(t *testing.T) {
+	peer := newRawPeer(t, &testRouter{handle: echo})
+
+	peer.send(`[{"jsonrpc":"2.0","id":1,"method":"echo","params":1},` +
+		`{"jsonrpc":"2.0","method":"notifications/test"},` +
+		`{"jsonrpc":"2.0","id":"1","method":"echo","params":"1"}]`)
+
+	// The responses are sent back in a single batch
+	var batch []JSONRPCMessage
+	if err := json.Unmarshal(peer.receive(), &batch); err != nil {
+		t.Fatalf("invalid batch response: %v", err)
+	}
+	if len(batch) != 2 {
+		t.Fatalf("batch response has %d responses, want 2", len(batch))
+	}
+	got := map[string]interface{}{}
+	for _, response := range batch {
+		got[idKey(response.ID)] = response.Result
+	}
+	if got[`1`] != float64(1) || got[`"1"`] != "1" {
+		t.Errorf("batch responses = %v", got)
+	}
+}
+
+func TestConnCancellation(t *testing.T) {
+	started := make(chan string, 2)
+	cancelled := make(chan string, 2)
+	peer := newRawPeer(t, &testRouter{handle: func(ctx context.Context, method string, params json.RawMessage) (interface{}, *JSONRPCError) {
+		started <- string(params)
+		select {
+		case <-ctx.Done():
+			cancelled <- string(params)
+			return nil, nil
+		case <-time.After(200 * time.Millisecond):
+			return "done", nil
+		}
+	}})
+
+	peer.send(`{"jsonrpc":"2.0","id":1,"method":"wait","params":"number"}`)
+	peer.send(`{"jsonrpc":"2.0","id":"1","method":"wait","params":"string"}`)
+	<-started
+	<-started
+	peer.send(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"1"}}`)
+
+	select {
+	case params := <-cancelled:
+		if params != `"string"` {
+			t.Errorf("cancelled request %s, want the one with the string id", params)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not cancelled")
+	}
+
+	// Only the request that was not cancelled is answered
+	var response JSONRPCMessage
+	if err := json.Unmarshal(peer.receive(), &response); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if response.ID != float64(1) || response.Result != "done" {
+		t.Errorf("response = %+v, want the result of request 1", response)
+	}
+}
+
+func TestConnCallCancelled(t *testing.T) {
+	peer := newRawPeer(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := peer.conn.Call(ctx, request(1, "slow", nil))
+		errs <- err
+	}()
+	peer.receive()
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Call() error = %v, want context.Canceled", err)
+	}
+
+	// A late response to the abandoned call is dropped
+	peer.send(`{"jsonrpc":"2.0","id":1,"result":"late"}`)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		peer.receive()
+		peer.send(`{"jsonrpc":"2.0","id":2,"result":"next"}`)
+	}()
+	response, err := peer.conn.Call(ctx, request(2, "slow", nil))
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if response.Result != "next" {
+		t.Errorf("Call() result = %v, want next", response.Result)
+	}
+
+	peer.conn.mu.Lock()
+	defer peer.conn.mu.Unlock()
+	if len(peer.conn.pending) != 0 {
+		t.Errorf("%d calls still pending", len(peer.conn.pending))
+	}
+}
+
+func TestConnClosed(t *testing.T) {
+	peer := newRawPeer(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := peer.conn.Call(ctx, request(1, "slow", nil))
+		errs <- err
+	}()
+	peer.receive()
+	peer.out.close()
+
+	var transportErr *TransportError
+	if err := <-errs; !errors.As(err, &transportErr) {
+		t.Fatalf("Call() error = %v, want a TransportError", err)
+	}
+	if _, err := peer.conn.Call(ctx, request(2, "slow", nil)); !errors.As(err, &transportErr) {
+		t.Errorf("Call() on a closed Conn error = %v, want a TransportError", err)
+	}
+}
+
+func TestIDKey(t *testing.T) {
+	tests := []struct {
+		a, b  interface{}
+		equal bool
+	}{
+		{1, float64(1), true},
+		{int64(42), float64(42), true},
+		{"1", 1, false},
+		{"1", float64(1), false},
+		{"a", "a", true},
+	}
+	for _, tt := range tests {
+		if got := idKey(tt.a) == idKey(tt.b); got != tt.equal {
+			t.Errorf("idKey(%#v) == idKey(%#v) is %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+	}
+}
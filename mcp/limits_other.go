@@ -0,0 +1,17 @@
+//go:build !linux && !darwin
+
+package mcp
+
+import "fmt"
+
+// RunLimitedIfRequested - resource limits are only supported on Linux and
+// macOS, there is nothing to run
+func RunLimitedIfRequested() {}
+
+// withLimits - resource limits are only supported on Linux and macOS
+func (p *ProcessConfig) withLimits(argv []string, environ []string) ([]string, []string, error) {
+	if len(p.limits()) > 0 {
+		return nil, nil, fmt.Errorf("resource limits are only supported on Linux and macOS")
+	}
+	return argv, environ, nil
+}
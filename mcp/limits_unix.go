@@ -0,0 +1,91 @@
+//go:build linux || darwin
+
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// The resource limits are set by a copy of our own binary: started with
+// limitsCommand, the limits encoded in JSON and the server to run, it
+// applies the limits to itself and execs the server, so that they apply
+// from the first instruction of the server and to its children
+const limitsCommand = "mcp-limited-exec"
+
+var rlimitResources = map[string]int{
+	limitCPU:       syscall.RLIMIT_CPU,
+	limitMemory:    syscall.RLIMIT_AS,
+	limitOpenFiles: syscall.RLIMIT_NOFILE,
+}
+
+// RunLimitedIfRequested runs the stdio server our binary was started for by
+// ProcessConfig.Command with resource limits, and never returns then. It
+// returns at once otherwise. Programs starting servers with limits call it
+// first thing in main
+func RunLimitedIfRequested() {
+	if len(os.Args) > 1 && os.Args[1] == limitsCommand {
+		execWithLimits(os.Args[2:])
+	}
+}
+
+// withLimits runs argv through our binary when resource limits are set
+func (p *ProcessConfig) withLimits(argv []string, environ []string) ([]string, []string, error) {
+	limits := p.limits()
+	if len(limits) == 0 {
+		return argv, environ, nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find the executable applying the resource limits: %w", err)
+	}
+
+	// Commands are looked up in our PATH, as done without limits. Paths
+	// are left as they are, relative ones are relative to Dir
+	path := argv[0]
+	if !strings.Contains(path, "/") {
+		if path, err = exec.LookPath(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	encoded, err := json.Marshal(limits)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append([]string{self, limitsCommand, string(encoded), path}, argv...), environ, nil
+}
+
+// execWithLimits applies the limits to the current process and execs the
+// server. args holds the limits, the path of the server and its argv. It
+// never returns
+func execWithLimits(args []string) {
+	var err error
+	if len(args) < 3 {
+		err = fmt.Errorf("no command to run")
+	} else if err = setLimits(args[0]); err == nil {
+		err = syscall.Exec(args[1], args[2:], os.Environ())
+	}
+	fmt.Fprintf(os.Stderr, "failed to start the MCP server: %v\n", err)
+	os.Exit(127)
+}
+
+func setLimits(encoded string) error {
+	var limits map[string]uint64
+	if err := json.Unmarshal([]byte(encoded), &limits); err != nil {
+		return fmt.Errorf("invalid resource limits: %w", err)
+	}
+	for name, value := range limits {
+		resource, exists := rlimitResources[name]
+		if !exists {
+			return fmt.Errorf("unknown resource limit: %s", name)
+		}
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: value, Max: value}); err != nil {
+			return fmt.Errorf("failed to set the %s limit to %d: %w", name, value, err)
+		}
+	}
+	return nil
+}
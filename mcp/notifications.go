@@ -0,0 +1,210 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Notifications sent by MCP servers
+const (
+	NotificationToolsListChanged = "notifications/tools/list_changed"
+	NotificationMessage          = "notifications/message"
+	NotificationProgress         = "notifications/progress"
+	NotificationResourceUpdated  = "notifications/resources/updated"
+)
+
+// Notifications sent by the client
+const (
+	NotificationRootsListChanged = "notifications/roots/list_changed"
+)
+
+// DefaultLogLevel is the minimum level of the server log messages shown when
+// MCPConfig.LogLevel is not set
+const DefaultLogLevel = "info"
+
+// logLevels follows the syslog severities defined by the MCP specification
+var logLevels = []string{
+	"debug",
+	"info",
+	"notice",
+	"warning",
+	"error",
+	"critical",
+	"alert",
+	"emergency",
+}
+
+// NotificationHandler processes the params of a server notification
+type NotificationHandler func(params json.RawMessage)
+
+// OnNotification registers a handler for the given notification method,
+// replacing the existing one, if any
+func (c *MCPClient) OnNotification(method string, handler NotificationHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notificationHandlers[method] = handler
+}
+
+// OnToolsChanged registers a callback invoked after the tool list has been
+// refreshed because the server notified a change
+func (c *MCPClient) OnToolsChanged(callback func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.toolsChangedCallbacks = append(c.toolsChangedCallbacks, callback)
+}
+
+// SetLogLevel updates the minimum level of the server log messages shown to
+// the user and, if supported, asks the server to only send those messages
+func (c *MCPClient) SetLogLevel(ctx context.Context, level string) error {
+	level = strings.ToLower(level)
+	if logLevelIndex(level) < 0 {
+		return fmt.Errorf("invalid log level '%s', use one of: %s", level, strings.Join(logLevels, ", "))
+	}
+
+	c.mu.Lock()
+	c.config.LogLevel = level
+	supported := c.capabilities != nil && c.capabilities.Logging != nil
+	c.mu.Unlock()
+
+	if !supported || !c.IsConnected() {
+		return nil
+	}
+
+	request := JSONRPCRequest{
+		JSONRpc: "2.0",
+		ID:      c.nextRequestID(),
+		Method:  "logging/setLevel",
+		Params:  SetLevelRequest{Level: level},
+	}
+
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return fmt.Errorf("failed to send logging/setLevel request: %w", err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("logging/setLevel failed: %s", response.Error.Message)
+	}
+	return nil
+}
+
+// registerDefaultNotificationHandlers installs the handlers for the
+// notifications the client understands out of the box
+func (c *MCPClient) registerDefaultNotificationHandlers() {
+	c.notificationHandlers[NotificationToolsListChanged] = c.handleToolsListChanged
+	c.notificationHandlers[NotificationMessage] = c.handleLoggingMessage
+	c.notificationHandlers[NotificationProgress] = c.handleProgress
+	c.notificationHandlers[NotificationResourceUpdated] = c.handleResourceUpdated
+}
+
+// dispatchNotification routes a notification to the registered handler.
+// Unknown notifications are ignored as required by the specification
+func (c *MCPClient) dispatchNotification(method string, params json.RawMessage) {
+	c.mu.RLock()
+	handler, exists := c.notificationHandlers[method]
+	c.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+	handler(params)
+}
+
+func (c *MCPClient) handleToolsListChanged(params json.RawMessage) {
+	// The refresh goes through the same message loop that delivered the
+	// notification, so it must not block it
+	go func() {
+		if err := c.refreshTools(); err != nil {
+			c.printf("Warning: failed to refresh tools: %v\n", err)
+			return
+		}
+
+		c.mu.RLock()
+		callbacks := append([]func(){}, c.toolsChangedCallbacks...)
+		c.mu.RUnlock()
+
+		for _, callback := range callbacks {
+			callback()
+		}
+	}()
+}
+
+func (c *MCPClient) handleLoggingMessage(params json.RawMessage) {
+	var msg LoggingMessageNotification
+	if err := json.Unmarshal(params, &msg); err != nil {
+		return
+	}
+
+	c.mu.RLock()
+	threshold := c.config.LogLevel
+	c.mu.RUnlock()
+	if threshold == "" {
+		threshold = DefaultLogLevel
+	}
+	if logLevelIndex(msg.Level) < logLevelIndex(threshold) {
+		return
+	}
+
+	data, ok := msg.Data.(string)
+	if !ok {
+		b, _ := json.Marshal(msg.Data)
+		data = string(b)
+	}
+	if msg.Logger != "" {
+		c.printf("[MCP %s] %s: %s\n", strings.ToUpper(msg.Level), msg.Logger, data)
+		return
+	}
+	c.printf("[MCP %s] %s\n", strings.ToUpper(msg.Level), data)
+}
+
+func (c *MCPClient) handleProgress(params json.RawMessage) {
+	var progress ProgressNotification
+	if err := json.Unmarshal(params, &progress); err != nil {
+		return
+	}
+
+	c.mu.RLock()
+	name, exists := c.progressTokens[idKey(progress.ProgressToken)]
+	c.mu.RUnlock()
+	if !exists {
+		name = fmt.Sprintf("%v", progress.ProgressToken)
+	}
+
+	status := fmt.Sprintf("%g", progress.Progress)
+	if progress.Total > 0 {
+		status = fmt.Sprintf("%g/%g (%.0f%%)", progress.Progress, progress.Total, 100*progress.Progress/progress.Total)
+	}
+	if progress.Message != "" {
+		status = fmt.Sprintf("%s - %s", status, progress.Message)
+	}
+	c.printf("P :> [%s] %s\n", name, status)
+}
+
+func (c *MCPClient) handleResourceUpdated(params json.RawMessage) {
+	var updated ResourceUpdatedNotification
+	if err := json.Unmarshal(params, &updated); err != nil {
+		return
+	}
+	c.printf("I :> MCP resource updated: %s\n", updated.URI)
+}
+
+func logLevelIndex(level string) int {
+	for i, l := range logLevels {
+		if l == strings.ToLower(level) {
+			return i
+		}
+	}
+	return -1
+}
+
+// idKey returns the JSON encoding of an id, which tells the string "1" from
+// the number 1. Numbers encode the same way whether they are the integers we
+// send or the float64 decoded from the peer messages
+func idKey(id interface{}) string {
+	data, err := json.Marshal(id)
+	if err != nil {
+		return fmt.Sprintf("%v", id)
+	}
+	return string(data)
+}
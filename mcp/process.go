@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// EnvMode defines how the environment of a stdio server is built
+type EnvMode string
+
+const (
+	// EnvInherit passes our whole environment, the configured Env on top
+	EnvInherit EnvMode = "inherit"
+	// EnvAllowlist only passes the variables listed in EnvAllowlist, along
+	// with the configured Env. This is the default
+	EnvAllowlist EnvMode = "allowlist"
+	// EnvClean only passes the configured Env
+	EnvClean EnvMode = "clean"
+)
+
+// Resource limits applied to the server process
+const (
+	limitCPU       = "cpu"
+	limitMemory    = "memory"
+	limitOpenFiles = "openFiles"
+)
+
+// DefaultEnvAllowlist - the variables passed to the servers when
+// EnvAllowlist is empty: what tools like npx, uvx or oc need to run, without
+// the tokens and keys that may be set in our environment
+var DefaultEnvAllowlist = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TMPDIR", "TZ",
+	"LANG", "LC_ALL", "TERM",
+	"XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME",
+	"KUBECONFIG",
+}
+
+// ProcessConfig controls the process of a stdio server: its environment,
+// working directory, resource limits and sandboxing
+type ProcessConfig struct {
+	// EnvAllowlist when empty
+	EnvMode EnvMode `json:"envMode,omitempty"`
+
+	// Variables inherited with EnvAllowlist, DefaultEnvAllowlist when empty
+	EnvAllowlist []string `json:"envAllowlist,omitempty"`
+
+	// Working directory of the server, ours when empty
+	Dir string `json:"dir,omitempty"`
+
+	// Resource limits, unlimited when zero. CPUTime is rounded up to the
+	// second, MaxMemory (bytes) limits the address space of the process.
+	// Linux and macOS only
+	CPUTime      time.Duration `json:"cpuTime,omitempty"`
+	MaxMemory    uint64        `json:"maxMemory,omitempty"`
+	MaxOpenFiles uint64        `json:"maxOpenFiles,omitempty"`
+
+	// Runs the server in a new user namespace as an unprivileged user,
+	// Linux only
+	UserNamespace bool `json:"userNamespace,omitempty"`
+}
+
+// Command builds the server process, which is killed when ctx is cancelled.
+// A nil ProcessConfig passes the DefaultEnvAllowlist variables and runs the
+// server without limits
+func (p *ProcessConfig) Command(ctx context.Context, argv []string, env map[string]string) (*exec.Cmd, error) {
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("Command required for stdio transport")
+	}
+	if p == nil {
+		p = &ProcessConfig{}
+	}
+
+	environ, err := p.environ(env)
+	if err != nil {
+		return nil, err
+	}
+
+	argv, environ, err = p.withLimits(argv, environ)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = environ
+	cmd.Dir = p.Dir
+
+	if p.UserNamespace {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+		if err := userNamespace(cmd.SysProcAttr); err != nil {
+			return nil, err
+		}
+	}
+	return cmd, nil
+}
+
+// environ returns the environment of the process, env overriding the
+// inherited variables
+func (p *ProcessConfig) environ(env map[string]string) ([]string, error) {
+	// A nil cmd.Env would inherit everything, keep it empty instead
+	environ := []string{}
+	switch p.EnvMode {
+	case EnvInherit:
+		environ = append(environ, os.Environ()...)
+	case "", EnvAllowlist:
+		allowlist := p.EnvAllowlist
+		if len(allowlist) == 0 {
+			allowlist = DefaultEnvAllowlist
+		}
+		for _, name := range allowlist {
+			if value, exists := os.LookupEnv(name); exists {
+				environ = append(environ, name+"="+value)
+			}
+		}
+	case EnvClean:
+	default:
+		return nil, fmt.Errorf("unknown env mode: %s", p.EnvMode)
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// exec uses the last value of duplicated variables
+	for _, name := range names {
+		environ = append(environ, name+"="+env[name])
+	}
+	return environ, nil
+}
+
+// limits returns the resource limits to apply, by resource
+func (p *ProcessConfig) limits() map[string]uint64 {
+	limits := map[string]uint64{}
+	if p.CPUTime > 0 {
+		limits[limitCPU] = uint64(math.Ceil(p.CPUTime.Seconds()))
+	}
+	if p.MaxMemory > 0 {
+		limits[limitMemory] = p.MaxMemory
+	}
+	if p.MaxOpenFiles > 0 {
+		limits[limitOpenFiles] = p.MaxOpenFiles
+	}
+	return limits
+}
@@ -0,0 +1,106 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain runs the servers started with limits, through the test binary
+func TestMain(m *testing.M) {
+	RunLimitedIfRequested()
+	os.Exit(m.Run())
+}
+
+func TestProcessEnviron(t *testing.T) {
+	t.Setenv("HOME", "/home/test")
+	t.Setenv("OCSTACK_TEST_SECRET", "secret")
+
+	tests := []struct {
+		name    string
+		config  *ProcessConfig
+		env     map[string]string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "default",
+			config:  &ProcessConfig{},
+			want:    []string{"HOME=/home/test"},
+			notWant: []string{"OCSTACK_TEST_SECRET"},
+		},
+		{
+			name:    "allowlist",
+			config:  &ProcessConfig{EnvMode: EnvAllowlist, EnvAllowlist: []string{"OCSTACK_TEST_SECRET"}},
+			want:    []string{"OCSTACK_TEST_SECRET=secret"},
+			notWant: []string{"HOME"},
+		},
+		{
+			name:   "inherit",
+			config: &ProcessConfig{EnvMode: EnvInherit},
+			want:   []string{"HOME=/home/test", "OCSTACK_TEST_SECRET=secret"},
+		},
+		{
+			name:    "clean",
+			config:  &ProcessConfig{EnvMode: EnvClean},
+			env:     map[string]string{"API_KEY": "key"},
+			want:    []string{"API_KEY=key"},
+			notWant: []string{"HOME", "OCSTACK_TEST_SECRET"},
+		},
+		{
+			name:   "env overrides",
+			config: &ProcessConfig{},
+			env:    map[string]string{"HOME": "/tmp"},
+			want:   []string{"HOME=/tmp"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			environ, err := tt.config.environ(tt.env)
+			if err != nil {
+				t.Fatalf("environ() error = %v", err)
+			}
+			// exec uses the last value of duplicated variables
+			values := map[string]string{}
+			for _, variable := range environ {
+				name, value, _ := strings.Cut(variable, "=")
+				values[name] = value
+			}
+			for _, variable := range tt.want {
+				name, value, _ := strings.Cut(variable, "=")
+				if got, exists := values[name]; !exists || got != value {
+					t.Errorf("%s = %q, want %q", name, got, value)
+				}
+			}
+			for _, name := range tt.notWant {
+				if _, exists := values[name]; exists {
+					t.Errorf("%s passed to the server", name)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := &ProcessConfig{
+		CPUTime:      90 * time.Second,
+		MaxMemory:    4 << 30,
+		MaxOpenFiles: 64,
+	}
+	cmd, err := config.Command(ctx, []string{"sh", "-c", "ulimit -t; ulimit -v; ulimit -n"}, nil)
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("server failed: %v: %s", err, out)
+	}
+	if got, want := strings.Fields(string(out)), []string{"90", "4194304", "64"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("limits = %v, want %v", got, want)
+	}
+}
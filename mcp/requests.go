@@ -0,0 +1,221 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Requests sent by MCP servers to the client
+const (
+	RequestListRoots     = "roots/list"
+	RequestCreateMessage = "sampling/createMessage"
+	RequestElicitation   = "elicitation/create"
+)
+
+// JSON-RPC error codes
+const (
+	ErrCodeParse          = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+	// ErrCodeUserRejected is returned when the user declines a server request
+	ErrCodeUserRejected = -1
+	// ErrCodeResourceNotFound is returned by resources/read for unknown URIs
+	ErrCodeResourceNotFound = -32002
+)
+
+// DefaultMaxSamplingTokens is the maxTokens upper bound applied to sampling
+// requests when MCPConfig.MaxSamplingTokens is not set
+const DefaultMaxSamplingTokens = 1024
+
+// RequestHandler processes a server request and returns either the result or
+// a JSON-RPC error
+type RequestHandler func(ctx context.Context, params json.RawMessage) (interface{}, *JSONRPCError)
+
+// SamplingHandler fulfills a sampling/createMessage request, usually by asking
+// the user for approval and forwarding the messages to an LLM
+type SamplingHandler func(ctx context.Context, request *CreateMessageRequest) (*CreateMessageResult, error)
+
+// ElicitationHandler fulfills an elicitation/create request, usually by
+// asking the user to fill a form. The result action is accept, decline or
+// cancel
+type ElicitationHandler func(ctx context.Context, request *ElicitRequest) (*ElicitResult, error)
+
+// ErrUserRejected can be returned by handlers when the user declines a server
+// request
+var ErrUserRejected = fmt.Errorf("request rejected by the user")
+
+// OnRequest registers a handler for the given server request method,
+// replacing the existing one, if any
+func (c *MCPClient) OnRequest(method string, handler RequestHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requestHandlers[method] = handler
+}
+
+// SetSamplingHandler enables sampling: the capability is advertised to the
+// server on the next Connect and sampling/createMessage requests are passed
+// to the handler
+func (c *MCPClient) SetSamplingHandler(handler SamplingHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.samplingHandler = handler
+}
+
+// SetElicitationHandler enables elicitation: the capability is advertised to
+// the server on the next Connect, when the offered protocol version supports
+// it, and elicitation/create requests are passed to the handler
+func (c *MCPClient) SetElicitationHandler(handler ElicitationHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.elicitationHandler = handler
+}
+
+// registerDefaultRequestHandlers installs the handlers for the requests the
+// client understands out of the box
+func (c *MCPClient) registerDefaultRequestHandlers() {
+	c.requestHandlers[RequestListRoots] = c.handleListRoots
+	c.requestHandlers[RequestCreateMessage] = c.handleCreateMessage
+	c.requestHandlers[RequestElicitation] = c.handleElicitation
+}
+
+// clientRouter routes the messages received from the server to the
+// registered handlers
+type clientRouter struct {
+	c *MCPClient
+}
+
+// HandleRequest runs the handler associated to the request method
+func (r clientRouter) HandleRequest(ctx context.Context, method string, params json.RawMessage) (interface{}, *JSONRPCError) {
+	r.c.mu.RLock()
+	handler, exists := r.c.requestHandlers[method]
+	r.c.mu.RUnlock()
+
+	if !exists {
+		return nil, &JSONRPCError{
+			Code:    ErrCodeMethodNotFound,
+			Message: fmt.Sprintf("method not found: %s", method),
+		}
+	}
+	return handler(ctx, params)
+}
+
+func (r clientRouter) HandleNotification(method string, params json.RawMessage) {
+	r.c.dispatchNotification(method, params)
+}
+
+func (c *MCPClient) handleListRoots(ctx context.Context, params json.RawMessage) (interface{}, *JSONRPCError) {
+	c.mu.RLock()
+	roots := append([]Root{}, c.config.Roots...)
+	c.mu.RUnlock()
+	return ListRootsResponse{Roots: roots}, nil
+}
+
+func (c *MCPClient) handleCreateMessage(ctx context.Context, params json.RawMessage) (interface{}, *JSONRPCError) {
+	c.mu.RLock()
+	handler := c.samplingHandler
+	limit := c.config.MaxSamplingTokens
+	c.mu.RUnlock()
+
+	if handler == nil {
+		return nil, &JSONRPCError{
+			Code:    ErrCodeMethodNotFound,
+			Message: "sampling not supported by the client",
+		}
+	}
+
+	var request CreateMessageRequest
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, &JSONRPCError{
+			Code:    ErrCodeInvalidParams,
+			Message: fmt.Sprintf("invalid sampling request: %v", err),
+		}
+	}
+	if len(request.Messages) == 0 {
+		return nil, &JSONRPCError{
+			Code:    ErrCodeInvalidParams,
+			Message: "sampling request has no messages",
+		}
+	}
+
+	// Never let a server consume more tokens than configured
+	if limit == 0 {
+		limit = DefaultMaxSamplingTokens
+	}
+	if request.MaxTokens <= 0 || request.MaxTokens > limit {
+		request.MaxTokens = limit
+	}
+
+	result, err := handler(ctx, &request)
+	if err == ErrUserRejected {
+		return nil, &JSONRPCError{
+			Code:    ErrCodeUserRejected,
+			Message: err.Error(),
+		}
+	}
+	if err != nil {
+		return nil, &JSONRPCError{
+			Code:    ErrCodeInternal,
+			Message: err.Error(),
+		}
+	}
+	return result, nil
+}
+
+func (c *MCPClient) handleElicitation(ctx context.Context, params json.RawMessage) (interface{}, *JSONRPCError) {
+	c.mu.RLock()
+	handler := c.elicitationHandler
+	advertised := c.clientCapabilities.Elicitation != nil
+	c.mu.RUnlock()
+
+	// Servers must not send requests the client did not advertise
+	if handler == nil || !advertised || !c.Supports(FeatureElicitation) {
+		return nil, &JSONRPCError{
+			Code:    ErrCodeMethodNotFound,
+			Message: "elicitation not supported by the client",
+		}
+	}
+
+	var request ElicitRequest
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, &JSONRPCError{
+			Code:    ErrCodeInvalidParams,
+			Message: fmt.Sprintf("invalid elicitation request: %v", err),
+		}
+	}
+	if request.RequestedSchema.Type != "object" {
+		return nil, &JSONRPCError{
+			Code:    ErrCodeInvalidParams,
+			Message: "elicitation schema must be an object",
+		}
+	}
+
+	result, err := handler(ctx, &request)
+	if err != nil {
+		return nil, &JSONRPCError{
+			Code:    ErrCodeInternal,
+			Message: err.Error(),
+		}
+	}
+
+	switch result.Action {
+	case ElicitAccept:
+		// Never send data that does not match what the server asked for
+		if err := request.RequestedSchema.Validate(result.Content); err != nil {
+			return nil, &JSONRPCError{
+				Code:    ErrCodeInternal,
+				Message: fmt.Sprintf("invalid elicitation content: %v", err),
+			}
+		}
+	case ElicitDecline, ElicitCancel:
+		result.Content = nil
+	default:
+		return nil, &JSONRPCError{
+			Code:    ErrCodeInternal,
+			Message: fmt.Sprintf("invalid elicitation action: %s", result.Action),
+		}
+	}
+	return result, nil
+}
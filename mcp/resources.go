@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ListResources returns the resources exposed by the server, following the
+// pagination
+func (c *MCPClient) ListResources(ctx context.Context) ([]Resource, error) {
+	var resources []Resource
+	err := c.listAll(ctx, "resources/list", func(result []byte) (string, error) {
+		var page ListResourcesResponse
+		if err := json.Unmarshal(result, &page); err != nil {
+			return "", err
+		}
+		resources = append(resources, page.Resources...)
+		return page.NextCursor, nil
+	})
+	return resources, err
+}
+
+// ListResourceTemplates returns the resource templates exposed by the
+// server, following the pagination
+func (c *MCPClient) ListResourceTemplates(ctx context.Context) ([]ResourceTemplate, error) {
+	var templates []ResourceTemplate
+	err := c.listAll(ctx, "resources/templates/list", func(result []byte) (string, error) {
+		var page ListResourceTemplatesResponse
+		if err := json.Unmarshal(result, &page); err != nil {
+			return "", err
+		}
+		templates = append(templates, page.ResourceTemplates...)
+		return page.NextCursor, nil
+	})
+	return templates, err
+}
+
+// ReadResource returns the contents of a resource
+func (c *MCPClient) ReadResource(ctx context.Context, uri string) (*ReadResourceResponse, error) {
+	if !c.IsConnected() {
+		return nil, fmt.Errorf("client not connected")
+	}
+
+	request := JSONRPCRequest{
+		JSONRpc: "2.0",
+		ID:      c.nextRequestID(),
+		Method:  "resources/read",
+		Params:  ReadResourceRequest{URI: uri},
+	}
+
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send resources/read request: %w", err)
+	}
+
+	if response.Error != nil {
+		return nil, fmt.Errorf("resources/read failed: %s", response.Error.Message)
+	}
+
+	var readResponse ReadResourceResponse
+	resultBytes, err := json.Marshal(response.Result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal resource response: %w", err)
+	}
+
+	if err := json.Unmarshal(resultBytes, &readResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal resource response: %w", err)
+	}
+
+	return &readResponse, nil
+}
+
+// Capabilities returns the capabilities announced by the server during
+// initialize, nil before the connection
+func (c *MCPClient) Capabilities() *ServerCapabilities {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.capabilities
+}
+
+// listAll sends a paginated list request until the last page. decode
+// collects the items of a page and returns its next cursor
+func (c *MCPClient) listAll(ctx context.Context, method string, decode func(result []byte) (string, error)) error {
+	if !c.IsConnected() {
+		return fmt.Errorf("client not connected")
+	}
+
+	cursor := ""
+	for page := 0; page < maxListPages; page++ {
+		request := JSONRPCRequest{
+			JSONRpc: "2.0",
+			ID:      c.nextRequestID(),
+			Method:  method,
+			Params:  ListResourcesRequest{Cursor: cursor},
+		}
+
+		response, err := c.sendRequest(ctx, request)
+		if err != nil {
+			return fmt.Errorf("failed to send %s request: %w", method, err)
+		}
+
+		if response.Error != nil {
+			return fmt.Errorf("%s failed: %s", method, response.Error.Message)
+		}
+
+		resultBytes, err := json.Marshal(response.Result)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s response: %w", method, err)
+		}
+
+		next, err := decode(resultBytes)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal %s response: %w", method, err)
+		}
+		if next == "" || next == cursor {
+			return nil
+		}
+		cursor = next
+	}
+
+	return fmt.Errorf("%s returned more than %d pages", method, maxListPages)
+}
@@ -0,0 +1,157 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultCallTimeout is the deadline applied to tools/call when neither
+	// MCPConfig.CallTimeout nor a per tool timeout is set
+	DefaultCallTimeout = 2 * time.Minute
+	// DefaultRetryBackoff is the delay before the first retry
+	DefaultRetryBackoff = 500 * time.Millisecond
+	// maxRetryBackoff caps the exponential backoff
+	maxRetryBackoff = 10 * time.Second
+	// DefaultMaxReconnects is the number of attempts to establish again a
+	// dropped WebSocket connection
+	DefaultMaxReconnects = 5
+)
+
+// NotificationCancelled is sent to the server when the client gives up on a
+// request
+const NotificationCancelled = "notifications/cancelled"
+
+// idempotentMethods can be safely sent again when the transport fails
+var idempotentMethods = map[string]bool{
+	"ping":                     true,
+	"tools/list":               true,
+	"prompts/list":             true,
+	"prompts/get":              true,
+	"completion/complete":      true,
+	"resources/list":           true,
+	"resources/read":           true,
+	"resources/templates/list": true,
+}
+
+// TransportError marks failures of the underlying transport, as opposed to
+// JSON-RPC errors returned by the server
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransportError returns true if err is (or wraps) a TransportError
+func IsTransportError(err error) bool {
+	var transportErr *TransportError
+	return errors.As(err, &transportErr)
+}
+
+// ToolTimeout returns the deadline applied to calls of the given tool
+func (c *MCPClient) ToolTimeout(name string) time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if timeout, exists := c.config.ToolTimeouts[name]; exists && timeout > 0 {
+		return timeout
+	}
+	if c.config.CallTimeout > 0 {
+		return c.config.CallTimeout
+	}
+	return DefaultCallTimeout
+}
+
+// sendRequest sends a request and waits for its response. Idempotent methods
+// are retried with exponential backoff when the transport fails, and the
+// server is notified when the caller gives up on a request
+func (c *MCPClient) sendRequest(ctx context.Context, request JSONRPCRequest) (*JSONRPCResponse, error) {
+	// Requests without an explicit deadline use the connection timeout
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+
+	attempts := 1
+	if idempotentMethods[request.Method] && c.config.MaxRetries > 0 {
+		attempts += c.config.MaxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(c.backoff(attempt)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			// Never reuse the id of a request the server may have seen
+			request.ID = c.nextRequestID()
+		}
+
+		response, err := c.roundTrip(ctx, request)
+		if err == nil {
+			return response, nil
+		}
+		lastErr = err
+
+		if ctx.Err() != nil {
+			c.notifyCancelled(request, ctx.Err())
+			return nil, err
+		}
+		if !IsTransportError(err) {
+			return nil, err
+		}
+	}
+
+	if attempts > 1 {
+		return nil, fmt.Errorf("%s failed after %d attempts: %w", request.Method, attempts, lastErr)
+	}
+	return nil, lastErr
+}
+
+// backoff returns the delay before the given retry attempt
+func (c *MCPClient) backoff(attempt int) time.Duration {
+	delay := c.config.RetryBackoff
+	if delay <= 0 {
+		delay = DefaultRetryBackoff
+	}
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay
+}
+
+// notifyCancelled tells the server to stop processing a request. The
+// initialize request must never be cancelled
+func (c *MCPClient) notifyCancelled(request JSONRPCRequest, reason error) {
+	c.mu.RLock()
+	transport, ctx := c.transport, c.ctx
+	c.mu.RUnlock()
+	if request.Method == "initialize" || transport == nil || ctx == nil || ctx.Err() != nil {
+		return
+	}
+
+	notification := JSONRPCRequest{
+		JSONRpc: "2.0",
+		Method:  NotificationCancelled,
+		Params: CancelledNotification{
+			RequestID: request.ID,
+			Reason:    reason.Error(),
+		},
+	}
+	if err := c.sendNotification(notification); err != nil {
+		c.printf("Warning: failed to cancel request %v: %v\n", request.ID, err)
+	}
+}
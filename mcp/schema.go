@@ -0,0 +1,289 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ValidateSchema checks value against a JSON schema. Only the subset of JSON
+// Schema used by MCP tool and elicitation schemas is supported: type, enum,
+// const, properties, required, additionalProperties, items, anyOf, oneOf and
+// the usual numeric, string and array bounds. Unknown keywords are ignored.
+func ValidateSchema(schema interface{}, value interface{}) error {
+	s, err := toSchemaMap(schema)
+	if err != nil {
+		return err
+	}
+	v, err := normalizeValue(value)
+	if err != nil {
+		return err
+	}
+	return validate(s, v, "$")
+}
+
+// Validate checks value against the tool schema
+func (t ToolSchema) Validate(value interface{}) error {
+	return ValidateSchema(t, value)
+}
+
+// toSchemaMap converts typed schemas (e.g. ToolSchema) to their generic form
+func toSchemaMap(schema interface{}) (map[string]interface{}, error) {
+	if m, ok := schema.(map[string]interface{}); ok {
+		return m, nil
+	}
+	b, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return m, nil
+}
+
+// normalizeValue converts Go values to the types produced by encoding/json
+func normalizeValue(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case nil, bool, string, float64, map[string]interface{}, []interface{}:
+		return value, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+	return v, nil
+}
+
+func validate(schema map[string]interface{}, value interface{}, path string) error {
+	if types := schemaTypes(schema); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if hasType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), typeOf(value))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if equalValues(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+		}
+	}
+	if c, ok := schema["const"]; ok && !equalValues(c, value) {
+		return fmt.Errorf("%s: expected %v", path, c)
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		if countMatches(anyOf, value, path) == 0 {
+			return fmt.Errorf("%s: does not match any of the allowed schemas", path)
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		if countMatches(oneOf, value, path) != 1 {
+			return fmt.Errorf("%s: must match exactly one of the allowed schemas", path)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return validateObject(schema, v, path)
+	case []interface{}:
+		return validateArray(schema, v, path)
+	case string:
+		return validateString(schema, v, path)
+	case float64:
+		return validateNumber(schema, v, path)
+	}
+	return nil
+}
+
+func validateObject(schema map[string]interface{}, value map[string]interface{}, path string) error {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, exists := value[name]; !exists {
+				return fmt.Errorf("%s: missing required property '%s'", path, name)
+			}
+		}
+	}
+
+	// Iterate in a stable order so errors are reproducible
+	keys := make([]string, 0, len(value))
+	for k := range value {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		childPath := path + "." + k
+		if propSchema, ok := properties[k].(map[string]interface{}); ok {
+			if err := validate(propSchema, value[k], childPath); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unexpected property", childPath)
+			}
+		case map[string]interface{}:
+			if err := validate(additional, value[k], childPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateArray(schema map[string]interface{}, value []interface{}, path string) error {
+	if min, ok := schema["minItems"].(float64); ok && float64(len(value)) < min {
+		return fmt.Errorf("%s: expected at least %v items", path, min)
+	}
+	if max, ok := schema["maxItems"].(float64); ok && float64(len(value)) > max {
+		return fmt.Errorf("%s: expected at most %v items", path, max)
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range value {
+			if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateString(schema map[string]interface{}, value string, path string) error {
+	length := float64(len([]rune(value)))
+	if min, ok := schema["minLength"].(float64); ok && length < min {
+		return fmt.Errorf("%s: expected at least %v characters", path, min)
+	}
+	if max, ok := schema["maxLength"].(float64); ok && length > max {
+		return fmt.Errorf("%s: expected at most %v characters", path, max)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err == nil && !re.MatchString(value) {
+			return fmt.Errorf("%s: does not match pattern %s", path, pattern)
+		}
+	}
+	return nil
+}
+
+func validateNumber(schema map[string]interface{}, value float64, path string) error {
+	if min, ok := schema["minimum"].(float64); ok && value < min {
+		return fmt.Errorf("%s: must be >= %v", path, min)
+	}
+	if max, ok := schema["maximum"].(float64); ok && value > max {
+		return fmt.Errorf("%s: must be <= %v", path, max)
+	}
+	if min, ok := schema["exclusiveMinimum"].(float64); ok && value <= min {
+		return fmt.Errorf("%s: must be > %v", path, min)
+	}
+	if max, ok := schema["exclusiveMaximum"].(float64); ok && value >= max {
+		return fmt.Errorf("%s: must be < %v", path, max)
+	}
+	return nil
+}
+
+func countMatches(schemas []interface{}, value interface{}, path string) int {
+	matches := 0
+	for _, s := range schemas {
+		if sm, ok := s.(map[string]interface{}); ok && validate(sm, value, path) == nil {
+			matches++
+		}
+	}
+	return matches
+}
+
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		if t == "" {
+			return nil
+		}
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func hasType(value interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	// Unknown types are not enforced
+	return true
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func equalValues(a, b interface{}) bool {
+	ab, errA := json.Marshal(a)
+	bb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ab) == string(bb)
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fmount/ocstack/mcp"
+)
+
+// sessionIdleTimeout is how long a Streamable HTTP session is kept without
+// requests. Clients are expected to end their session with a DELETE, the
+// timeout takes care of the others
+const sessionIdleTimeout = time.Hour
+
+// newSession creates a Streamable HTTP session and returns its id
+func (s *Server) newSession() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	now := time.Now()
+	for session, lastSeen := range s.sessions {
+		if now.Sub(lastSeen) > sessionIdleTimeout {
+			delete(s.sessions, session)
+		}
+	}
+	s.sessions[hex.EncodeToString(id)] = now
+	s.sessionsIssued = true
+	return hex.EncodeToString(id), nil
+}
+
+// requireSession returns true once a session has been issued: the requests
+// without session are only accepted from the clients of the protocol
+// versions predating Streamable HTTP, as long as no client uses sessions
+func (s *Server) requireSession() bool {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	return s.sessionsIssued
+}
+
+// touchSession records the activity of a session, and returns false if the
+// session is unknown or expired
+func (s *Server) touchSession(id string) bool {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	lastSeen, exists := s.sessions[id]
+	if !exists || time.Since(lastSeen) > sessionIdleTimeout {
+		delete(s.sessions, id)
+		return false
+	}
+	s.sessions[id] = time.Now()
+	return true
+}
+
+func (s *Server) endSession(id string) {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	delete(s.sessions, id)
+}
+
+// sameOrigin returns false for browser requests coming from another site,
+// which protects servers listening on localhost from DNS rebinding
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// authorized returns true when the request carries the bearer token of the
+// server, or when no token is required
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="ocstack"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// acceptsEventStream returns true if the client can read the response of a
+// POST as an event stream
+func acceptsEventStream(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept") {
+		if strings.Contains(value, "text/event-stream") {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingRequest - a request sent to a Streamable HTTP client, its response
+// is only accepted from the session it was sent to
+type pendingRequest struct {
+	session  string
+	response chan mcp.JSONRPCResponse
+}
+
+// requestOverStream sends a request on the event stream of the current POST,
+// and waits for the client to POST its response
+func (s *Server) requestOverStream(ctx context.Context, stream *eventStream, session string, method string, params interface{}) (*mcp.JSONRPCResponse, error) {
+	id := s.nextRequestID()
+	pending := &pendingRequest{session: session, response: make(chan mcp.JSONRPCResponse, 1)}
+	s.requestMu.Lock()
+	s.requests[id] = pending
+	s.requestMu.Unlock()
+	defer func() {
+		s.requestMu.Lock()
+		delete(s.requests, id)
+		s.requestMu.Unlock()
+	}()
+
+	err := stream.send(mcp.JSONRPCRequest{
+		JSONRpc: "2.0",
+		ID:      id,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case response := <-pending.response:
+		return &response, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// deliver routes a response POSTed by a client to the request waiting for
+// it. Responses to unknown or abandoned requests are dropped
+func (s *Server) deliver(session string, response mcp.JSONRPCResponse) {
+	id, _ := response.ID.(string)
+	s.requestMu.Lock()
+	pending, exists := s.requests[id]
+	if exists && pending.session == session {
+		delete(s.requests, id)
+	} else {
+		exists = false
+	}
+	s.requestMu.Unlock()
+
+	if exists {
+		pending.response <- response
+	}
+}
+
+// eventStream answers a Streamable HTTP request. The response switches to
+// an event stream on the first notification sent while the request is
+// handled, plain JSON is returned otherwise
+type eventStream struct {
+	w       http.ResponseWriter
+	mu      sync.Mutex
+	started bool
+	// set once the response is written, later notifications are dropped
+	done bool
+}
+
+func (e *eventStream) notify(ctx context.Context, method string, params interface{}) error {
+	return e.send(mcp.JSONRPCRequest{
+		JSONRpc: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+// send writes a notification or a request, switching the response to an
+// event stream
+func (e *eventStream) send(message interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.done {
+		return nil
+	}
+	if !e.started {
+		e.w.Header().Set("Content-Type", "text/event-stream")
+		e.w.Header().Set("Cache-Control", "no-cache")
+		e.w.WriteHeader(http.StatusOK)
+		e.started = true
+	}
+	return e.writeEvent(message)
+}
+
+// respond writes the response of the request, closing the stream
+func (e *eventStream) respond(response *mcp.JSONRPCResponse) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.done = true
+	if !e.started {
+		writeJSON(e.w, http.StatusOK, response)
+		return
+	}
+	e.writeEvent(response)
+}
+
+func (e *eventStream) writeEvent(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(e.w, "event: message\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	if flusher, ok := e.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fmount/ocstack/mcp"
+)
+
+const initializeRequest = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1.0.0"}}}`
+
+func TestBearerToken(t *testing.T) {
+	s := New("test", "1.0.0")
+	s.SetBearerToken("secret")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"no credentials", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer other", http.StatusUnauthorized},
+		{"basic credentials", "Basic c2VjcmV0", http.StatusUnauthorized},
+		{"token", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL, strings.NewReader(initializeRequest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json, text/event-stream")
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
+
+// post sends body to the server, with the given session id when not empty
+func post(t *testing.T, url string, session string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if session != "" {
+		req.Header.Set("Mcp-Session-Id", session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestSessionRequired(t *testing.T) {
+	ts := httptest.NewServer(New("test", "1.0.0"))
+	defer ts.Close()
+
+	const toolsList = `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`
+	// Clients predating Streamable HTTP don't use sessions
+	if resp := post(t, ts.URL, "", toolsList); resp.StatusCode != http.StatusOK {
+		t.Fatalf("request without session before any session was issued: status = %d, want 200", resp.StatusCode)
+	}
+
+	session := post(t, ts.URL, "", initializeRequest).Header.Get("Mcp-Session-Id")
+	if session == "" {
+		t.Fatal("initialize did not issue a session")
+	}
+
+	tests := []struct {
+		name    string
+		session string
+		body    string
+		want    int
+	}{
+		{"session", session, toolsList, http.StatusOK},
+		{"no session", "", toolsList, http.StatusBadRequest},
+		{"no session batch", "", "[" + toolsList + "]", http.StatusBadRequest},
+		{"no session notification", "", `{"jsonrpc":"2.0","method":"notifications/initialized"}`, http.StatusBadRequest},
+		{"unknown session", "unknown", toolsList, http.StatusNotFound},
+		{"initialize without session", "", initializeRequest, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := post(t, ts.URL, tt.session, tt.body); resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestOverHTTP(t *testing.T) {
+	s := New("test", "1.0.0")
+	s.AddTool(mcp.MCPTool{
+		Name:        "roots",
+		InputSchema: mcp.ToolSchema{Type: "object"},
+	}, func(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResponse, error) {
+		response, err := Request(ctx, mcp.RequestListRoots, nil)
+		if err != nil {
+			return nil, err
+		}
+		if response.Error != nil {
+			return nil, fmt.Errorf("roots/list failed: %s", response.Error.Message)
+		}
+		data, err := json.Marshal(response.Result)
+		if err != nil {
+			return nil, err
+		}
+		return TextResult(string(data)), nil
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := mcp.NewClient(mcp.MCPConfig{
+		Transport: mcp.TransportHTTP,
+		ServerURL: ts.URL,
+		Roots:     []mcp.Root{{URI: "file:///workspace", Name: "workspace"}},
+	})
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Disconnect()
+
+	result, err := client.CallTool(ctx, "roots", nil)
+	if err != nil {
+		t.Fatalf("CallTool() error = %v", err)
+	}
+	if result.IsError || len(result.Content) != 1 || !strings.Contains(result.Content[0].Text, "file:///workspace") {
+		t.Errorf("CallTool() = %+v, want the roots of the client", result)
+	}
+}
+
+func TestResponseFromAnotherSession(t *testing.T) {
+	s := New("test", "1.0.0")
+	pending := &pendingRequest{session: "a", response: make(chan mcp.JSONRPCResponse, 1)}
+	s.requests["ocstack-1"] = pending
+
+	s.deliver("b", mcp.JSONRPCResponse{JSONRpc: "2.0", ID: "ocstack-1", Result: "b"})
+	s.deliver("a", mcp.JSONRPCResponse{JSONRpc: "2.0", ID: "ocstack-1", Result: "a"})
+	if response := <-pending.response; response.Result != "a" {
+		t.Errorf("delivered the response of session %v", response.Result)
+	}
+}
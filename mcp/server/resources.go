@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fmount/ocstack/mcp"
+)
+
+// PromptHandler renders a prompt with the given arguments, which have been
+// validated against the prompt definition
+type PromptHandler func(ctx context.Context, args map[string]string) (*mcp.GetPromptResponse, error)
+
+// ResourceHandler returns the contents of a resource
+type ResourceHandler func(ctx context.Context, uri string) (*mcp.ReadResourceResponse, error)
+
+// AddPrompt registers a prompt, replacing the existing one with the same name
+func (s *Server) AddPrompt(prompt mcp.Prompt, handler PromptHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.promptHandlers[prompt.Name]; exists {
+		for i := range s.prompts {
+			if s.prompts[i].Name == prompt.Name {
+				s.prompts[i] = prompt
+			}
+		}
+	} else {
+		s.prompts = append(s.prompts, prompt)
+	}
+	s.promptHandlers[prompt.Name] = handler
+}
+
+// Prompts returns the registered prompts
+func (s *Server) Prompts() []mcp.Prompt {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]mcp.Prompt{}, s.prompts...)
+}
+
+// AddResource registers a resource, replacing the existing one with the same
+// URI
+func (s *Server) AddResource(resource mcp.Resource, handler ResourceHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.resourceHandlers[resource.URI]; exists {
+		for i := range s.resources {
+			if s.resources[i].URI == resource.URI {
+				s.resources[i] = resource
+			}
+		}
+	} else {
+		s.resources = append(s.resources, resource)
+	}
+	s.resourceHandlers[resource.URI] = handler
+}
+
+// AddResourceTemplate registers a resource template. Reads of URIs matching
+// the template, and not registered as resources, are sent to handler
+func (s *Server) AddResourceTemplate(template mcp.ResourceTemplate, handler ResourceHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.templateHandlers[template.URITemplate]; exists {
+		for i := range s.templates {
+			if s.templates[i].URITemplate == template.URITemplate {
+				s.templates[i] = template
+			}
+		}
+	} else {
+		s.templates = append(s.templates, template)
+	}
+	s.templateHandlers[template.URITemplate] = handler
+}
+
+// Resources returns the registered resources
+func (s *Server) Resources() []mcp.Resource {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]mcp.Resource{}, s.resources...)
+}
+
+// ResourceTemplates returns the registered resource templates
+func (s *Server) ResourceTemplates() []mcp.ResourceTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]mcp.ResourceTemplate{}, s.templates...)
+}
+
+func (s *Server) getPrompt(ctx context.Context, params json.RawMessage) (interface{}, *mcp.JSONRPCError) {
+	var request mcp.GetPromptRequest
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, invalidParams(err)
+	}
+
+	s.mu.RLock()
+	handler, exists := s.promptHandlers[request.Name]
+	var prompt mcp.Prompt
+	for _, p := range s.prompts {
+		if p.Name == request.Name {
+			prompt = p
+		}
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, &mcp.JSONRPCError{
+			Code:    mcp.ErrCodeInvalidParams,
+			Message: fmt.Sprintf("unknown prompt: %s", request.Name),
+		}
+	}
+	if err := prompt.ValidateArguments(request.Arguments); err != nil {
+		return nil, invalidParams(err)
+	}
+
+	start := time.Now()
+	result, err := handler(ctx, request.Arguments)
+	s.logger.Printf("prompts/get %s (%s)", request.Name, time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		return nil, &mcp.JSONRPCError{
+			Code:    mcp.ErrCodeInternal,
+			Message: fmt.Sprintf("error rendering %s: %v", request.Name, err),
+		}
+	}
+	return result, nil
+}
+
+func (s *Server) readResource(ctx context.Context, params json.RawMessage) (interface{}, *mcp.JSONRPCError) {
+	var request mcp.ReadResourceRequest
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, invalidParams(err)
+	}
+
+	s.mu.RLock()
+	handler, exists := s.resourceHandlers[request.URI]
+	if !exists {
+		for _, template := range s.templates {
+			if matchTemplate(template.URITemplate, request.URI) {
+				handler, exists = s.templateHandlers[template.URITemplate], true
+				break
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, &mcp.JSONRPCError{
+			Code:    mcp.ErrCodeResourceNotFound,
+			Message: fmt.Sprintf("resource not found: %s", request.URI),
+		}
+	}
+
+	start := time.Now()
+	result, err := handler(ctx, request.URI)
+	s.logger.Printf("resources/read %s (%s)", request.URI, time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		return nil, &mcp.JSONRPCError{
+			Code:    mcp.ErrCodeInternal,
+			Message: fmt.Sprintf("error reading %s: %v", request.URI, err),
+		}
+	}
+	return result, nil
+}
+
+// templateVariable matches the {name} expressions of a URI template
+var templateVariable = regexp.MustCompile(`\{[^}]*\}`)
+
+// matchTemplate returns true if uri is an expansion of the URI template.
+// Simple expressions match a single path segment, {+name} and {/name...}
+// any string
+func matchTemplate(template string, uri string) bool {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, loc := range templateVariable.FindAllStringIndex(template, -1) {
+		pattern.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+		expression := template[loc[0]+1 : loc[1]-1]
+		if strings.HasPrefix(expression, "+") || strings.HasPrefix(expression, "/") || strings.HasSuffix(expression, "*") {
+			pattern.WriteString(".*")
+		} else {
+			pattern.WriteString("[^/]+")
+		}
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]))
+	pattern.WriteString("$")
+
+	matched, err := regexp.MatchString(pattern.String(), uri)
+	return err == nil && matched
+}
@@ -0,0 +1,574 @@
+// Package server implements an MCP server exposing tools, prompts and
+// resources over stdio, Streamable HTTP and WebSocket. Tools are registered
+// with handlers receiving the raw arguments (AddTool), or typed Go values
+// along with a generated input schema (AddTypedTool, AddStructuredTool)
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fmount/ocstack/mcp"
+)
+
+// maxMessageSize is the largest request accepted by the server
+const maxMessageSize = 16 * 1024 * 1024
+
+// ToolHandler executes a tool call. Errors are returned to the client as a
+// tool result with isError set, so the LLM can see them
+type ToolHandler func(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResponse, error)
+
+// Server is an MCP server exposing a set of tools, prompts and resources
+type Server struct {
+	info     mcp.ServerInfo
+	mu       sync.RWMutex
+	tools    []mcp.MCPTool
+	handlers map[string]ToolHandler
+	logger   *log.Logger
+
+	prompts        []mcp.Prompt
+	promptHandlers map[string]PromptHandler
+
+	// resource handlers by URI, template handlers by URI template
+	resources        []mcp.Resource
+	templates        []mcp.ResourceTemplate
+	resourceHandlers map[string]ResourceHandler
+	templateHandlers map[string]ResourceHandler
+
+	// Streamable HTTP sessions, with the time of their last request. Once
+	// a session is issued, requests must carry one
+	sessionMu      sync.Mutex
+	sessions       map[string]time.Time
+	sessionsIssued bool
+
+	// requests sent to the Streamable HTTP clients, waiting for the
+	// response the client POSTs, by id
+	requestMu     sync.Mutex
+	requests      map[string]*pendingRequest
+	lastRequestID atomic.Uint64
+
+	// bearer token required from the HTTP and WebSocket clients
+	token string
+}
+
+// New creates a server with the given name and version. Logs are written to
+// stderr, since stdout carries the protocol on stdio
+func New(name string, version string) *Server {
+	return &Server{
+		info: mcp.ServerInfo{
+			Name:    name,
+			Version: version,
+		},
+		handlers:         make(map[string]ToolHandler),
+		logger:           log.New(os.Stderr, "["+name+"] ", log.LstdFlags),
+		promptHandlers:   make(map[string]PromptHandler),
+		resourceHandlers: make(map[string]ResourceHandler),
+		templateHandlers: make(map[string]ResourceHandler),
+		sessions:         make(map[string]time.Time),
+		requests:         make(map[string]*pendingRequest),
+	}
+}
+
+// SetLogger replaces the default stderr logger
+func (s *Server) SetLogger(logger *log.Logger) {
+	s.logger = logger
+}
+
+// SetBearerToken requires the HTTP and WebSocket clients to send token as
+// bearer credentials, an empty token disables the check
+func (s *Server) SetBearerToken(token string) {
+	s.token = token
+}
+
+// AddTool registers a tool, replacing the existing one with the same name
+func (s *Server) AddTool(tool mcp.MCPTool, handler ToolHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.handlers[tool.Name]; exists {
+		for i := range s.tools {
+			if s.tools[i].Name == tool.Name {
+				s.tools[i] = tool
+			}
+		}
+	} else {
+		s.tools = append(s.tools, tool)
+	}
+	s.handlers[tool.Name] = handler
+}
+
+// RemoveTool unregisters a tool
+func (s *Server) RemoveTool(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.handlers, name)
+	for i := range s.tools {
+		if s.tools[i].Name == name {
+			s.tools = append(s.tools[:i], s.tools[i+1:]...)
+			break
+		}
+	}
+}
+
+// Tools returns the registered tools
+func (s *Server) Tools() []mcp.MCPTool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]mcp.MCPTool{}, s.tools...)
+}
+
+// Handle processes a message and returns the response to send back, or nil
+// for notifications and responses. The responses to the requests sent to the
+// clients are routed by the transports
+func (s *Server) Handle(ctx context.Context, message mcp.JSONRPCMessage) *mcp.JSONRPCResponse {
+	if message.IsNotification() || message.IsResponse() {
+		// initialized and cancelled are the only notifications we expect,
+		// cancellation is handled by the transports
+		return nil
+	}
+
+	response := &mcp.JSONRPCResponse{
+		JSONRpc: "2.0",
+		ID:      message.ID,
+	}
+	result, rpcErr := s.dispatch(ctx, message)
+	if rpcErr != nil {
+		response.Error = rpcErr
+	} else {
+		response.Result = result
+	}
+	return response
+}
+
+func (s *Server) dispatch(ctx context.Context, message mcp.JSONRPCMessage) (interface{}, *mcp.JSONRPCError) {
+	switch message.Method {
+	case "initialize":
+		return s.initialize(message.Params)
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return mcp.ListToolsResponse{Tools: s.Tools()}, nil
+	case "tools/call":
+		return s.callTool(ctx, message.Params)
+	case "prompts/list":
+		return mcp.ListPromptsResponse{Prompts: s.Prompts()}, nil
+	case "prompts/get":
+		return s.getPrompt(ctx, message.Params)
+	case "resources/list":
+		return mcp.ListResourcesResponse{Resources: s.Resources()}, nil
+	case "resources/templates/list":
+		return mcp.ListResourceTemplatesResponse{ResourceTemplates: s.ResourceTemplates()}, nil
+	case "resources/read":
+		return s.readResource(ctx, message.Params)
+	}
+	return nil, &mcp.JSONRPCError{
+		Code:    mcp.ErrCodeMethodNotFound,
+		Message: fmt.Sprintf("method not found: %s", message.Method),
+	}
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, *mcp.JSONRPCError) {
+	var request mcp.InitializeRequest
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, invalidParams(err)
+	}
+	s.logger.Printf("initialize from %s %s (protocol %s)",
+		request.ClientInfo.Name, request.ClientInfo.Version, request.ProtocolVersion)
+
+	// Answer with the requested version when we support it, otherwise
+	// propose our latest one and let the client decide
+	version := mcp.LatestProtocolVersion
+	if mcp.IsSupportedProtocolVersion(request.ProtocolVersion) {
+		version = request.ProtocolVersion
+	}
+
+	// Prompts and resources are only announced when registered
+	capabilities := mcp.ServerCapabilities{
+		Tools: &mcp.ToolsCapability{},
+	}
+	s.mu.RLock()
+	if len(s.prompts) > 0 {
+		capabilities.Prompts = &mcp.PromptsCapability{}
+	}
+	if len(s.resources) > 0 || len(s.templates) > 0 {
+		capabilities.Resources = &mcp.ResourcesCapability{}
+	}
+	s.mu.RUnlock()
+
+	return mcp.InitializeResponse{
+		ProtocolVersion: version,
+		Capabilities:    capabilities,
+		ServerInfo:      s.info,
+	}, nil
+}
+
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (interface{}, *mcp.JSONRPCError) {
+	var request mcp.CallToolRequest
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, invalidParams(err)
+	}
+
+	s.mu.RLock()
+	handler, exists := s.handlers[request.Name]
+	var tool mcp.MCPTool
+	for _, t := range s.tools {
+		if t.Name == request.Name {
+			tool = t
+		}
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, &mcp.JSONRPCError{
+			Code:    mcp.ErrCodeInvalidParams,
+			Message: fmt.Sprintf("unknown tool: %s", request.Name),
+		}
+	}
+
+	args := request.Arguments
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	// Invalid arguments are reported as a tool error, so the LLM can fix
+	// the call
+	if err := tool.InputSchema.Validate(args); err != nil {
+		return errorResult(fmt.Sprintf("invalid arguments for %s: %v", request.Name, err)), nil
+	}
+
+	if request.Meta != nil && request.Meta.ProgressToken != nil {
+		ctx = context.WithValue(ctx, progressTokenKey{}, request.Meta.ProgressToken)
+	}
+
+	start := time.Now()
+	result, err := handler(ctx, args)
+	s.logger.Printf("tools/call %s (%s)", request.Name, time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		return errorResult(fmt.Sprintf("error executing %s: %v", request.Name, err)), nil
+	}
+	if result == nil {
+		result = &mcp.CallToolResponse{Content: []mcp.ToolResult{}}
+	}
+	return result, nil
+}
+
+// TextResult returns a tool result made of a single text block
+func TextResult(text string) *mcp.CallToolResponse {
+	return &mcp.CallToolResponse{
+		Content: []mcp.ToolResult{{Type: mcp.ContentText, Text: text}},
+	}
+}
+
+// notifier sends a notification to the client of the current request
+type notifier func(ctx context.Context, method string, params interface{}) error
+
+type notifierKey struct{}
+
+// requester sends a request to the client of the current request, and waits
+// for its response
+type requester func(ctx context.Context, method string, params interface{}) (*mcp.JSONRPCResponse, error)
+
+type requesterKey struct{}
+
+type progressTokenKey struct{}
+
+// Progress reports the progress of the current tool call, when the client
+// asked for it with a progress token. total is zero when unknown. Over HTTP
+// the notifications require a client accepting event streams
+func Progress(ctx context.Context, progress float64, total float64, message string) error {
+	token := ctx.Value(progressTokenKey{})
+	notify, _ := ctx.Value(notifierKey{}).(notifier)
+	if token == nil || notify == nil {
+		return nil
+	}
+	return notify(ctx, mcp.NotificationProgress, mcp.ProgressNotification{
+		ProgressToken: token,
+		Progress:      progress,
+		Total:         total,
+		Message:       message,
+	})
+}
+
+// Request sends a request to the client of the current request, e.g.
+// roots/list, and returns its response. Over HTTP the request requires a
+// client accepting event streams, which POSTs the response back
+func Request(ctx context.Context, method string, params interface{}) (*mcp.JSONRPCResponse, error) {
+	request, _ := ctx.Value(requesterKey{}).(requester)
+	if request == nil {
+		return nil, fmt.Errorf("the client can't receive requests")
+	}
+	return request(ctx, method, params)
+}
+
+// nextRequestID returns the id of a request sent to a client
+func (s *Server) nextRequestID() string {
+	return fmt.Sprintf("ocstack-%d", s.lastRequestID.Add(1))
+}
+
+func errorResult(text string) *mcp.CallToolResponse {
+	result := TextResult(text)
+	result.IsError = true
+	return result
+}
+
+func invalidParams(err error) *mcp.JSONRPCError {
+	return &mcp.JSONRPCError{
+		Code:    mcp.ErrCodeInvalidParams,
+		Message: fmt.Sprintf("invalid params: %v", err),
+	}
+}
+
+// ServeStdio reads newline delimited messages from r and writes the responses
+// to w until r is closed. Requests run concurrently, so that a slow tool does
+// not block pings or cancellations
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	return s.Serve(ctx, mcp.NewLineStream(r, w))
+}
+
+// Serve runs the server on a message stream until the stream is closed
+func (s *Server) Serve(ctx context.Context, stream mcp.MessageStream) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r := &router{s: s}
+	conn := mcp.NewConn(stream, r)
+	conn.SetErrorLog(s.logger)
+	r.conn = conn
+	err := conn.Run(ctx)
+	conn.Wait()
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// router adapts the server to the mcp.Conn JSON-RPC session
+type router struct {
+	s    *Server
+	conn *mcp.Conn
+}
+
+func (r *router) HandleRequest(ctx context.Context, method string, params json.RawMessage) (interface{}, *mcp.JSONRPCError) {
+	ctx = context.WithValue(ctx, notifierKey{}, notifier(r.notify))
+	ctx = context.WithValue(ctx, requesterKey{}, requester(r.request))
+	return r.s.dispatch(ctx, mcp.JSONRPCMessage{Method: method, Params: params})
+}
+
+func (r *router) notify(ctx context.Context, method string, params interface{}) error {
+	return r.conn.Notify(ctx, mcp.JSONRPCRequest{
+		JSONRpc: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+func (r *router) request(ctx context.Context, method string, params interface{}) (*mcp.JSONRPCResponse, error) {
+	return r.conn.Call(ctx, mcp.JSONRPCRequest{
+		JSONRpc: "2.0",
+		ID:      r.s.nextRequestID(),
+		Method:  method,
+		Params:  params,
+	})
+}
+
+// HandleNotification ignores the notifications: initialized and cancelled
+// are the only ones we expect, and cancellation is handled by mcp.Conn
+func (r *router) HandleNotification(method string, params json.RawMessage) {}
+
+// ServeHTTP implements the Streamable HTTP transport: every POST carries a
+// single message or a batch, answered in the response body, or as an event
+// stream when notifications or requests are sent while handling a request.
+// The responses to these requests are POSTed by the client. A session is
+// assigned on initialize, and ended with a DELETE. Server initiated streams
+// (GET) are not offered
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if !s.authorized(r) {
+		unauthorized(w)
+		return
+	}
+	if version := r.Header.Get("MCP-Protocol-Version"); version != "" && !mcp.IsSupportedProtocolVersion(version) {
+		http.Error(w, fmt.Sprintf("unsupported protocol version: %s", version), http.StatusBadRequest)
+		return
+	}
+	sessionID := r.Header.Get("Mcp-Session-Id")
+	if sessionID != "" && !s.touchSession(sessionID) {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		if sessionID == "" {
+			http.Error(w, "missing session id", http.StatusBadRequest)
+			return
+		}
+		s.endSession(sessionID)
+		w.WriteHeader(http.StatusOK)
+		return
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	body = bytes.TrimSpace(body)
+
+	if len(body) > 0 && body[0] == '[' {
+		if sessionID == "" && s.requireSession() {
+			http.Error(w, "missing session id", http.StatusBadRequest)
+			return
+		}
+		s.serveBatch(w, r, body)
+		return
+	}
+
+	var message mcp.JSONRPCMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		writeJSON(w, http.StatusBadRequest, parseError(err))
+		return
+	}
+	if sessionID == "" && message.Method != "initialize" && s.requireSession() {
+		http.Error(w, "missing session id", http.StatusBadRequest)
+		return
+	}
+	if message.IsResponse() {
+		s.deliver(sessionID, message.Response())
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	stream := &eventStream{w: w}
+	ctx := r.Context()
+	if acceptsEventStream(r) {
+		ctx = context.WithValue(ctx, notifierKey{}, notifier(stream.notify))
+		ctx = context.WithValue(ctx, requesterKey{}, requester(func(ctx context.Context, method string, params interface{}) (*mcp.JSONRPCResponse, error) {
+			return s.requestOverStream(ctx, stream, sessionID, method, params)
+		}))
+	}
+	response := s.Handle(ctx, message)
+	if response == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if message.Method == "initialize" && response.Error == nil {
+		// Sessions came with Streamable HTTP, older clients ignore them
+		initialized, _ := response.Result.(mcp.InitializeResponse)
+		if mcp.VersionSupports(initialized.ProtocolVersion, mcp.FeatureStreamableHTTP) {
+			id, err := s.newSession()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Mcp-Session-Id", id)
+		}
+	}
+	stream.respond(response)
+}
+
+// serveBatch handles the messages of a batch concurrently and answers with
+// the batch of their responses
+func (s *Server) serveBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	var batch []mcp.JSONRPCMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		writeJSON(w, http.StatusBadRequest, parseError(err))
+		return
+	}
+
+	sessionID := r.Header.Get("Mcp-Session-Id")
+	responses := make([]*mcp.JSONRPCResponse, len(batch))
+	var wg sync.WaitGroup
+	for i, message := range batch {
+		if message.IsResponse() {
+			s.deliver(sessionID, message.Response())
+			continue
+		}
+		wg.Add(1)
+		go func(i int, message mcp.JSONRPCMessage) {
+			defer wg.Done()
+			responses[i] = s.Handle(r.Context(), message)
+		}(i, message)
+	}
+	wg.Wait()
+
+	var answers []*mcp.JSONRPCResponse
+	for _, response := range responses {
+		if response != nil {
+			answers = append(answers, response)
+		}
+	}
+	if len(answers) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeJSON(w, http.StatusOK, answers)
+}
+
+func parseError(err error) *mcp.JSONRPCResponse {
+	return &mcp.JSONRPCResponse{
+		JSONRpc: "2.0",
+		Error: &mcp.JSONRPCError{
+			Code:    mcp.ErrCodeParse,
+			Message: fmt.Sprintf("parse error: %v", err),
+		},
+	}
+}
+
+// ListenAndServe serves the Streamable HTTP endpoint on /mcp, the WebSocket
+// one on /ws and a health check on /health until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", s)
+	mux.Handle("/ws", s.WebSocketHandler(ctx))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  "healthy",
+			"server":  s.info.Name,
+			"version": s.info.Version,
+			"tools":   len(s.Tools()),
+		})
+	})
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	s.logger.Printf("serving MCP on http://%s/mcp and ws://%s/ws", addr, addr)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fmount/ocstack/mcp"
+)
+
+// TypedToolHandler executes a tool call with the arguments decoded in In
+type TypedToolHandler[In any] func(ctx context.Context, in In) (*mcp.CallToolResponse, error)
+
+// StructuredToolHandler executes a tool call with the arguments decoded in
+// In, and returns its result as Out
+type StructuredToolHandler[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+// AddTypedTool registers a tool whose input schema is generated from In,
+// which must be a struct. Properties are named after the json tag of the
+// fields, and are required unless the field is a pointer or has omitempty.
+// The description tag documents a property, and the enum tag lists its
+// allowed values separated by commas:
+//
+//	type ScaleArgs struct {
+//		Name     string `json:"name" description:"Name of the deployment"`
+//		Replicas int    `json:"replicas" description:"Number of replicas"`
+//		Mode     string `json:"mode,omitempty" enum:"fast,safe"`
+//	}
+//
+// The InputSchema of tool is replaced by the generated one
+func AddTypedTool[In any](s *Server, tool mcp.MCPTool, handler TypedToolHandler[In]) error {
+	schema, err := objectSchema(reflect.TypeOf((*In)(nil)).Elem())
+	if err != nil {
+		return fmt.Errorf("invalid input type for tool %s: %w", tool.Name, err)
+	}
+	tool.InputSchema = schema
+
+	s.AddTool(tool, func(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResponse, error) {
+		var in In
+		if err := decodeArguments(args, &in); err != nil {
+			return nil, err
+		}
+		return handler(ctx, in)
+	})
+	return nil
+}
+
+// AddStructuredTool registers a tool like AddTypedTool, with an output
+// schema generated from Out. The result is returned as structured content,
+// and as its JSON text for the clients that do not support it
+func AddStructuredTool[In, Out any](s *Server, tool mcp.MCPTool, handler StructuredToolHandler[In, Out]) error {
+	output, err := objectSchema(reflect.TypeOf((*Out)(nil)).Elem())
+	if err != nil {
+		return fmt.Errorf("invalid output type for tool %s: %w", tool.Name, err)
+	}
+	tool.OutputSchema = &output
+
+	return AddTypedTool(s, tool, func(ctx context.Context, in In) (*mcp.CallToolResponse, error) {
+		out, err := handler(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		text, err := json.Marshal(out)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode result: %w", err)
+		}
+		result := TextResult(string(text))
+		result.StructuredContent = out
+		return result, nil
+	})
+}
+
+// decodeArguments decodes the validated arguments of a call into in
+func decodeArguments(args map[string]interface{}, in interface{}) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("failed to encode arguments: %w", err)
+	}
+	if err := json.Unmarshal(data, in); err != nil {
+		return fmt.Errorf("failed to decode arguments: %w", err)
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// objectSchema returns the schema of a struct type
+func objectSchema(t reflect.Type) (mcp.ToolSchema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return mcp.ToolSchema{}, fmt.Errorf("%s is not a struct", t)
+	}
+
+	schema, err := typeSchema(t, map[reflect.Type]bool{})
+	if err != nil {
+		return mcp.ToolSchema{}, err
+	}
+	required, _ := schema["required"].([]string)
+	return mcp.ToolSchema{
+		Type:                 "object",
+		Properties:           schema["properties"].(map[string]interface{}),
+		Required:             required,
+		AdditionalProperties: false,
+	}, nil
+}
+
+// typeSchema returns the JSON schema of the values of type t, as encoded by
+// encoding/json. visiting holds the structs being expanded, since recursive
+// types cannot be described without references
+func typeSchema(t reflect.Type, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Interface:
+		// Any value
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// base64 encoded by encoding/json
+			return map[string]interface{}{"type": "string"}, nil
+		}
+		items, err := typeSchema(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := typeSchema(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("recursive type %s is not supported", t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := map[string]interface{}{}
+		var required []string
+		if err := addFields(t, properties, &required, visiting); err != nil {
+			return nil, err
+		}
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// addFields adds the properties of the fields of a struct, flattening the
+// embedded structs like encoding/json does
+func addFields(t reflect.Type, properties map[string]interface{}, required *[]string, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := addFields(embedded, properties, required, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema, err := typeSchema(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if description := field.Tag.Get("description"); description != "" {
+			schema["description"] = description
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			values, err := enumValues(enum, schema["type"])
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			schema["enum"] = values
+		}
+		properties[name] = schema
+
+		optional := field.Type.Kind() == reflect.Pointer || strings.Contains(","+options+",", ",omitempty,")
+		if !optional {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
+
+// enumValues parses the comma separated values of an enum tag
+func enumValues(tag string, kind interface{}) ([]interface{}, error) {
+	var values []interface{}
+	for _, value := range strings.Split(tag, ",") {
+		value = strings.TrimSpace(value)
+		switch kind {
+		case "string":
+			values = append(values, value)
+		case "integer":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid enum value %q: %w", value, err)
+			}
+			values = append(values, n)
+		case "number":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid enum value %q: %w", value, err)
+			}
+			values = append(values, n)
+		default:
+			return nil, fmt.Errorf("enum is not supported for %v values", kind)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fmount/ocstack/mcp"
+	"github.com/gorilla/websocket"
+)
+
+// wsWriteWait bounds the writes of messages without deadline
+const wsWriteWait = 10 * time.Second
+
+// WebSocketHandler returns the handler of the WebSocket transport: every
+// connection is a session carrying one message per frame. Sessions are
+// closed when ctx is cancelled
+func (s *Server) WebSocketHandler(ctx context.Context) http.Handler {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: sameOrigin,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			unauthorized(w)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader already answered with an error
+			s.logger.Printf("WebSocket upgrade failed: %v", err)
+			return
+		}
+		conn.SetReadLimit(maxMessageSize)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			// Unblocks the pending read once the session is over
+			<-ctx.Done()
+			conn.Close()
+		}()
+
+		if err := s.Serve(ctx, &wsStream{conn: conn}); err != nil && ctx.Err() == nil {
+			s.logger.Printf("WebSocket session from %s ended: %v", r.RemoteAddr, err)
+		}
+	})
+}
+
+// wsStream adapts a WebSocket connection to mcp.MessageStream. Pings are
+// answered by the default handler of the connection
+type wsStream struct {
+	conn *websocket.Conn
+	// serializes the writes, which the connection does not support
+	// concurrently
+	mu sync.Mutex
+}
+
+var _ mcp.MessageStream = (*wsStream)(nil)
+
+func (s *wsStream) ReadMessage() ([]byte, error) {
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		if messageType == websocket.TextMessage || messageType == websocket.BinaryMessage {
+			return data, nil
+		}
+	}
+}
+
+func (s *wsStream) WriteMessage(ctx context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(wsWriteWait)
+	}
+	s.conn.SetWriteDeadline(deadline)
+	return s.conn.WriteMessage(websocket.TextMessage, data)
+}
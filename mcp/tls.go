@@ -0,0 +1,78 @@
+package mcp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSConfig holds the TLS settings of a remote MCP server, shared by the
+// HTTP and WebSocket transports
+type TLSConfig struct {
+	// PEM bundle of the CAs trusted in addition to the system ones
+	CAFile string `json:"caFile,omitempty"`
+
+	// Client certificate and key, for servers requiring mTLS
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+
+	// Overrides the name used to verify the server certificate
+	ServerName string `json:"serverName,omitempty"`
+
+	// Disables the verification of the server certificate, only meant for
+	// test environments
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// ClientConfig builds the crypto/tls configuration. A nil TLSConfig returns
+// a nil configuration, meaning the Go defaults
+func (t *TLSConfig) ClientConfig() (*tls.Config, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate found in %s", t.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, fmt.Errorf("both certFile and keyFile are required for client authentication")
+		}
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// newHTTPClient returns an HTTP client using the given TLS configuration
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
+	if tlsConfig == nil {
+		return &http.Client{}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,188 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Directions of the traced frames
+const (
+	TraceSend = "->"
+	TraceRecv = "<-"
+)
+
+// traceTimeFormat is the timestamp of every trace entry
+const traceTimeFormat = "2006-01-02 15:04:05.000000"
+
+// secretHeaders are never written in clear to a trace
+var secretHeaders = []string{"authorization", "cookie", "token", "secret", "password", "api-key", "apikey"}
+
+// Tracer writes the JSON-RPC frames exchanged with a server, the HTTP
+// headers and the stderr of stdio servers, one entry per line. Responses
+// report the latency of the request they answer. It is safe for concurrent
+// use
+type Tracer struct {
+	w  io.Writer
+	mu sync.Mutex
+	// time of the requests waiting for a response, by direction and id
+	started map[string]time.Time
+}
+
+// NewTracer creates a tracer writing to w
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{
+		w:       w,
+		started: make(map[string]time.Time),
+	}
+}
+
+// Message traces a frame, which is either a single message or a batch
+func (t *Tracer) Message(direction string, data []byte) {
+	now := time.Now()
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err == nil {
+			for i, raw := range batch {
+				t.message(now, direction, raw, fmt.Sprintf(" (batch %d/%d)", i+1, len(batch)))
+			}
+			return
+		}
+	}
+	t.message(now, direction, data, "")
+}
+
+func (t *Tracer) message(now time.Time, direction string, data []byte, suffix string) {
+	var message JSONRPCMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		t.write(now, direction, fmt.Sprintf("invalid message%s: %v %s", suffix, err, data))
+		return
+	}
+
+	// Responses travel in the direction opposite to their request
+	requestKey := func(from string) string {
+		return from + idKey(message.ID)
+	}
+	t.mu.Lock()
+	var summary string
+	switch {
+	case message.IsResponse():
+		summary = fmt.Sprintf("response #%s", idKey(message.ID))
+		key := requestKey(TraceRecv)
+		if direction == TraceRecv {
+			key = requestKey(TraceSend)
+		}
+		if started, exists := t.started[key]; exists {
+			summary += fmt.Sprintf(" (%s)", now.Sub(started).Round(time.Microsecond))
+			delete(t.started, key)
+		}
+		if message.Error != nil {
+			summary += " error"
+		}
+	case message.IsNotification():
+		summary = fmt.Sprintf("notification %s", message.Method)
+		// The request will never be answered
+		if message.Method == NotificationCancelled {
+			var cancelled CancelledNotification
+			if json.Unmarshal(message.Params, &cancelled) == nil {
+				delete(t.started, TraceSend+idKey(cancelled.RequestID))
+				delete(t.started, TraceRecv+idKey(cancelled.RequestID))
+			}
+		}
+	default:
+		summary = fmt.Sprintf("request #%s %s", idKey(message.ID), message.Method)
+		t.started[requestKey(direction)] = now
+	}
+	t.mu.Unlock()
+
+	t.write(now, direction, fmt.Sprintf("%s%s %s", summary, suffix, data))
+}
+
+// HTTP traces the headers of an HTTP request or response, described by
+// line (e.g. the method and URL). Secret headers are redacted
+func (t *Tracer) HTTP(direction string, line string, header http.Header, latency time.Duration) {
+	entry := "http " + line
+	if latency > 0 {
+		entry += fmt.Sprintf(" (%s)", latency.Round(time.Microsecond))
+	}
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if isSecretHeader(name) {
+			value = "[REDACTED]"
+		}
+		entry += fmt.Sprintf(" %s=%q", name, value)
+	}
+	t.write(time.Now(), direction, entry)
+}
+
+// Stderr traces a line written by a stdio server on its stderr
+func (t *Tracer) Stderr(line string) {
+	t.write(time.Now(), TraceRecv, "stderr "+line)
+}
+
+func (t *Tracer) write(now time.Time, direction string, entry string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "%s %s %s\n", now.Format(traceTimeFormat), direction, entry)
+}
+
+func isSecretHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range secretHeaders {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// traceHook holds the tracer of a transport or client, which can be changed
+// while the connection is running
+type traceHook struct {
+	tracer atomic.Pointer[Tracer]
+}
+
+// SetTracer starts tracing, a nil tracer stops it
+func (h *traceHook) SetTracer(tracer *Tracer) {
+	h.tracer.Store(tracer)
+}
+
+func (h *traceHook) current() *Tracer {
+	return h.tracer.Load()
+}
+
+// tracedStream traces the messages of a stream while the hook has a tracer
+type tracedStream struct {
+	MessageStream
+	hook *traceHook
+}
+
+func (s tracedStream) ReadMessage() ([]byte, error) {
+	data, err := s.MessageStream.ReadMessage()
+	if tracer := s.hook.current(); tracer != nil && err == nil {
+		tracer.Message(TraceRecv, data)
+	}
+	return data, err
+}
+
+func (s tracedStream) WriteMessage(ctx context.Context, data []byte) error {
+	if tracer := s.hook.current(); tracer != nil {
+		tracer.Message(TraceSend, data)
+	}
+	return s.MessageStream.WriteMessage(ctx, data)
+}
@@ -1,24 +1,30 @@
 package mcp
 
 import (
+	"bufio"
 	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// Transport defines the interface for different MCP communication methods
+// Transport defines the interface for different MCP communication methods.
+// Messages are exchanged through the MessageStream methods, and the
+// JSON-RPC logic is implemented on top of them by Conn
 type Transport interface {
+	MessageStream
 	Connect(ctx context.Context) error
 	Disconnect() error
-	Send(request JSONRPCRequest) error
-	Receive() (*JSONRPCResponse, error)
 	IsConnected() bool
 }
 
@@ -29,6 +35,8 @@ const (
 	TransportStdio     TransportType = "stdio"
 	TransportHTTP      TransportType = "http"
 	TransportWebSocket TransportType = "websocket"
+	// Server running in the same process, see MCPConfig.InProcess
+	TransportInProcess TransportType = "inprocess"
 )
 
 // HTTPTransport implements MCP over HTTP
@@ -37,6 +45,17 @@ type HTTPTransport struct {
 	httpClient *http.Client
 	headers    map[string]string
 	connected  bool
+	// default deadline of requests whose context has none
+	timeout time.Duration
+	auth    Authenticator
+	// negotiated protocol revision and Streamable HTTP session
+	protocolVersion string
+	sessionID       string
+	// messages read from the response bodies
+	inbox *messageQueue
+	mu    sync.RWMutex
+	// traces the headers of the requests and responses
+	traceHook
 }
 
 // NewHTTPTransport creates a new HTTP transport
@@ -45,57 +64,189 @@ func NewHTTPTransport(baseURL string, timeout time.Duration) *HTTPTransport {
 		timeout = 30 * time.Second
 	}
 
+	// The deadline is set per request, so long running tool calls are not
+	// cut by a client wide timeout
 	return &HTTPTransport{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
+		baseURL:    baseURL,
+		httpClient: &http.Client{},
+		timeout:    timeout,
+		inbox:      newMessageQueue(),
 		headers: map[string]string{
 			"Content-Type": "application/json",
-			"Accept":       "application/json",
+			"Accept":       "application/json, text/event-stream",
 		},
 	}
 }
 
+// SetHeaders adds custom headers sent with every request
+func (h *HTTPTransport) SetHeaders(headers map[string]string) {
+	for key, value := range headers {
+		h.headers[key] = value
+	}
+}
+
+// SetAuthenticator sets the provider of the Authorization header
+func (h *HTTPTransport) SetAuthenticator(auth Authenticator) {
+	h.auth = auth
+}
+
+// SetTLSConfig sets the TLS settings used to reach the server
+func (h *HTTPTransport) SetTLSConfig(config *tls.Config) {
+	h.httpClient = newHTTPClient(config)
+}
+
+// SetProtocolVersion enables the headers required by the negotiated
+// protocol revision
+func (h *HTTPTransport) SetProtocolVersion(version string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.protocolVersion = version
+}
+
+// HTTPClient returns the client used by the transport, so that related
+// requests, like the OAuth ones, share its settings
+func (h *HTTPTransport) HTTPClient() *http.Client {
+	return h.httpClient
+}
+
 func (h *HTTPTransport) Connect(ctx context.Context) error {
 	// For HTTP, we don't need a persistent connection, just validate the URL
 	_, err := url.Parse(h.baseURL)
 	if err != nil {
 		return fmt.Errorf("invalid HTTP URL: %w", err)
 	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.inbox.isClosed() {
+		h.inbox = newMessageQueue()
+	}
 	h.connected = true
 	return nil
 }
 
 func (h *HTTPTransport) Disconnect() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	h.connected = false
+	h.inbox.close()
 	return nil
 }
 
 func (h *HTTPTransport) IsConnected() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	return h.connected
 }
 
-func (h *HTTPTransport) Send(request JSONRPCRequest) error {
-	return fmt.Errorf("HTTP transport requires synchronous send/receive")
+// ReadMessage returns the next message read from a response body
+func (h *HTTPTransport) ReadMessage() ([]byte, error) {
+	h.mu.RLock()
+	inbox := h.inbox
+	h.mu.RUnlock()
+	return inbox.pop()
 }
 
-func (h *HTTPTransport) Receive() (*JSONRPCResponse, error) {
-	return nil, fmt.Errorf("HTTP transport requires synchronous send/receive")
-}
+// WriteMessage posts a message to the server. The messages in the response,
+// either a JSON body or an event stream, are queued for ReadMessage
+func (h *HTTPTransport) WriteMessage(ctx context.Context, data []byte) error {
+	h.mu.RLock()
+	connected, inbox := h.connected, h.inbox
+	h.mu.RUnlock()
+	if !connected {
+		return fmt.Errorf("HTTP transport not connected")
+	}
+
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	resp, err := h.post(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	// The server asks for credentials, authorize and try once more
+	if resp.StatusCode == http.StatusUnauthorized && h.auth != nil {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		// The user takes part in the authorization, so it must not be bound
+		// to the deadline of the request
+		authCtx := context.WithoutCancel(ctx)
+		if err := h.auth.Authorize(authCtx, challenge); err != nil {
+			return fmt.Errorf("MCP server authorization failed: %w", err)
+		}
+		retryCtx, cancel := context.WithTimeout(authCtx, h.timeout)
+		defer cancel()
+		resp, err = h.post(retryCtx, data)
+		if err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	// Notifications and responses have no response
+	if resp.StatusCode == http.StatusAccepted {
+		return nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("HTTP request failed with status: %d", resp.StatusCode)
+		// Server side and throttling failures are worth a retry
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return &TransportError{Err: err}
+		}
+		return err
+	}
 
-// SendRequest sends a request and returns the response for HTTP transport
-func (h *HTTPTransport) SendRequest(ctx context.Context, request JSONRPCRequest) (*JSONRPCResponse, error) {
-	if !h.connected {
-		return nil, fmt.Errorf("HTTP transport not connected")
+	body := io.LimitReader(resp.Body, maxMessageSize)
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readEventStream(body, inbox)
 	}
 
-	requestBytes, err := json.Marshal(request)
+	responseBytes, err := io.ReadAll(body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return fmt.Errorf("failed to read response body: %w", err)
 	}
+	if len(bytes.TrimSpace(responseBytes)) > 0 {
+		inbox.push(responseBytes)
+	}
+	return nil
+}
+
+// readEventStream queues the data of every event of a Streamable HTTP
+// response
+func readEventStream(r io.Reader, inbox *messageQueue) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	var data []string
+	flush := func() {
+		if len(data) > 0 {
+			inbox.push([]byte(strings.Join(data, "\n")))
+			data = nil
+		}
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return &TransportError{Err: fmt.Errorf("failed to read event stream: %w", err)}
+	}
+	return nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", h.baseURL, bytes.NewReader(requestBytes))
+// post sends the request body with the configured headers and credentials
+func (h *HTTPTransport) post(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", h.baseURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -104,80 +255,208 @@ func (h *HTTPTransport) SendRequest(ctx context.Context, request JSONRPCRequest)
 	for key, value := range h.headers {
 		req.Header.Set(key, value)
 	}
+	if err := setAuthorization(ctx, req.Header, h.auth); err != nil {
+		return nil, err
+	}
+
+	h.mu.RLock()
+	version, sessionID := h.protocolVersion, h.sessionID
+	h.mu.RUnlock()
+	if VersionSupports(version, FeatureProtocolVersionHeader) {
+		req.Header.Set("MCP-Protocol-Version", version)
+	}
+	if sessionID != "" && VersionSupports(version, FeatureStreamableHTTP) {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
 
+	tracer := h.current()
+	if tracer != nil {
+		tracer.HTTP(TraceSend, req.Method+" "+req.URL.String(), req.Header, 0)
+	}
+	start := time.Now()
 	resp, err := h.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &TransportError{Err: fmt.Errorf("HTTP request failed: %w", err)}
+	}
+	if tracer != nil {
+		tracer.HTTP(TraceRecv, resp.Status, resp.Header, time.Since(start))
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP request failed with status: %d", resp.StatusCode)
+	// Streamable HTTP servers assign the session on initialize
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		h.mu.Lock()
+		h.sessionID = id
+		h.mu.Unlock()
 	}
+	return resp, nil
+}
 
-	responseBytes, err := io.ReadAll(resp.Body)
+// setAuthorization adds the bearer token provided by auth, if any
+func setAuthorization(ctx context.Context, header http.Header, auth Authenticator) error {
+	if auth == nil {
+		return nil
+	}
+	token, err := auth.Token(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return fmt.Errorf("failed to get access token: %w", err)
 	}
-
-	var response JSONRPCResponse
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
 	}
-
-	return &response, nil
+	return nil
 }
 
-// WebSocketTransport implements MCP over WebSocket
+// WebSocket keepalive settings
+const (
+	// pongWait is how long we wait for any message, pongs included, before
+	// considering the connection lost
+	pongWait = 60 * time.Second
+	// pingInterval must be lower than pongWait
+	pingInterval = 25 * time.Second
+	writeWait    = 10 * time.Second
+)
+
+// ErrConnectionLost is returned by ReadMessage when the connection drops
+var ErrConnectionLost = fmt.Errorf("connection lost")
+
+// WebSocketTransport implements MCP over WebSocket. The transport can be
+// connected again after the connection is lost
 type WebSocketTransport struct {
-	url        string
-	conn       *websocket.Conn
-	connected  bool
-	sendCh     chan JSONRPCRequest
-	receiveCh  chan JSONRPCResponse
-	closeCh    chan struct{}
-	dialer     *websocket.Dialer
+	url       string
+	conn      *websocket.Conn
+	connected bool
+	// messages to send on the current connection, each connection has its
+	// own so that a send loop never takes the messages of the next one
+	sendCh    chan []byte
+	receiveCh chan []byte
+	closeCh   chan struct{}
+	// closed when the current connection drops
+	lostCh  chan struct{}
+	dialer  *websocket.Dialer
+	headers http.Header
+	auth    Authenticator
+	// receives the errors of the connection
+	output io.Writer
+	mu     sync.Mutex
+	// traces the headers of the handshake
+	traceHook
 }
 
 // NewWebSocketTransport creates a new WebSocket transport
 func NewWebSocketTransport(url string) *WebSocketTransport {
 	return &WebSocketTransport{
-		url: url,
+		url:    url,
+		output: os.Stderr,
 		dialer: &websocket.Dialer{
 			HandshakeTimeout: 30 * time.Second,
 		},
-		sendCh:    make(chan JSONRPCRequest, 10),
-		receiveCh: make(chan JSONRPCResponse, 10),
+		headers:   http.Header{},
+		receiveCh: make(chan []byte, 10),
 		closeCh:   make(chan struct{}),
+		lostCh:    make(chan struct{}),
 	}
 }
 
+// SetHeaders adds custom headers sent with the handshake
+func (w *WebSocketTransport) SetHeaders(headers map[string]string) {
+	for key, value := range headers {
+		w.headers.Set(key, value)
+	}
+}
+
+// SetOutput sets where the errors of the connection are reported,
+// os.Stderr by default
+func (w *WebSocketTransport) SetOutput(output io.Writer) {
+	w.output = output
+}
+
+// SetTLSConfig sets the TLS settings used for wss:// connections
+func (w *WebSocketTransport) SetTLSConfig(config *tls.Config) {
+	w.dialer.TLSClientConfig = config
+}
+
+// SetAuthenticator sets the provider of the Authorization header
+func (w *WebSocketTransport) SetAuthenticator(auth Authenticator) {
+	w.auth = auth
+}
+
 func (w *WebSocketTransport) Connect(ctx context.Context) error {
-	conn, _, err := w.dialer.DialContext(ctx, w.url, nil)
+	conn, resp, err := w.dial(ctx)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusUnauthorized && w.auth != nil {
+		if err := w.auth.Authorize(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+			return fmt.Errorf("MCP server authorization failed: %w", err)
+		}
+		conn, _, err = w.dial(ctx)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to connect to WebSocket: %w", err)
 	}
 
+	w.mu.Lock()
+	select {
+	case <-w.closeCh:
+		// Connecting again after Disconnect
+		w.closeCh = make(chan struct{})
+	default:
+	}
 	w.conn = conn
 	w.connected = true
+	lostCh := make(chan struct{})
+	w.lostCh = lostCh
+	sendCh := make(chan []byte, 10)
+	w.sendCh = sendCh
+	closeCh := w.closeCh
+	w.mu.Unlock()
+
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
 
 	// Start message handling goroutines
-	go w.sendLoop()
-	go w.receiveLoop()
+	go w.sendLoop(conn, sendCh, lostCh, closeCh)
+	go w.receiveLoop(conn, lostCh, closeCh)
 
 	return nil
 }
 
+func (w *WebSocketTransport) dial(ctx context.Context) (*websocket.Conn, *http.Response, error) {
+	header := w.headers.Clone()
+	if err := setAuthorization(ctx, header, w.auth); err != nil {
+		return nil, nil, err
+	}
+
+	tracer := w.current()
+	if tracer != nil {
+		tracer.HTTP(TraceSend, "GET "+w.url, header, 0)
+	}
+	start := time.Now()
+	conn, resp, err := w.dialer.DialContext(ctx, w.url, header)
+	if tracer != nil && resp != nil {
+		tracer.HTTP(TraceRecv, resp.Status, resp.Header, time.Since(start))
+	}
+	return conn, resp, err
+}
+
 func (w *WebSocketTransport) Disconnect() error {
-	if !w.connected {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	select {
+	case <-w.closeCh:
 		return nil
+	default:
 	}
-
 	w.connected = false
 	close(w.closeCh)
 
 	if w.conn != nil {
-		w.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		w.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(writeWait))
 		w.conn.Close()
 	}
 
@@ -185,108 +464,305 @@ func (w *WebSocketTransport) Disconnect() error {
 }
 
 func (w *WebSocketTransport) IsConnected() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.connected
 }
 
-func (w *WebSocketTransport) Send(request JSONRPCRequest) error {
-	if !w.connected {
-		return fmt.Errorf("WebSocket not connected")
+// WriteMessage queues a message for the send loop
+func (w *WebSocketTransport) WriteMessage(ctx context.Context, data []byte) error {
+	w.mu.Lock()
+	connected, sendCh, lostCh, closeCh := w.connected, w.sendCh, w.lostCh, w.closeCh
+	w.mu.Unlock()
+	if !connected {
+		return &TransportError{Err: fmt.Errorf("WebSocket not connected")}
 	}
 
 	select {
-	case w.sendCh <- request:
+	case sendCh <- data:
 		return nil
-	case <-w.closeCh:
+	case <-lostCh:
+		return &TransportError{Err: ErrConnectionLost}
+	case <-closeCh:
 		return fmt.Errorf("WebSocket transport closed")
+	case <-ctx.Done():
+		return ctx.Err()
 	case <-time.After(5 * time.Second):
-		return fmt.Errorf("send timeout")
+		return &TransportError{Err: fmt.Errorf("send timeout")}
 	}
 }
 
-func (w *WebSocketTransport) Receive() (*JSONRPCResponse, error) {
-	if !w.connected {
-		return nil, fmt.Errorf("WebSocket not connected")
-	}
+// ReadMessage returns the next message sent by the server. It fails with
+// ErrConnectionLost when the connection drops
+func (w *WebSocketTransport) ReadMessage() ([]byte, error) {
+	w.mu.Lock()
+	lostCh, closeCh := w.lostCh, w.closeCh
+	w.mu.Unlock()
 
 	select {
-	case response := <-w.receiveCh:
-		return &response, nil
-	case <-w.closeCh:
+	case message := <-w.receiveCh:
+		return message, nil
+	case <-lostCh:
+		// Deliver what was read before the connection dropped
+		select {
+		case message := <-w.receiveCh:
+			return message, nil
+		default:
+			return nil, ErrConnectionLost
+		}
+	case <-closeCh:
 		return nil, fmt.Errorf("WebSocket transport closed")
-	case <-time.After(30 * time.Second):
-		return nil, fmt.Errorf("receive timeout")
 	}
 }
 
-func (w *WebSocketTransport) sendLoop() {
+// lost marks the given connection as dropped
+func (w *WebSocketTransport) lost(conn *websocket.Conn, lostCh chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	select {
+	case <-lostCh:
+		return
+	default:
+	}
+	close(lostCh)
+	if w.conn == conn {
+		w.connected = false
+	}
+	conn.Close()
+}
+
+func (w *WebSocketTransport) sendLoop(conn *websocket.Conn, sendCh chan []byte, lostCh, closeCh chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case request := <-w.sendCh:
-			if err := w.conn.WriteJSON(request); err != nil {
-				fmt.Printf("WebSocket send error: %v\n", err)
-				w.Disconnect()
+		case message := <-sendCh:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				fmt.Fprintf(w.output, "WebSocket send error: %v\n", err)
+				w.lost(conn, lostCh)
 				return
 			}
-		case <-w.closeCh:
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				w.lost(conn, lostCh)
+				return
+			}
+		case <-lostCh:
+			return
+		case <-closeCh:
 			return
 		}
 	}
 }
 
-func (w *WebSocketTransport) receiveLoop() {
+func (w *WebSocketTransport) receiveLoop(conn *websocket.Conn, lostCh, closeCh chan struct{}) {
 	for {
-		select {
-		case <-w.closeCh:
-			return
-		default:
-			var response JSONRPCResponse
-			if err := w.conn.ReadJSON(&response); err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					fmt.Printf("WebSocket receive error: %v\n", err)
-				}
-				w.Disconnect()
-				return
-			}
-
+		_, message, err := conn.ReadMessage()
+		if err != nil {
 			select {
-			case w.receiveCh <- response:
-			case <-w.closeCh:
+			case <-closeCh:
 				return
+			default:
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure) {
+				fmt.Fprintf(w.output, "WebSocket receive error: %v\n", err)
 			}
+			w.lost(conn, lostCh)
+			return
+		}
+		// Any message proves the connection is alive
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		select {
+		case w.receiveCh <- message:
+		case <-closeCh:
+			return
 		}
 	}
 }
 
-// StdioTransport remains the same as before but implements the Transport interface
+// StdioTransport runs the MCP server as a child process, and exchanges
+// newline delimited messages over its stdin and stdout
 type StdioTransport struct {
-	cmd    interface{} // Will be *exec.Cmd
-	stdin  io.WriteCloser
-	stdout io.ReadCloser
-	stderr io.ReadCloser
-	sendCh chan JSONRPCRequest
-	recvCh chan JSONRPCResponse
-	ctx    context.Context
-	cancel context.CancelFunc
+	command []string
+	env     map[string]string
+	process *ProcessConfig
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stream  MessageStream
+	mu      sync.Mutex
+	// traces the stderr of the server
+	traceHook
 }
 
-// Placeholder implementation - the actual stdio implementation should be moved here
+// NewStdioTransport creates a transport running command. The server gets
+// the DefaultEnvAllowlist variables of our environment, env overriding
+// them, unless SetProcessConfig selects another EnvMode
+func NewStdioTransport(command []string, env map[string]string) *StdioTransport {
+	return &StdioTransport{
+		command: command,
+		env:     env,
+	}
+}
+
+// SetProcessConfig sets the environment mode, working directory, limits and
+// sandboxing of the server
+func (s *StdioTransport) SetProcessConfig(config *ProcessConfig) {
+	s.process = config
+}
+
+// Connect starts the server, which is killed when ctx is cancelled
 func (s *StdioTransport) Connect(ctx context.Context) error {
-	// Implementation would be moved from the client
+	cmd, err := s.process.Command(ctx, s.command, s.env)
+	if err != nil {
+		return err
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start command: %w", err)
+	}
+
+	go s.drainStderr(stderr)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cmd = cmd
+	s.stdin = stdin
+	s.stream = NewLineStream(stdout, stdin)
 	return nil
 }
 
+// drainStderr traces the server logs. The pipe is drained even when tracing
+// is off, so that the server never blocks on it
+func (s *StdioTransport) drainStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		if tracer := s.current(); tracer != nil {
+			tracer.Stderr(scanner.Text())
+		}
+	}
+	io.Copy(io.Discard, stderr)
+}
+
 func (s *StdioTransport) Disconnect() error {
+	s.mu.Lock()
+	cmd, stdin := s.cmd, s.stdin
+	s.mu.Unlock()
+
+	if stdin != nil {
+		stdin.Close()
+	}
+	if cmd != nil && cmd.Process != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}
 	return nil
 }
 
-func (s *StdioTransport) Send(request JSONRPCRequest) error {
-	return nil
+func (s *StdioTransport) IsConnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cmd != nil && s.cmd.Process != nil
 }
 
-func (s *StdioTransport) Receive() (*JSONRPCResponse, error) {
-	return nil, nil
+// ReadMessage returns the next line written by the server, it fails with
+// io.EOF when the server exits
+func (s *StdioTransport) ReadMessage() ([]byte, error) {
+	s.mu.Lock()
+	stream := s.stream
+	s.mu.Unlock()
+	if stream == nil {
+		return nil, fmt.Errorf("stdio transport not connected")
+	}
+	return stream.ReadMessage()
 }
 
-func (s *StdioTransport) IsConnected() bool {
-	return false
-}
\ No newline at end of file
+func (s *StdioTransport) WriteMessage(ctx context.Context, data []byte) error {
+	s.mu.Lock()
+	stream := s.stream
+	s.mu.Unlock()
+	if stream == nil {
+		return &TransportError{Err: fmt.Errorf("stdio transport not connected")}
+	}
+	return stream.WriteMessage(ctx, data)
+}
+
+// messageQueue is an unbounded queue of messages with a single reader, so
+// that producers never block on a slow reader
+type messageQueue struct {
+	mu       sync.Mutex
+	messages [][]byte
+	ready    chan struct{}
+	closed   bool
+}
+
+func newMessageQueue() *messageQueue {
+	return &messageQueue{ready: make(chan struct{}, 1)}
+}
+
+func (q *messageQueue) push(message []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.messages = append(q.messages, message)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop blocks until a message is available, and fails once the queue is
+// closed and empty
+func (q *messageQueue) pop() ([]byte, error) {
+	for {
+		q.mu.Lock()
+		if len(q.messages) > 0 {
+			message := q.messages[0]
+			q.messages = q.messages[1:]
+			q.mu.Unlock()
+			return message, nil
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return nil, io.EOF
+		}
+		q.mu.Unlock()
+		<-q.ready
+	}
+}
+
+func (q *messageQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.ready)
+	}
+}
+
+func (q *messageQueue) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
@@ -1,6 +1,8 @@
 package mcp
 
 import (
+	"encoding/json"
+	"io"
 	"time"
 )
 
@@ -27,6 +29,42 @@ type JSONRPCError struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// JSONRPCMessage is used to decode any incoming message (response, request or
+// notification) before dispatching it
+type JSONRPCMessage struct {
+	JSONRpc string          `json:"jsonrpc"`
+	ID      interface{}     `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+}
+
+// IsNotification returns true if the message is a notification
+func (m *JSONRPCMessage) IsNotification() bool {
+	return m.Method != "" && m.ID == nil
+}
+
+// IsResponse returns true if the message is a response to a request
+func (m *JSONRPCMessage) IsResponse() bool {
+	return m.Method == ""
+}
+
+// Response converts the message to a JSONRPCResponse
+func (m *JSONRPCMessage) Response() JSONRPCResponse {
+	return JSONRPCResponse{
+		JSONRpc: m.JSONRpc,
+		ID:      m.ID,
+		Result:  m.Result,
+		Error:   m.Error,
+	}
+}
+
+// RequestMeta carries the _meta field attached to requests
+type RequestMeta struct {
+	ProgressToken interface{} `json:"progressToken,omitempty"`
+}
+
 // MCP Initialization
 type InitializeRequest struct {
 	ProtocolVersion string             `json:"protocolVersion"`
@@ -35,8 +73,9 @@ type InitializeRequest struct {
 }
 
 type ClientCapabilities struct {
-	Roots    *RootsCapability    `json:"roots,omitempty"`
-	Sampling *SamplingCapability `json:"sampling,omitempty"`
+	Roots       *RootsCapability       `json:"roots,omitempty"`
+	Sampling    *SamplingCapability    `json:"sampling,omitempty"`
+	Elicitation *ElicitationCapability `json:"elicitation,omitempty"`
 }
 
 type RootsCapability struct {
@@ -45,6 +84,8 @@ type RootsCapability struct {
 
 type SamplingCapability struct{}
 
+type ElicitationCapability struct{}
+
 type ClientInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
@@ -61,6 +102,8 @@ type ServerCapabilities struct {
 	Resources *ResourcesCapability `json:"resources,omitempty"`
 	Prompts   *PromptsCapability   `json:"prompts,omitempty"`
 	Logging   *LoggingCapability   `json:"logging,omitempty"`
+	// Advertised since 2025-03-26
+	Completions *CompletionsCapability `json:"completions,omitempty"`
 }
 
 type ToolsCapability struct {
@@ -78,6 +121,8 @@ type PromptsCapability struct {
 
 type LoggingCapability struct{}
 
+type CompletionsCapability struct{}
+
 type ServerInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
@@ -85,38 +130,297 @@ type ServerInfo struct {
 
 // MCP Tools
 type MCPTool struct {
-	Name        string      `json:"name"`
-	Description string      `json:"description,omitempty"`
-	InputSchema ToolSchema  `json:"inputSchema"`
+	Name         string           `json:"name"`
+	Description  string           `json:"description,omitempty"`
+	InputSchema  ToolSchema       `json:"inputSchema"`
+	OutputSchema *ToolSchema      `json:"outputSchema,omitempty"`
+	Annotations  *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations describe the behavior of a tool. They are hints: clients
+// must not rely on them for tools exposed by untrusted servers
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
 }
 
 type ToolSchema struct {
-	Type        string                 `json:"type"`
-	Properties  map[string]interface{} `json:"properties,omitempty"`
-	Required    []string               `json:"required,omitempty"`
-	Description string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
 }
 
-type ListToolsRequest struct{}
+type ListToolsRequest struct {
+	Cursor string `json:"cursor,omitempty"`
+}
 
 type ListToolsResponse struct {
-	Tools []MCPTool `json:"tools"`
+	Tools      []MCPTool `json:"tools"`
+	NextCursor string    `json:"nextCursor,omitempty"`
 }
 
 type CallToolRequest struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Meta      *RequestMeta           `json:"_meta,omitempty"`
 }
 
 type CallToolResponse struct {
-	Content []ToolResult `json:"content"`
-	IsError bool         `json:"isError,omitempty"`
+	Content           []ToolResult `json:"content"`
+	StructuredContent interface{}  `json:"structuredContent,omitempty"`
+	IsError           bool         `json:"isError,omitempty"`
 }
 
+// Content types
+const (
+	ContentText         = "text"
+	ContentImage        = "image"
+	ContentAudio        = "audio"
+	ContentResource     = "resource"
+	ContentResourceLink = "resource_link"
+)
+
+// ToolResult is a content block, as returned by tools and prompts
 type ToolResult struct {
 	Type     string `json:"type"`
 	Text     string `json:"text,omitempty"`
 	MimeType string `json:"mimeType,omitempty"`
+
+	// Base64 encoded data of image and audio content
+	Data string `json:"data,omitempty"`
+
+	// Resource link content
+	URI         string `json:"uri,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Embedded resource content
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	// Base64 encoded binary content
+	Blob string `json:"blob,omitempty"`
+}
+
+// MCP Resources
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate describes parameterized resources with an RFC 6570 URI
+// template, e.g. openstack://{namespace}/version
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ListResourcesRequest struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type ListResourcesResponse struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+type ListResourceTemplatesResponse struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+	NextCursor        string             `json:"nextCursor,omitempty"`
+}
+
+type ReadResourceRequest struct {
+	URI string `json:"uri"`
+}
+
+type ReadResourceResponse struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// MCP Prompts
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type ListPromptsRequest struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type ListPromptsResponse struct {
+	Prompts    []Prompt `json:"prompts"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+type GetPromptRequest struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type GetPromptResponse struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+type PromptMessage struct {
+	Role    string     `json:"role"`
+	Content ToolResult `json:"content"`
+}
+
+// MCP Completion
+
+// Types of the completion references
+const (
+	RefPrompt   = "ref/prompt"
+	RefResource = "ref/resource"
+)
+
+// CompletionReference identifies the prompt (by name) or the resource
+// template (by URI template) whose argument is completed
+type CompletionReference struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+type CompletionArgument struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CompletionContext carries the arguments already provided, so that the
+// server can narrow the suggestions (e.g. the CRs of a given namespace)
+type CompletionContext struct {
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type CompleteRequest struct {
+	Ref      CompletionReference `json:"ref"`
+	Argument CompletionArgument  `json:"argument"`
+	Context  *CompletionContext  `json:"context,omitempty"`
+}
+
+// Completion holds at most 100 values, HasMore is set when the server has
+// more than those
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
+
+type CompleteResponse struct {
+	Completion Completion `json:"completion"`
+}
+
+// MCP Notifications
+type LoggingMessageNotification struct {
+	Level  string      `json:"level"`
+	Logger string      `json:"logger,omitempty"`
+	Data   interface{} `json:"data"`
+}
+
+type SetLevelRequest struct {
+	Level string `json:"level"`
+}
+
+type ProgressNotification struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      float64     `json:"progress"`
+	Total         float64     `json:"total,omitempty"`
+	Message       string      `json:"message,omitempty"`
+}
+
+type ResourceUpdatedNotification struct {
+	URI string `json:"uri"`
+}
+
+type CancelledNotification struct {
+	RequestID interface{} `json:"requestId"`
+	Reason    string      `json:"reason,omitempty"`
+}
+
+// MCP Roots
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+}
+
+type ListRootsResponse struct {
+	Roots []Root `json:"roots"`
+}
+
+// MCP Sampling
+type CreateMessageRequest struct {
+	Messages         []SamplingMessage `json:"messages"`
+	ModelPreferences *ModelPreferences `json:"modelPreferences,omitempty"`
+	SystemPrompt     string            `json:"systemPrompt,omitempty"`
+	IncludeContext   string            `json:"includeContext,omitempty"`
+	Temperature      *float64          `json:"temperature,omitempty"`
+	MaxTokens        int               `json:"maxTokens"`
+	StopSequences    []string          `json:"stopSequences,omitempty"`
+}
+
+type SamplingMessage struct {
+	Role    string     `json:"role"`
+	Content ToolResult `json:"content"`
+}
+
+type ModelPreferences struct {
+	Hints                []ModelHint `json:"hints,omitempty"`
+	CostPriority         float64     `json:"costPriority,omitempty"`
+	SpeedPriority        float64     `json:"speedPriority,omitempty"`
+	IntelligencePriority float64     `json:"intelligencePriority,omitempty"`
+}
+
+type ModelHint struct {
+	Name string `json:"name,omitempty"`
+}
+
+type CreateMessageResult struct {
+	Role       string     `json:"role"`
+	Content    ToolResult `json:"content"`
+	Model      string     `json:"model"`
+	StopReason string     `json:"stopReason,omitempty"`
+}
+
+// MCP Elicitation
+type ElicitRequest struct {
+	Message string `json:"message"`
+	// Flat object whose properties are strings, numbers, booleans or enums
+	RequestedSchema ToolSchema `json:"requestedSchema"`
+}
+
+// Elicitation actions
+const (
+	ElicitAccept  = "accept"
+	ElicitDecline = "decline"
+	ElicitCancel  = "cancel"
+)
+
+type ElicitResult struct {
+	Action string `json:"action"`
+	// Submitted data, only set when the action is accept
+	Content map[string]interface{} `json:"content,omitempty"`
 }
 
 // Connection configuration
@@ -127,14 +431,59 @@ type MCPConfig struct {
 	// For stdio transport
 	Command []string          `json:"command,omitempty"`
 	Env     map[string]string `json:"env,omitempty"`
+
+	// Environment inheritance, working directory, resource limits and
+	// sandboxing of the server process
+	Process *ProcessConfig `json:"process,omitempty"`
 	
+	// For in-process transport, the server serving the sessions
+	InProcess ServeFunc `json:"-"`
+
 	// For HTTP/WebSocket transport
 	ServerURL string `json:"serverUrl,omitempty"`
 	Headers   map[string]string `json:"headers,omitempty"`
-	
+
+	// Static token sent as "Authorization: Bearer", it takes precedence
+	// over OAuth
+	BearerToken string `json:"bearerToken,omitempty"`
+
+	// Enables the MCP authorization flow when the server requires it
+	OAuth *OAuthConfig `json:"oauth,omitempty"`
+
+	// CA bundle, client certificate and verification settings
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// Protocol revision offered during initialize, LatestProtocolVersion
+	// when empty
+	ProtocolVersion string `json:"protocolVersion,omitempty"`
+
 	// Common settings
 	Timeout    time.Duration `json:"timeout,omitempty"`
 	MaxRetries int           `json:"maxRetries,omitempty"`
+
+	// Initial delay between retries, doubled at every attempt
+	RetryBackoff time.Duration `json:"retryBackoff,omitempty"`
+
+	// Attempts to reconnect when a WebSocket connection drops
+	MaxReconnects int `json:"maxReconnects,omitempty"`
+
+	// Deadline applied to tools/call, ToolTimeouts overrides it per tool
+	CallTimeout  time.Duration            `json:"callTimeout,omitempty"`
+	ToolTimeouts map[string]time.Duration `json:"toolTimeouts,omitempty"`
+
+	// Minimum level of the server log messages shown to the user
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// Roots returned to the server on roots/list
+	Roots []Root `json:"roots,omitempty"`
+
+	// Upper bound for the maxTokens of sampling requests
+	MaxSamplingTokens int `json:"maxSamplingTokens,omitempty"`
+
+	// Where the client prints its warnings, notifications and the
+	// authorization prompts, os.Stderr when nil. stdout is left to the
+	// programs serving MCP on stdio
+	Output io.Writer `json:"-"`
 }
 
 // Client state
@@ -0,0 +1,20 @@
+package mcp
+
+import (
+	"os"
+	"syscall"
+)
+
+// nobodyID is the user and group of the server inside its user namespace
+const nobodyID = 65534
+
+// userNamespace runs the process in a new user namespace, where our user is
+// mapped to nobody: the server has no capabilities, and can't gain any by
+// running setuid binaries
+func userNamespace(attr *syscall.SysProcAttr) error {
+	attr.Cloneflags |= syscall.CLONE_NEWUSER
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: nobodyID, HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: nobodyID, HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+	return nil
+}
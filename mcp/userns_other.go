@@ -0,0 +1,13 @@
+//go:build !linux
+
+package mcp
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// userNamespace is only supported on Linux
+func userNamespace(attr *syscall.SysProcAttr) error {
+	return fmt.Errorf("user namespaces are only supported on Linux")
+}
@@ -0,0 +1,106 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MCP protocol revisions
+const (
+	ProtocolVersion20241105 = "2024-11-05"
+	ProtocolVersion20250326 = "2025-03-26"
+	ProtocolVersion20250618 = "2025-06-18"
+
+	// LatestProtocolVersion is offered to servers during initialize
+	LatestProtocolVersion = ProtocolVersion20250618
+)
+
+// SupportedProtocolVersions lists the revisions we speak, latest first
+var SupportedProtocolVersions = []string{
+	ProtocolVersion20250618,
+	ProtocolVersion20250326,
+	ProtocolVersion20241105,
+}
+
+// ProtocolFeature identifies a capability that depends on the protocol
+// revision negotiated with the server
+type ProtocolFeature string
+
+const (
+	// Mcp-Session-Id handling of the Streamable HTTP transport
+	FeatureStreamableHTTP  ProtocolFeature = "streamable-http"
+	FeatureToolAnnotations ProtocolFeature = "tool-annotations"
+	FeatureAudioContent    ProtocolFeature = "audio-content"
+	// outputSchema and structuredContent of tools
+	FeatureStructuredOutput ProtocolFeature = "structured-output"
+	FeatureResourceLinks    ProtocolFeature = "resource-links"
+	FeatureElicitation      ProtocolFeature = "elicitation"
+	// MCP-Protocol-Version header sent with every HTTP request
+	FeatureProtocolVersionHeader ProtocolFeature = "protocol-version-header"
+	// JSON-RPC batches, removed by 2025-06-18
+	FeatureBatching ProtocolFeature = "batching"
+	// completions server capability, before it completion/complete was
+	// available without being advertised
+	FeatureCompletionsCapability ProtocolFeature = "completions-capability"
+	// context arguments of completion/complete
+	FeatureCompletionContext ProtocolFeature = "completion-context"
+)
+
+// featureVersions maps every feature to the revision introducing it
+var featureVersions = map[ProtocolFeature]string{
+	FeatureStreamableHTTP:        ProtocolVersion20250326,
+	FeatureToolAnnotations:       ProtocolVersion20250326,
+	FeatureAudioContent:          ProtocolVersion20250326,
+	FeatureStructuredOutput:      ProtocolVersion20250618,
+	FeatureResourceLinks:         ProtocolVersion20250618,
+	FeatureElicitation:           ProtocolVersion20250618,
+	FeatureProtocolVersionHeader: ProtocolVersion20250618,
+	FeatureBatching:              ProtocolVersion20250326,
+	FeatureCompletionsCapability: ProtocolVersion20250326,
+	FeatureCompletionContext:     ProtocolVersion20250618,
+}
+
+// featureRemovals maps the features dropped by the specification to the
+// revision removing them
+var featureRemovals = map[ProtocolFeature]string{
+	FeatureBatching: ProtocolVersion20250618,
+}
+
+// IsSupportedProtocolVersion returns true if we can speak the given revision
+func IsSupportedProtocolVersion(version string) bool {
+	for _, v := range SupportedProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// VersionSupports returns true if the feature is available in the given
+// protocol revision. Revisions are dates, so they compare as strings
+func VersionSupports(version string, feature ProtocolFeature) bool {
+	since, exists := featureVersions[feature]
+	if !exists || version == "" {
+		return false
+	}
+	if until, removed := featureRemovals[feature]; removed && version >= until {
+		return false
+	}
+	return version >= since
+}
+
+// negotiateVersion validates the revision chosen by the server in its
+// initialize response: the offered one, or an older one we support
+func negotiateVersion(offered string, chosen string) (string, error) {
+	if chosen == "" {
+		return "", fmt.Errorf("server did not return a protocol version")
+	}
+	if !IsSupportedProtocolVersion(chosen) {
+		return "", fmt.Errorf("server requires MCP protocol version %s, which is not supported (offered %s, supported: %s)",
+			chosen, offered, strings.Join(SupportedProtocolVersions, ", "))
+	}
+	if chosen > offered {
+		return "", fmt.Errorf("server chose MCP protocol version %s, newer than the offered %s", chosen, offered)
+	}
+	return chosen, nil
+}
@@ -0,0 +1,62 @@
+package mcp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		offered string
+		chosen  string
+		wantErr string
+	}{
+		{name: "offered version", offered: ProtocolVersion20250618, chosen: ProtocolVersion20250618},
+		{name: "older version", offered: ProtocolVersion20250618, chosen: ProtocolVersion20241105},
+		{name: "older offer accepted", offered: ProtocolVersion20250326, chosen: ProtocolVersion20250326},
+		{name: "newer than offered", offered: ProtocolVersion20250326, chosen: ProtocolVersion20250618, wantErr: "newer than the offered"},
+		{name: "unsupported version", offered: ProtocolVersion20250618, chosen: "2024-01-01", wantErr: "not supported"},
+		{name: "unsupported newer version", offered: ProtocolVersion20250618, chosen: "2099-01-01", wantErr: "not supported"},
+		{name: "no version", offered: ProtocolVersion20250618, chosen: "", wantErr: "did not return a protocol version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := negotiateVersion(tt.offered, tt.chosen)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("negotiateVersion(%s, %s) error = %v, want %q", tt.offered, tt.chosen, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("negotiateVersion(%s, %s) error = %v", tt.offered, tt.chosen, err)
+			}
+			if got != tt.chosen {
+				t.Errorf("negotiateVersion(%s, %s) = %s, want %s", tt.offered, tt.chosen, got, tt.chosen)
+			}
+		})
+	}
+}
+
+func TestVersionSupports(t *testing.T) {
+	tests := []struct {
+		version string
+		feature ProtocolFeature
+		want    bool
+	}{
+		{ProtocolVersion20241105, FeatureToolAnnotations, false},
+		{ProtocolVersion20250326, FeatureToolAnnotations, true},
+		{ProtocolVersion20250326, FeatureElicitation, false},
+		{ProtocolVersion20250618, FeatureElicitation, true},
+		{ProtocolVersion20241105, FeatureBatching, false},
+		{ProtocolVersion20250326, FeatureBatching, true},
+		{ProtocolVersion20250618, FeatureBatching, false},
+		{"", FeatureToolAnnotations, false},
+	}
+	for _, tt := range tests {
+		if got := VersionSupports(tt.version, tt.feature); got != tt.want {
+			t.Errorf("VersionSupports(%q, %s) = %v, want %v", tt.version, tt.feature, got, tt.want)
+		}
+	}
+}
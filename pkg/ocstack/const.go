@@ -4,4 +4,7 @@ const (
 	MODEL             = "gemma2"
 	NAMESPACE         = "namespace"
 	DEFAULT_NAMESPACE = "openstack"
+	WORKSPACE         = "workspace"
+	WORKSPACE_ENV     = "OCSTACK_WORKSPACE"
+	MCP_TOKEN_ENV     = "OCSTACK_MCP_TOKEN"
 )
@@ -0,0 +1,171 @@
+package ocstack
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FORM_CANCEL is the input that aborts a form
+const FORM_CANCEL = "/cancel"
+
+// ErrFormCancelled is returned by ReadForm when the user aborts the form
+var ErrFormCancelled = errors.New("form cancelled by the user")
+
+// FormField describes a value requested to the user. Type is one of string,
+// number, integer and boolean
+type FormField struct {
+	Name        string
+	Title       string
+	Description string
+	Type        string
+	Required    bool
+	Default     interface{}
+
+	// Allowed values, and the labels shown for them
+	Enum      []string
+	EnumNames []string
+
+	// Optional check applied to the parsed value, the field is asked again
+	// when it fails
+	Validate func(value interface{}) error
+}
+
+// IsTerminal returns true when stdin is an interactive terminal. The null
+// device is a character device too, so it is excluded explicitly
+func IsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(fi, null)
+}
+
+// ReadForm asks the user a value for each field and returns them by name.
+// Optional fields left empty are omitted, and ErrFormCancelled is returned
+// when the user types /cancel. It can be called from any goroutine, see
+// Interact
+func ReadForm(fields []FormField) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	var err error
+	Interact(func(read LineReader) {
+		values, err = readForm(read, fields)
+	})
+	return values, err
+}
+
+func readForm(read LineReader, fields []FormField) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	fmt.Printf("   (type %s to abort)\n", FORM_CANCEL)
+	for _, field := range fields {
+		printField(field)
+		for {
+			input, err := read(fmt.Sprintf("   %s: ", fieldPrompt(field)))
+			if err != nil {
+				return nil, ErrFormCancelled
+			}
+			input = strings.TrimSpace(input)
+			if input == FORM_CANCEL {
+				return nil, ErrFormCancelled
+			}
+
+			if input == "" {
+				if field.Default != nil {
+					values[field.Name] = field.Default
+					break
+				}
+				if !field.Required {
+					break
+				}
+				fmt.Println("   A value is required")
+				continue
+			}
+
+			value, err := parseField(field, input)
+			if err == nil && field.Validate != nil {
+				err = field.Validate(value)
+			}
+			if err != nil {
+				fmt.Printf("   %sInvalid value: %v%s\n", Red, err, Reset)
+				continue
+			}
+			values[field.Name] = value
+			break
+		}
+	}
+	return values, nil
+}
+
+func printField(field FormField) {
+	if field.Description != "" {
+		fmt.Printf("   %s%s%s\n", Gray, field.Description, Reset)
+	}
+	for i, value := range field.Enum {
+		label := value
+		if i < len(field.EnumNames) && field.EnumNames[i] != "" {
+			label = field.EnumNames[i]
+		}
+		fmt.Printf("     %d) %s\n", i+1, label)
+	}
+}
+
+func fieldPrompt(field FormField) string {
+	label := field.Title
+	if label == "" {
+		label = field.Name
+	}
+	if field.Type == "boolean" {
+		label += " (y/n)"
+	}
+	if !field.Required {
+		label += " (optional)"
+	}
+	if field.Default != nil {
+		label += fmt.Sprintf(" [%v]", field.Default)
+	}
+	return label
+}
+
+// parseField converts the input to the field type. Enum values can be given
+// either by number or by value
+func parseField(field FormField, input string) (interface{}, error) {
+	if len(field.Enum) > 0 {
+		if i, err := strconv.Atoi(input); err == nil && i >= 1 && i <= len(field.Enum) {
+			return field.Enum[i-1], nil
+		}
+		for _, value := range field.Enum {
+			if value == input {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("choose one of the listed options")
+	}
+
+	switch field.Type {
+	case "boolean":
+		switch strings.ToLower(input) {
+		case "y", "yes", "true":
+			return true, nil
+		case "n", "no", "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("respond with 'y' or 'n'")
+	case "integer":
+		i, err := strconv.ParseInt(input, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return float64(i), nil
+	case "number":
+		f, err := strconv.ParseFloat(input, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+		return f, nil
+	}
+	return input, nil
+}
@@ -0,0 +1,160 @@
+package ocstack
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The terminal input is read by a single goroutine, and consumed by one
+// owner at a time: ReadLine, waiting for the next command, or a dialog run
+// by Interact, e.g. the approval of a request coming from an MCP server. A
+// dialog started while ReadLine waits is run by ReadLine itself, which then
+// restores the line being typed, so no input is lost between readers.
+
+// LineReader shows prompt and returns the line typed by the user, without
+// its trailing newline
+type LineReader func(prompt string) (string, error)
+
+type inputOwner int
+
+const (
+	ownerNone inputOwner = iota
+	ownerLine
+	ownerDialog
+)
+
+// dialog - questions asked to the user, run by the owner of the terminal
+type dialog struct {
+	run  func(read LineReader)
+	done chan struct{}
+}
+
+var (
+	inputMu   sync.Mutex
+	inputCond = sync.NewCond(&inputMu)
+	owner     inputOwner
+	// dialogs started while ReadLine waits, it is woken up through
+	// dialogWake to run them
+	pending    []*dialog
+	dialogWake = make(chan struct{}, 1)
+
+	keysOnce sync.Once
+	keys     chan byte
+	// error that stopped the reader, set before keys is closed
+	keysErr error
+)
+
+// stdinKeys returns the bytes read from stdin, the channel is closed when
+// stdin can't be read anymore
+func stdinKeys() <-chan byte {
+	keysOnce.Do(func() {
+		keys = make(chan byte, 4096)
+		go func() {
+			r := bufio.NewReader(os.Stdin)
+			for {
+				b, err := r.ReadByte()
+				if err != nil {
+					keysErr = err
+					close(keys)
+					return
+				}
+				keys <- b
+			}
+		}()
+	})
+	return keys
+}
+
+// Interact runs a dialog with the user, and returns once it is completed.
+// It is safe to call from any goroutine: when ReadLine is waiting for a
+// command, the dialog is run on its behalf
+func Interact(run func(read LineReader)) {
+	inputMu.Lock()
+	for {
+		switch owner {
+		case ownerLine:
+			d := &dialog{run: run, done: make(chan struct{})}
+			pending = append(pending, d)
+			inputMu.Unlock()
+			select {
+			case dialogWake <- struct{}{}:
+			default:
+			}
+			<-d.done
+			return
+		case ownerNone:
+			owner = ownerDialog
+			inputMu.Unlock()
+			run(readCooked)
+			releaseInput()
+			return
+		}
+		inputCond.Wait()
+	}
+}
+
+// acquireInput waits for the terminal to be free and takes it
+func acquireInput(o inputOwner) {
+	inputMu.Lock()
+	defer inputMu.Unlock()
+	for owner != ownerNone {
+		inputCond.Wait()
+	}
+	owner = o
+}
+
+// releaseInput runs the dialogs still pending, then frees the terminal
+func releaseInput() {
+	inputMu.Lock()
+	for len(pending) > 0 {
+		d := pending[0]
+		pending = pending[1:]
+		inputMu.Unlock()
+		d.run(readCooked)
+		close(d.done)
+		inputMu.Lock()
+	}
+	owner = ownerNone
+	inputCond.Broadcast()
+	inputMu.Unlock()
+}
+
+// hasPending returns true when dialogs wait to be run by ReadLine
+func hasPending() bool {
+	inputMu.Lock()
+	defer inputMu.Unlock()
+	return len(pending) > 0
+}
+
+// runPending runs the dialogs started while ReadLine waits, reading their
+// answers with read
+func runPending(read LineReader) {
+	for {
+		inputMu.Lock()
+		if len(pending) == 0 {
+			inputMu.Unlock()
+			return
+		}
+		d := pending[0]
+		pending = pending[1:]
+		inputMu.Unlock()
+		d.run(read)
+		close(d.done)
+	}
+}
+
+// readCooked reads a line when the terminal echoes and edits the input
+func readCooked(prompt string) (string, error) {
+	fmt.Print(prompt)
+	var line []byte
+	for b := range stdinKeys() {
+		if b == '\n' {
+			return strings.TrimSuffix(string(line), "\r"), nil
+		}
+		line = append(line, b)
+	}
+	return string(line), keysErr
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package ocstack
+
+import "errors"
+
+// makeRaw is not supported, lines are read without editing
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode not supported")
+}
@@ -0,0 +1,36 @@
+//go:build linux || darwin
+
+package ocstack
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw disables the line buffering, echo and signal keys of the
+// terminal, and returns the function restoring its previous state
+func makeRaw(fd int) (func(), error) {
+	var saved syscall.Termios
+	if err := termios(fd, ioctlGetTermios, &saved); err != nil {
+		return nil, err
+	}
+
+	raw := saved
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := termios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	return func() {
+		termios(fd, ioctlSetTermios, &saved)
+	}, nil
+}
+
+func termios(fd int, request uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package ocstack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses ctrl-c
+var ErrInterrupted = errors.New("interrupted")
+
+// Completer returns the candidates for the last word of line, each one
+// replacing the whole word
+type Completer func(line string) []string
+
+// Control keys handled by ReadLine
+const (
+	keyInterrupt = 3  // ctrl-c
+	keyEOF       = 4  // ctrl-d
+	keyBackspace = 8  // ctrl-h
+	keyTab       = 9  // tab
+	keyKill      = 21 // ctrl-u
+	keyWordErase = 23 // ctrl-w
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// ReadLine shows prompt and reads a line, including its trailing newline.
+// On a terminal the line can be edited (backspace, ctrl-u, ctrl-w), and tab
+// completes the last word through complete: a single candidate is inserted,
+// otherwise their common prefix is, and they are listed when it adds
+// nothing. Ctrl-d on an empty line returns io.EOF. When stdin is not a
+// terminal a plain line is read. Dialogs started by Interact while waiting
+// are run before going on with the line
+func ReadLine(prompt string, complete Completer) (string, error) {
+	acquireInput(ownerLine)
+	defer releaseInput()
+
+	fmt.Print(prompt)
+	if complete == nil || !IsTerminal() {
+		return readPlainLine(prompt)
+	}
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return readPlainLine(prompt)
+	}
+	defer restore()
+
+	line, err := editLine(prompt, complete, dialogWake)
+	if err != nil {
+		return "", err
+	}
+	return line + "\n", nil
+}
+
+// readPlainLine reads a line edited by the terminal itself
+func readPlainLine(prompt string) (string, error) {
+	var line []byte
+	for {
+		select {
+		case <-dialogWake:
+			if hasPending() {
+				fmt.Print("\n")
+				runPending(readCooked)
+				fmt.Print(prompt)
+			}
+		case key, ok := <-stdinKeys():
+			if !ok {
+				return string(line), keysErr
+			}
+			line = append(line, key)
+			if key == '\n' {
+				return string(line), nil
+			}
+		}
+	}
+}
+
+// readRaw reads the answer of a dialog run by ReadLine, the terminal being
+// in raw mode
+func readRaw(prompt string) (string, error) {
+	fmt.Print(prompt)
+	return editLine(prompt, nil, nil)
+}
+
+// editLine reads a line in raw mode, handling the editing keys. Dialogs are
+// run when wake fires
+func editLine(prompt string, complete Completer, wake <-chan struct{}) (string, error) {
+	var line []byte
+	for {
+		var key byte
+		select {
+		case <-wake:
+			if hasPending() {
+				fmt.Print("\n")
+				runPending(readRaw)
+				redrawLine(prompt, line)
+			}
+			continue
+		case k, ok := <-stdinKeys():
+			if !ok {
+				return "", keysErr
+			}
+			key = k
+		}
+
+		switch key {
+		case '\r', '\n':
+			fmt.Print("\n")
+			return string(line), nil
+		case keyInterrupt:
+			fmt.Print("^C\n")
+			return "", ErrInterrupted
+		case keyEOF:
+			if len(line) == 0 {
+				fmt.Print("\n")
+				return "", io.EOF
+			}
+		case keyBackspace, keyDelete:
+			if len(line) > 0 {
+				_, size := utf8.DecodeLastRune(line)
+				line = line[:len(line)-size]
+				fmt.Print("\b \b")
+			}
+		case keyKill:
+			line = nil
+			redrawLine(prompt, line)
+		case keyWordErase:
+			text := strings.TrimRight(string(line), " ")
+			line = []byte(text[:strings.LastIndex(text, " ")+1])
+			redrawLine(prompt, line)
+		case keyTab:
+			if complete != nil {
+				line = completeLine(prompt, line, complete)
+			}
+		case keyEscape:
+			// Arrows and function keys are not supported, drop the sequence
+			skipEscapeSequence()
+		default:
+			if key >= ' ' {
+				line = append(line, key)
+				os.Stdout.Write([]byte{key})
+			}
+		}
+	}
+}
+
+// completeLine replaces the last word of line with the completion
+// candidates, and returns the new line
+func completeLine(prompt string, line []byte, complete Completer) []byte {
+	text := string(line)
+	start := strings.LastIndex(text, " ") + 1
+	word := text[start:]
+
+	candidates := complete(text)
+	switch {
+	case len(candidates) == 0:
+		fmt.Print("\a")
+		return line
+	case len(candidates) == 1:
+		text = text[:start] + candidates[0]
+		// key=value arguments go on with the value
+		if !strings.HasSuffix(candidates[0], "=") {
+			text += " "
+		}
+	default:
+		if prefix := commonPrefix(candidates); len(prefix) > len(word) {
+			text = text[:start] + prefix
+		} else {
+			fmt.Printf("\n%s\n", strings.Join(candidates, "  "))
+		}
+	}
+	redrawLine(prompt, []byte(text))
+	return []byte(text)
+}
+
+func redrawLine(prompt string, line []byte) {
+	fmt.Printf("\r\033[K%s%s", prompt, line)
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// skipEscapeSequence consumes the rest of a CSI or SS3 sequence
+func skipEscapeSequence() {
+	if key, ok := <-stdinKeys(); !ok || (key != '[' && key != 'O') {
+		return
+	}
+	for key := range stdinKeys() {
+		if key >= 0x40 && key <= 0x7e {
+			return
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package ocstack
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 var Reset = "\033[0m"
 var Red = "\033[31m"
@@ -22,6 +25,8 @@ func TermHelper(cmd string) {
 		fmt.Println("3. /namespace ")
 		fmt.Println("4. /config ")
 		fmt.Println("5. /mcp ")
+		fmt.Println("6. /prompt ")
+		fmt.Println("7. /update ")
 		fmt.Println("----")
 	} else {
 		fmt.Println("----")
@@ -49,13 +54,53 @@ func help(cmd string) {
 	case cmd == "namespace":
 		fmt.Println("Usage: /namespace <ns>")
 	case cmd == "config":
-		fmt.Println("Usage: /config")
+		fmt.Println("Usage: /config [<option> <value>]")
+		fmt.Println("  Show the config options, or set one. Setting the workspace updates the roots of the MCP server")
 	case cmd == "mcp":
 		fmt.Println("Usage: /mcp <command>")
 		fmt.Println("Commands:")
 		fmt.Println("  connect <server-type> - Connect to MCP server (filesystem, brave-search, sqlite)")
 		fmt.Println("  disconnect - Disconnect from MCP server")
-		fmt.Println("  tools - List available tools")
+		fmt.Println("  tools [refresh] - List available tools, refresh fetches them again from the server")
+		fmt.Println("  tools <name> - Show the description and the input schema of a tool")
+		fmt.Println("  call [--context] <tool> [json] - Call a tool directly, --context adds the result to the conversation")
+		fmt.Println("  trace on|off [server] [--file <path>] - Log the JSON-RPC frames exchanged with the server")
+		fmt.Println("  loglevel <level> - Minimum level of the server log messages to show")
+	case cmd == "prompt":
+		fmt.Println("Usage: /prompt [<name> [key=value ...]]")
+		fmt.Println("  Without arguments, list the prompts exposed by the MCP server")
+		fmt.Println("  Prompts can also be invoked directly as /<name> [key=value ...]")
+		fmt.Println("  Press tab to complete the prompt names, argument names and values")
+	case cmd == "update":
+		fmt.Println("Usage: /update [version]")
+		fmt.Println("  Run the minor update of the namespace to version, the available one by default")
+		fmt.Println("  Preflight checks run first, then each phase is confirmed before it starts:")
+		fmt.Println("  ovn-controlplane, ovn-dataplane, controlplane and dataplane")
+		fmt.Println("  A stopped or interrupted update is resumed by running /update again")
 	default:
 	}
 }
+
+// Confirm asks a yes/no question on the terminal and returns true only when
+// the user explicitly accepts. It can be called from any goroutine, see
+// Interact
+func Confirm(question string) bool {
+	accepted := false
+	Interact(func(read LineReader) {
+		for {
+			input, err := read(fmt.Sprintf("%s (y/n): ", question))
+			if err != nil {
+				return
+			}
+			switch strings.ToLower(strings.TrimSpace(input)) {
+			case "y", "yes":
+				accepted = true
+				return
+			case "n", "no":
+				return
+			}
+			fmt.Println("Please respond with 'y' or 'n'")
+		}
+	})
+	return accepted
+}
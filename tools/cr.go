@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Condition severities set by the OpenStack operators on failures
+const (
+	SeverityError   = "Error"
+	SeverityWarning = "Warning"
+	SeverityInfo    = "Info"
+)
+
+// crStatus - the name, the owners and the conditions of a CR
+type crStatus struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name            string `json:"name"`
+		UID             string `json:"uid"`
+		OwnerReferences []struct {
+			Kind string `json:"kind"`
+			UID  string `json:"uid"`
+		} `json:"ownerReferences"`
+	} `json:"metadata"`
+	Status struct {
+		Conditions []Condition `json:"conditions"`
+	} `json:"status"`
+}
+
+// listCRs returns the CRs of kind in ns
+func listCRs(ctx context.Context, r Runner, ns string, kind string) ([]crStatus, error) {
+	res, err := r.Run(ctx, ns, nil, "get", kind, "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("could not list %s: %v: %s", kind, err, strings.TrimSpace(res.Stderr))
+	}
+	var list struct {
+		Items []crStatus `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(res.Stdout), &list); err != nil {
+		return nil, fmt.Errorf("invalid %s list: %w", kind, err)
+	}
+	return list.Items, nil
+}
+
+// getCR returns the CR of kind called name, nil when it does not exist
+func getCR(ctx context.Context, r Runner, ns string, kind string, name string) (*crStatus, error) {
+	res, err := r.Run(ctx, ns, nil, "get", kind, name, "-o", "json", "--ignore-not-found")
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s %s: %v: %s", kind, name, err, strings.TrimSpace(res.Stderr))
+	}
+	if strings.TrimSpace(res.Stdout) == "" {
+		return nil, nil
+	}
+	var cr crStatus
+	if err := json.Unmarshal([]byte(res.Stdout), &cr); err != nil {
+		return nil, fmt.Errorf("invalid %s %s: %w", kind, name, err)
+	}
+	return &cr, nil
+}
+
+// ownedBy returns true when the CR is owned by the CR with the given uid
+func (cr *crStatus) ownedBy(uid string) bool {
+	for _, owner := range cr.Metadata.OwnerReferences {
+		if owner.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// findCondition returns the condition of type t, nil when it is not set
+func findCondition(conditions []Condition, t string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == t {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsTrue returns true when the condition is met
+func (c *Condition) IsTrue() bool {
+	return c != nil && c.Status == "True"
+}
+
+// IsFailed returns true when the condition reports an error, as opposed to
+// an operation still in progress
+func (c *Condition) IsFailed() bool {
+	return c != nil && c.Status == "False" && c.Severity == SeverityError
+}
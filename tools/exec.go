@@ -0,0 +1,216 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// DEFAULT_EXEC_TIMEOUT - time given to a command when ExecOptions has
+	// no Timeout
+	DEFAULT_EXEC_TIMEOUT = 60 * time.Second
+	// DEFAULT_MAX_OUTPUT - bytes kept of stdout and of stderr when
+	// ExecOptions has no MaxOutput
+	DEFAULT_MAX_OUTPUT = 64 * 1024
+	// execWaitDelay - time given to the children of a killed command to
+	// release its output
+	execWaitDelay = 2 * time.Second
+)
+
+// ExecOptions - limits applied to a command run by Exec
+type ExecOptions struct {
+	// Timeout of the command, DEFAULT_EXEC_TIMEOUT when zero
+	Timeout time.Duration
+	// MaxOutput is the number of bytes kept of stdout and of stderr,
+	// DEFAULT_MAX_OUTPUT when zero. The rest is dropped and replaced by a
+	// truncation marker
+	MaxOutput int
+	// Stdin of the command, empty when nil
+	Stdin io.Reader
+}
+
+// Exec runs argv[0] with the arguments argv[1:], as they are: no shell is
+// involved, so arguments can hold spaces, quotes or JSON. The command is
+// killed when ctx is cancelled or the timeout expires. Nothing is written to
+// stdout, which carries the protocol when ocstack runs as MCP server. The
+// result is returned along with the error, so the output of failed
+// commands is available
+func Exec(ctx context.Context, argv []string, opts ExecOptions) (ToolResult, error) {
+	if len(argv) == 0 {
+		return ToolResult{}, fmt.Errorf("no command to execute")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DEFAULT_EXEC_TIMEOUT
+	}
+	if opts.MaxOutput <= 0 {
+		opts.MaxOutput = DEFAULT_MAX_OUTPUT
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	stdout := &limitedBuffer{max: opts.MaxOutput}
+	stderr := &limitedBuffer{max: opts.MaxOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Stdin = opts.Stdin
+	cmd.WaitDelay = execWaitDelay
+
+	start := time.Now()
+	err := cmd.Run()
+	t := ToolResult{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Duration:  time.Since(start),
+		Truncated: stdout.dropped > 0 || stderr.dropped > 0,
+	}
+	if err == nil {
+		return t, nil
+	}
+
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		t.ExitCode = exitError.ExitCode()
+	}
+	// The deadline of the caller cancels the command, it is not our timeout
+	switch {
+	case parent.Err() != nil:
+		return t, fmt.Errorf("command cancelled: %w", parent.Err())
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		t.TimedOut = true
+		return t, fmt.Errorf("command timed out after %s", opts.Timeout)
+	}
+	return t, fmt.Errorf("command failed with error: %s", err)
+}
+
+// Runner - runs oc commands against a namespace, input is passed on stdin
+// when not nil. OCRunner executes the oc client, a stand-in answering from
+// fixtures can replace it
+type Runner interface {
+	Run(ctx context.Context, ns string, input []byte, args ...string) (ToolResult, error)
+}
+
+// OCRunner - a Runner executing oc with the given limits
+type OCRunner struct {
+	Options ExecOptions
+}
+
+// Run executes oc -n ns with args
+func (r OCRunner) Run(ctx context.Context, ns string, input []byte, args ...string) (ToolResult, error) {
+	opts := r.Options
+	if input != nil {
+		opts.Stdin = bytes.NewReader(input)
+	}
+	return Exec(ctx, append([]string{"oc", "-n", ns}, args...), opts)
+}
+
+// ExecTool executes a command with arguments and returns the result. The
+// arguments are split like a shell would, honoring quotes and backslashes,
+// and the command runs with the default limits.
+//
+// Deprecated: use Exec, which takes an argv and a context
+func ExecTool(c string, args string) (ToolResult, error) {
+	argv, err := SplitArgs(args)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	return Exec(context.Background(), append([]string{c}, argv...), ExecOptions{})
+}
+
+// SplitArgs splits a command line in arguments like a POSIX shell, without
+// any expansion: words are separated by blanks, single quotes preserve
+// their content, double quotes preserve it except for the \", \\, \$ and \`
+// escapes, and a backslash outside quotes escapes the next character
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in: %s", s)
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '"' {
+					closed = true
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double quote in: %s", s)
+			}
+			inWord = true
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+				word.WriteByte(s[i])
+			}
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// limitedBuffer keeps the first max bytes written, and counts the others so
+// that the command never blocks on a full pipe
+type limitedBuffer struct {
+	buf     []byte
+	max     int
+	dropped int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - len(b.buf); room > 0 {
+		if len(p) <= room {
+			b.buf = append(b.buf, p...)
+			return len(p), nil
+		}
+		b.buf = append(b.buf, p[:room]...)
+		b.dropped += len(p) - room
+		return len(p), nil
+	}
+	b.dropped += len(p)
+	return len(p), nil
+}
+
+// String returns the kept output, followed by a truncation marker when
+// bytes were dropped
+func (b *limitedBuffer) String() string {
+	if b.dropped == 0 {
+		return string(b.buf)
+	}
+	return fmt.Sprintf("%s\n[... output truncated, %d bytes omitted]\n", b.buf, b.dropped)
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr string
+	}{
+		{line: "", want: nil},
+		{line: "  get   pods \t-o\nwide ", want: []string{"get", "pods", "-o", "wide"}},
+		{line: `get pod 'my pod'`, want: []string{"get", "pod", "my pod"}},
+		{line: `echo "a \"quoted\" \\ \$HOME \n"`, want: []string{"echo", `a "quoted" \ $HOME \n`}},
+		{line: `echo '$HOME "as is" \n'`, want: []string{"echo", `$HOME "as is" \n`}},
+		{line: `a\ b c\'d`, want: []string{"a b", "c'd"}},
+		{line: `pre'quoted'"parts"post`, want: []string{"prequotedpartspost"}},
+		{line: `''`, want: []string{""}},
+		{
+			line: `patch openstackversion controlplane --type=merge -p '{"spec":{"targetVersion":"18.0.4"}}'`,
+			want: []string{"patch", "openstackversion", "controlplane", "--type=merge", "-p", `{"spec":{"targetVersion":"18.0.4"}}`},
+		},
+		{
+			line: `-p "{\"spec\": {\"replicas\": 2}}"`,
+			want: []string{"-p", `{"spec": {"replicas": 2}}`},
+		},
+		{line: `get 'pods`, wantErr: "unterminated single quote"},
+		{line: `get "pods`, wantErr: "unterminated double quote"},
+		{line: `get "pods\"`, wantErr: "unterminated double quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := SplitArgs(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SplitArgs(%q) error = %v, want %q", tt.line, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SplitArgs(%q) error = %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitArgs(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExec(t *testing.T) {
+	tests := []struct {
+		name         string
+		argv         []string
+		opts         ExecOptions
+		wantStdout   string
+		wantStderr   string
+		wantExitCode int
+		wantErr      string
+		timedOut     bool
+		truncated    bool
+	}{
+		{
+			name: "success",
+			argv: []string{"true"},
+		},
+		{
+			name: "arguments as they are",
+			argv: []string{"sh", "-c", `printf '%s|' "$@"`, "sh", "a b", `{"k": "v"}`, "$HOME"},
+			// No shell splits or expands the arguments
+			wantStdout: `a b|{"k": "v"}|$HOME|`,
+		},
+		{
+			name:         "exit code",
+			argv:         []string{"sh", "-c", "echo failed >&2; exit 3"},
+			wantStderr:   "failed\n",
+			wantExitCode: 3,
+			wantErr:      "command failed",
+		},
+		{
+			name:    "timeout",
+			argv:    []string{"sleep", "10"},
+			opts:    ExecOptions{Timeout: 100 * time.Millisecond},
+			wantErr: "command timed out after 100ms",
+			// Killed by a signal
+			wantExitCode: -1,
+			timedOut:     true,
+		},
+		{
+			name:       "stdout truncated",
+			argv:       []string{"sh", "-c", "printf 0123456789"},
+			opts:       ExecOptions{MaxOutput: 4},
+			wantStdout: "0123\n[... output truncated, 6 bytes omitted]\n",
+			truncated:  true,
+		},
+		{
+			name:         "stderr truncated",
+			argv:         []string{"sh", "-c", "printf 0123456789 >&2; exit 1"},
+			opts:         ExecOptions{MaxOutput: 8},
+			wantStderr:   "01234567\n[... output truncated, 2 bytes omitted]\n",
+			wantExitCode: 1,
+			wantErr:      "command failed",
+			truncated:    true,
+		},
+		{
+			name:       "stdin",
+			argv:       []string{"cat"},
+			opts:       ExecOptions{Stdin: strings.NewReader("input")},
+			wantStdout: "input",
+		},
+		{
+			name:         "unknown command",
+			argv:         []string{"ocstack-no-such-command"},
+			wantErr:      "executable file not found",
+			wantExitCode: 0,
+		},
+		{
+			name:    "no command",
+			argv:    nil,
+			wantErr: "no command to execute",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			got, err := Exec(context.Background(), tt.argv, tt.opts)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Exec() error = %v, want %q", err, tt.wantErr)
+			}
+			if got.Stdout != tt.wantStdout {
+				t.Errorf("Stdout = %q, want %q", got.Stdout, tt.wantStdout)
+			}
+			if got.Stderr != tt.wantStderr {
+				t.Errorf("Stderr = %q, want %q", got.Stderr, tt.wantStderr)
+			}
+			if got.ExitCode != tt.wantExitCode {
+				t.Errorf("ExitCode = %d, want %d", got.ExitCode, tt.wantExitCode)
+			}
+			if got.TimedOut != tt.timedOut {
+				t.Errorf("TimedOut = %v, want %v", got.TimedOut, tt.timedOut)
+			}
+			if got.Truncated != tt.truncated {
+				t.Errorf("Truncated = %v, want %v", got.Truncated, tt.truncated)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Exec() returned after %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestExecCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The deadline of ctx is not the timeout of the command
+	got, err := Exec(ctx, []string{"sleep", "10"}, ExecOptions{Timeout: time.Minute})
+	if err == nil || !strings.Contains(err.Error(), "command cancelled") {
+		t.Fatalf("Exec() error = %v, want command cancelled", err)
+	}
+	if got.TimedOut {
+		t.Error("TimedOut = true for a cancelled command")
+	}
+}
@@ -0,0 +1,265 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HealthReport - the health of an OpenStack control plane and of the service
+// CRs it references, as reported by their status conditions
+type HealthReport struct {
+	Namespace string `json:"namespace"`
+	// Every resource is Ready
+	Healthy bool   `json:"healthy"`
+	Summary string `json:"summary"`
+	// Conditions that are not met, the most relevant first
+	Issues    []HealthIssue    `json:"issues,omitempty"`
+	Resources []ResourceHealth `json:"resources"`
+}
+
+// ResourceHealth - the Ready condition of a CR
+type ResourceHealth struct {
+	Kind               string `json:"kind"`
+	Name               string `json:"name"`
+	Ready              bool   `json:"ready"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	Severity           string `json:"severity,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// HealthIssue - a condition of a CR that is not met
+type HealthIssue struct {
+	Kind               string `json:"kind"`
+	Name               string `json:"name"`
+	Condition          string `json:"condition"`
+	Status             string `json:"status"`
+	Severity           string `json:"severity,omitempty"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// controlPlaneServices - the kinds of the CRs created by the
+// OpenStackControlPlane, by key of its spec
+var controlPlaneServices = []struct {
+	key   string
+	kinds []string
+}{
+	{"dns", []string{"DNSMasq"}},
+	{"galera", []string{"Galera"}},
+	{"memcached", []string{"Memcached"}},
+	{"redis", []string{"Redis"}},
+	{"rabbitmq", []string{"RabbitmqCluster"}},
+	{"keystone", []string{"KeystoneAPI"}},
+	{"placement", []string{"PlacementAPI"}},
+	{"glance", []string{"Glance"}},
+	{"cinder", []string{"Cinder"}},
+	{"ovn", []string{"OVNDBCluster", "OVNNorthd", "OVNController"}},
+	{"neutron", []string{"NeutronAPI"}},
+	{"nova", []string{"Nova"}},
+	{"heat", []string{"Heat"}},
+	{"horizon", []string{"Horizon"}},
+	{"ironic", []string{"Ironic"}},
+	{"manila", []string{"Manila"}},
+	{"swift", []string{"Swift"}},
+	{"octavia", []string{"Octavia"}},
+	{"designate", []string{"Designate"}},
+	{"barbican", []string{"Barbican"}},
+	{"telemetry", []string{"Telemetry"}},
+	{"watcher", []string{"Watcher"}},
+}
+
+// optionalKinds - the kinds an enabled service only creates in some
+// configurations, which are not reported when missing: no OVNController runs
+// on the control plane without nicMappings
+var optionalKinds = map[string]bool{
+	"OVNController": true,
+}
+
+// controlPlaneCR - the fields of the OpenStackControlPlane CR we read
+type controlPlaneCR struct {
+	crStatus
+	Spec map[string]json.RawMessage `json:"spec"`
+}
+
+// enabled returns true when the service of the spec key is enabled
+func (cp *controlPlaneCR) enabled(key string) bool {
+	var service struct {
+		Enabled bool `json:"enabled"`
+	}
+	raw, ok := cp.Spec[key]
+	return ok && json.Unmarshal(raw, &service) == nil && service.Enabled
+}
+
+// GetHealthReport walks the OpenStackControlPlane of ns and the CRs of its
+// enabled services, and reports the conditions that are not met
+func GetHealthReport(ctx context.Context, ns string) (*HealthReport, error) {
+	return readHealthReport(ctx, OCRunner{}, ns)
+}
+
+func readHealthReport(ctx context.Context, r Runner, ns string) (*HealthReport, error) {
+	res, err := r.Run(ctx, ns, nil, "get", "openstackcontrolplane", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("could not list openstackcontrolplane: %v: %s", err, strings.TrimSpace(res.Stderr))
+	}
+	var list struct {
+		Items []controlPlaneCR `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(res.Stdout), &list); err != nil {
+		return nil, fmt.Errorf("invalid openstackcontrolplane list: %w", err)
+	}
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("no OpenStackControlPlane found in %s", ns)
+	}
+
+	report := &HealthReport{Namespace: ns}
+	for _, cp := range list.Items {
+		report.add(&cp.crStatus)
+
+		// Kinds are listed once, even when several services use them
+		listed := map[string][]crStatus{}
+		for _, service := range controlPlaneServices {
+			if !cp.enabled(service.key) {
+				continue
+			}
+			for _, kind := range service.kinds {
+				if _, ok := listed[kind]; !ok {
+					if listed[kind], err = listCRs(ctx, r, ns, strings.ToLower(kind)); err != nil {
+						return nil, err
+					}
+				}
+				found := false
+				for i := range listed[kind] {
+					if cr := &listed[kind][i]; cr.ownedBy(cp.Metadata.UID) {
+						report.add(cr)
+						found = true
+					}
+				}
+				if !found && !optionalKinds[kind] {
+					report.Issues = append(report.Issues, HealthIssue{
+						Kind:      kind,
+						Condition: "Created",
+						Status:    "False",
+						Severity:  SeverityWarning,
+						Reason:    "NotFound",
+						Message: fmt.Sprintf("%s is enabled but no %s is owned by the OpenStackControlPlane %s",
+							service.key, kind, cp.Metadata.Name),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].before(&report.Issues[j])
+	})
+	notReady := 0
+	for _, resource := range report.Resources {
+		if !resource.Ready {
+			notReady++
+		}
+	}
+	report.Healthy = len(report.Issues) == 0
+	report.Summary = fmt.Sprintf("%d of %d resources not ready, %d conditions not met",
+		notReady, len(report.Resources), len(report.Issues))
+	if len(report.Issues) > 0 {
+		first := report.Issues[0]
+		report.Summary += fmt.Sprintf(", most relevant: %s %s %s", first.Kind, first.Name, first.Condition)
+		if first.Message != "" {
+			report.Summary += ": " + first.Message
+		}
+	}
+	return report, nil
+}
+
+// add records the Ready condition of cr and the conditions it does not meet
+func (h *HealthReport) add(cr *crStatus) {
+	resource := ResourceHealth{Kind: cr.Kind, Name: cr.Metadata.Name}
+	if ready := findCondition(cr.Status.Conditions, "Ready"); ready != nil {
+		resource.Ready = ready.IsTrue()
+		resource.Reason = ready.Reason
+		resource.Message = ready.Message
+		resource.Severity = ready.Severity
+		resource.LastTransitionTime = ready.LastTransitionTime
+	}
+	h.Resources = append(h.Resources, resource)
+
+	if len(cr.Status.Conditions) == 0 {
+		h.Issues = append(h.Issues, HealthIssue{
+			Kind:      cr.Kind,
+			Name:      cr.Metadata.Name,
+			Condition: "Ready",
+			Status:    "Unknown",
+			Message:   "no status reported yet",
+		})
+	}
+	for _, c := range cr.Status.Conditions {
+		if c.IsTrue() {
+			continue
+		}
+		h.Issues = append(h.Issues, HealthIssue{
+			Kind:               cr.Kind,
+			Name:               cr.Metadata.Name,
+			Condition:          c.Type,
+			Status:             c.Status,
+			Severity:           c.Severity,
+			Reason:             c.Reason,
+			Message:            c.Message,
+			LastTransitionTime: c.LastTransitionTime,
+		})
+	}
+}
+
+// before ranks the issues: the conditions reported by the CRs before the
+// missing CRs, errors first, then the causes before the Ready conditions and
+// the OpenStackControlPlane which only aggregate them, then the oldest
+// failure, likely the one the others follow from
+func (i *HealthIssue) before(o *HealthIssue) bool {
+	if a, b := i.missing(), o.missing(); a != b {
+		return b
+	}
+	if a, b := severityRank(i.Severity), severityRank(o.Severity); a != b {
+		return a < b
+	}
+	if a, b := i.aggregate(), o.aggregate(); a != b {
+		return b
+	}
+	if i.LastTransitionTime != o.LastTransitionTime {
+		// RFC 3339 timestamps sort as strings, unknown times go last
+		return o.LastTransitionTime == "" ||
+			(i.LastTransitionTime != "" && i.LastTransitionTime < o.LastTransitionTime)
+	}
+	if i.Kind != o.Kind {
+		return i.Kind < o.Kind
+	}
+	if i.Name != o.Name {
+		return i.Name < o.Name
+	}
+	return i.Condition < o.Condition
+}
+
+// aggregate returns true when the condition summarizes other conditions
+func (i *HealthIssue) aggregate() bool {
+	return i.Condition == "Ready" || i.Kind == "OpenStackControlPlane"
+}
+
+// missing returns true when the issue reports a CR that was not found
+func (i *HealthIssue) missing() bool {
+	return i.Condition == "Created" && i.Reason == "NotFound"
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityError:
+		return 0
+	case SeverityWarning:
+		return 1
+	case SeverityInfo:
+		return 2
+	}
+	return 3
+}
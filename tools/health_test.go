@@ -0,0 +1,169 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// cannedRunner - a Runner returning the output of the get commands by kind,
+// an empty list for the kinds it does not know
+type cannedRunner map[string]any
+
+func (r cannedRunner) Run(ctx context.Context, ns string, input []byte, args ...string) (ToolResult, error) {
+	if len(args) < 2 || args[0] != "get" {
+		err := fmt.Errorf("unexpected command %v", args)
+		return ToolResult{Stderr: err.Error(), ExitCode: 1}, err
+	}
+	output, ok := r[args[1]]
+	if !ok {
+		output = list()
+	}
+	data, err := json.Marshal(output)
+	return ToolResult{Stdout: string(data)}, err
+}
+
+// owned returns a CR owned by the CR with the given uid
+func owned(kind string, name string, owner string, conditions ...Condition) map[string]any {
+	cr := cr(kind, name, conditions...)
+	cr["metadata"] = map[string]any{
+		"name":            name,
+		"ownerReferences": []map[string]any{{"kind": "OpenStackControlPlane", "uid": owner}},
+	}
+	return cr
+}
+
+func TestHealthReport(t *testing.T) {
+	controlPlane := cr("OpenStackControlPlane", "controlplane",
+		Condition{Type: "Ready", Status: "False", Severity: SeverityError, Reason: "Error",
+			Message: "OpenStackControlPlane KeystoneAPI error occured", LastTransitionTime: "2024-10-08T10:05:00Z"},
+		Condition{Type: "OpenStackControlPlaneKeystoneAPIReady", Status: "False", Severity: SeverityError,
+			Reason: "Error", LastTransitionTime: "2024-10-08T10:05:00Z"},
+	)
+	controlPlane["metadata"] = map[string]any{"name": "controlplane", "uid": "cp"}
+	controlPlane["spec"] = map[string]any{
+		"keystone": map[string]any{"enabled": true},
+		"galera":   map[string]any{"enabled": true},
+		"ovn":      map[string]any{"enabled": true},
+		"nova":     map[string]any{"enabled": false},
+	}
+
+	runner := cannedRunner{
+		"openstackcontrolplane": list(controlPlane),
+		"keystoneapi": list(owned("KeystoneAPI", "keystone", "cp",
+			Condition{Type: "Ready", Status: "False", Severity: SeverityError, Reason: "Error",
+				Message: "Deployment error occurred", LastTransitionTime: "2024-10-08T10:04:00Z"},
+			Condition{Type: "DBReady", Status: "False", Severity: SeverityError, Reason: "Error",
+				Message: "mariadb not found", LastTransitionTime: "2024-10-08T10:00:00Z"},
+		)),
+		"ovndbcluster": list(owned("OVNDBCluster", "ovndbcluster-nb", "cp", ready(true))),
+		"ovnnorthd": list(
+			owned("OVNNorthd", "ovnnorthd", "cp", Condition{Type: "Ready", Status: "False",
+				Severity: SeverityInfo, Reason: "Requested", LastTransitionTime: "2024-10-08T09:00:00Z"}),
+			// Owned by another control plane
+			owned("OVNNorthd", "other", "other", ready(false)),
+		),
+		// No Galera nor OVNController
+	}
+
+	report, err := readHealthReport(context.Background(), runner, "openstack")
+	if err != nil {
+		t.Fatalf("readHealthReport() error = %v", err)
+	}
+	if report.Healthy {
+		t.Error("Healthy = true, want false")
+	}
+
+	var got []string
+	for _, issue := range report.Issues {
+		got = append(got, fmt.Sprintf("%s %s %s", issue.Kind, issue.Name, issue.Condition))
+	}
+	want := []string{
+		// The oldest error first, the aggregated conditions after their cause
+		"KeystoneAPI keystone DBReady",
+		"KeystoneAPI keystone Ready",
+		"OpenStackControlPlane controlplane OpenStackControlPlaneKeystoneAPIReady",
+		"OpenStackControlPlane controlplane Ready",
+		"OVNNorthd ovnnorthd Ready",
+		// Missing CRs are reported last, OVNController may not exist
+		"Galera  Created",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Issues =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(report.Summary, "most relevant: KeystoneAPI keystone DBReady: mariadb not found") {
+		t.Errorf("Summary = %q, want the DBReady condition of KeystoneAPI as the most relevant", report.Summary)
+	}
+	if want := "3 of 4 resources not ready, 6 conditions not met"; !strings.HasPrefix(report.Summary, want) {
+		t.Errorf("Summary = %q, want prefix %q", report.Summary, want)
+	}
+}
+
+func TestHealthIssueRanking(t *testing.T) {
+	missing := HealthIssue{Kind: "Galera", Condition: "Created", Status: "False", Severity: SeverityWarning, Reason: "NotFound"}
+	tests := []struct {
+		name          string
+		first, second HealthIssue
+	}{
+		{
+			name:   "error before info",
+			first:  HealthIssue{Kind: "Nova", Condition: "Ready", Severity: SeverityError},
+			second: HealthIssue{Kind: "Cinder", Condition: "DBReady", Severity: SeverityInfo},
+		},
+		{
+			name:   "cause before Ready",
+			first:  HealthIssue{Kind: "Nova", Condition: "DBReady", Severity: SeverityError, LastTransitionTime: "2024-10-08T11:00:00Z"},
+			second: HealthIssue{Kind: "Nova", Condition: "Ready", Severity: SeverityError, LastTransitionTime: "2024-10-08T10:00:00Z"},
+		},
+		{
+			name:   "cause before OpenStackControlPlane",
+			first:  HealthIssue{Kind: "Nova", Condition: "DBReady", Severity: SeverityError},
+			second: HealthIssue{Kind: "OpenStackControlPlane", Condition: "OpenStackControlPlaneNovaReady", Severity: SeverityError},
+		},
+		{
+			name:   "oldest first",
+			first:  HealthIssue{Kind: "Nova", Condition: "DBReady", Severity: SeverityError, LastTransitionTime: "2024-10-08T10:00:00Z"},
+			second: HealthIssue{Kind: "Cinder", Condition: "DBReady", Severity: SeverityError, LastTransitionTime: "2024-10-08T11:00:00Z"},
+		},
+		{
+			name:   "unknown time last",
+			first:  HealthIssue{Kind: "Nova", Condition: "DBReady", Severity: SeverityError, LastTransitionTime: "2024-10-08T10:00:00Z"},
+			second: HealthIssue{Kind: "Cinder", Condition: "DBReady", Severity: SeverityError},
+		},
+		{
+			name:   "error before missing CR",
+			first:  HealthIssue{Kind: "Nova", Condition: "Ready", Severity: SeverityError},
+			second: missing,
+		},
+		{
+			name:   "no status before missing CR",
+			first:  HealthIssue{Kind: "Nova", Condition: "Ready", Status: "Unknown"},
+			second: missing,
+		},
+		{
+			name:   "aggregated condition before missing CR",
+			first:  HealthIssue{Kind: "OpenStackControlPlane", Condition: "Ready", Severity: SeverityInfo},
+			second: missing,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.first.before(&tt.second) {
+				t.Errorf("%+v not ranked before %+v", tt.first, tt.second)
+			}
+			if tt.second.before(&tt.first) {
+				t.Errorf("%+v ranked before %+v", tt.second, tt.first)
+			}
+
+			// The order does not depend on the order of the report
+			issues := []HealthIssue{tt.second, tt.first}
+			sort.SliceStable(issues, func(i, j int) bool { return issues[i].before(&issues[j]) })
+			if issues[0] != tt.first {
+				t.Errorf("sorted issues = %+v, want %+v first", issues, tt.first)
+			}
+		})
+	}
+}
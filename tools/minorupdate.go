@@ -0,0 +1,352 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DEFAULT_UPDATE_POLL_INTERVAL - delay between two checks of the update
+	DEFAULT_UPDATE_POLL_INTERVAL = 30 * time.Second
+	// DEFAULT_UPDATE_PHASE_TIMEOUT - time given to each phase of the update
+	DEFAULT_UPDATE_PHASE_TIMEOUT = 3 * time.Hour
+)
+
+// UpdatePhase - a step of the minor update, the operator confirms each one
+type UpdatePhase string
+
+const (
+	// The target version is set, the operator updates OVN on the control
+	// plane
+	PhaseOVNControlplane UpdatePhase = "ovn-controlplane"
+	// A dataplane deployment updates OVN on the nodes
+	PhaseOVNDataplane UpdatePhase = "ovn-dataplane"
+	// The operator updates the control plane services
+	PhaseControlplane UpdatePhase = "controlplane"
+	// A dataplane deployment updates the remaining services of the nodes
+	PhaseDataplane UpdatePhase = "dataplane"
+)
+
+// ErrUpdateStopped is returned when the operator declines a phase. The
+// update can be resumed by running it again
+var ErrUpdateStopped = errors.New("minor update stopped before completion")
+
+// MinorUpdate - runs the minor update of an OpenStack deployment: preflight
+// checks, then the update of OVN and of the other services, on the control
+// plane and on the data plane, waiting for the completion of each phase.
+// The update is resumed when it is already in progress for the same version
+type MinorUpdate struct {
+	Runner    Runner
+	Namespace string
+	// OpenStackVersion CR, the only one of the namespace when empty
+	Name string
+	// Version to update to, the available version when empty
+	TargetVersion string
+
+	// Confirm is asked before each phase, the update stops when it returns
+	// false. Every phase runs when nil
+	Confirm func(phase UpdatePhase, description string) bool
+	// Logf reports the progress of the update
+	Logf func(format string, args ...any)
+
+	// Zero values select DEFAULT_UPDATE_POLL_INTERVAL and
+	// DEFAULT_UPDATE_PHASE_TIMEOUT
+	PollInterval time.Duration
+	PhaseTimeout time.Duration
+}
+
+// updateStep - a phase of the update: an action, then a condition of the
+// OpenStackVersion marking its completion
+type updateStep struct {
+	phase       UpdatePhase
+	description string
+	condition   string
+	run         func(ctx context.Context) error
+}
+
+// Run executes the update until its completion, an error or a phase
+// declined by the operator
+func (u *MinorUpdate) Run(ctx context.Context) error {
+	v, nodeSets, err := u.preflight(ctx)
+	if err != nil || v == nil {
+		return err
+	}
+	target := u.TargetVersion
+
+	steps := []updateStep{
+		{
+			phase:       PhaseOVNControlplane,
+			description: fmt.Sprintf("Set the target version of %s to %s, OVN is updated on the control plane first", v.Name, target),
+			condition:   "MinorUpdateOVNControlplane",
+			run: func(ctx context.Context) error {
+				return u.setTargetVersion(ctx, v.Name, target)
+			},
+		},
+		{
+			phase:       PhaseOVNDataplane,
+			description: fmt.Sprintf("Create the OpenStackDataPlaneDeployment %s updating OVN on the node sets %v", u.deploymentName("ovn"), nodeSets),
+			condition:   "MinorUpdateOVNDataplane",
+			run: func(ctx context.Context) error {
+				return u.deploy(ctx, u.deploymentName("ovn"), nodeSets, "ovn")
+			},
+		},
+		{
+			phase:       PhaseControlplane,
+			description: "Wait for the update of the control plane services",
+			condition:   "MinorUpdateControlplane",
+		},
+		{
+			phase:       PhaseDataplane,
+			description: fmt.Sprintf("Create the OpenStackDataPlaneDeployment %s updating the node sets %v", u.deploymentName("update"), nodeSets),
+			condition:   "MinorUpdateDataplane",
+			run: func(ctx context.Context) error {
+				return u.deploy(ctx, u.deploymentName("update"), nodeSets, "update")
+			},
+		},
+	}
+
+	for _, step := range steps {
+		current, err := readOpenStackVersion(ctx, u.Runner, u.Namespace, v.Name)
+		if err != nil {
+			return err
+		}
+		if current.TargetVersion == target && findCondition(current.Conditions, step.condition).IsTrue() {
+			u.logf("[%s] already completed", step.phase)
+			continue
+		}
+
+		if u.Confirm != nil && !u.Confirm(step.phase, step.description) {
+			return ErrUpdateStopped
+		}
+		u.logf("[%s] %s", step.phase, step.description)
+		if step.run != nil {
+			if err := step.run(ctx); err != nil {
+				return fmt.Errorf("%s: %w", step.phase, err)
+			}
+		}
+		if err := u.waitCondition(ctx, step.phase, v.Name, step.condition); err != nil {
+			return fmt.Errorf("%s: %w", step.phase, err)
+		}
+		u.logf("[%s] completed", step.phase)
+	}
+
+	final, err := readOpenStackVersion(ctx, u.Runner, u.Namespace, v.Name)
+	if err != nil {
+		return err
+	}
+	u.logf("Minor update completed, deployed version: %s", final.DeployedVersion)
+	return nil
+}
+
+// preflight checks that the update can start, or be resumed, and returns the
+// OpenStackVersion along with the node sets to update. A nil OpenStackVersion
+// means there is nothing to update
+func (u *MinorUpdate) preflight(ctx context.Context) (*OpenStackVersion, []string, error) {
+	if u.Runner == nil {
+		u.Runner = OCRunner{}
+	}
+	v, err := readOpenStackVersion(ctx, u.Runner, u.Namespace, u.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.TargetVersion == "" {
+		u.TargetVersion = v.AvailableVersion
+	}
+	if v.DeployedVersion == u.TargetVersion {
+		u.logf("OpenStack is already at version %s", v.DeployedVersion)
+		return nil, nil, nil
+	}
+
+	nodeSets, err := listCRs(ctx, u.Runner, u.Namespace, "openstackdataplanenodeset")
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0, len(nodeSets))
+	for _, nodeSet := range nodeSets {
+		names = append(names, nodeSet.Metadata.Name)
+	}
+
+	// Resources are expected to be unready during the update
+	if v.UpdateInProgress && v.TargetVersion == u.TargetVersion {
+		u.logf("Resuming the update of %s from %s to %s", v.Name, v.DeployedVersion, u.TargetVersion)
+		return v, names, nil
+	}
+	if v.UpdateInProgress {
+		return nil, nil, fmt.Errorf("an update to %s is already in progress", v.TargetVersion)
+	}
+	if err := v.CanUpdateTo(u.TargetVersion); err != nil {
+		return nil, nil, err
+	}
+
+	var problems []string
+	controlPlanes, err := listCRs(ctx, u.Runner, u.Namespace, "openstackcontrolplane")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(controlPlanes) == 0 {
+		problems = append(problems, "no OpenStackControlPlane found")
+	}
+	for _, cr := range append(controlPlanes, nodeSets...) {
+		if ready := findCondition(cr.Status.Conditions, "Ready"); !ready.IsTrue() {
+			problems = append(problems, fmt.Sprintf("%s %s is not ready%s", cr.Kind, cr.Metadata.Name, conditionDetails(ready)))
+		}
+	}
+	deployments, err := listCRs(ctx, u.Runner, u.Namespace, "openstackdataplanedeployment")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, cr := range deployments {
+		if ready := findCondition(cr.Status.Conditions, "Ready"); !ready.IsTrue() && !ready.IsFailed() {
+			problems = append(problems, fmt.Sprintf("OpenStackDataPlaneDeployment %s is still running", cr.Metadata.Name))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("preflight checks failed: %v", problems)
+	}
+
+	u.logf("Preflight checks passed: %s can be updated from %s to %s (node sets: %v)",
+		v.Name, v.DeployedVersion, u.TargetVersion, names)
+	return v, names, nil
+}
+
+// setTargetVersion patches the OpenStackVersion, which starts the update
+func (u *MinorUpdate) setTargetVersion(ctx context.Context, name string, target string) error {
+	patch, _ := json.Marshal(map[string]any{
+		"spec": map[string]any{
+			"targetVersion": target,
+		},
+	})
+	res, err := u.Runner.Run(ctx, u.Namespace, nil, "patch", "openstackversion", name, "--type=merge", "-p", string(patch))
+	if err != nil {
+		return fmt.Errorf("could not set the target version: %v: %s", err, strings.TrimSpace(res.Stderr))
+	}
+	return nil
+}
+
+// deploymentName returns the name of the dataplane deployment running
+// service, which is stable for the target version so that a resumed update
+// monitors the existing deployment
+func (u *MinorUpdate) deploymentName(service string) string {
+	// Names are DNS subdomains, drop the build metadata of the version
+	version := strings.ToLower(strings.SplitN(u.TargetVersion, "+", 2)[0])
+	return fmt.Sprintf("ocstack-%s-%s", service, version)
+}
+
+// deploy creates a dataplane deployment running service on the node sets,
+// unless it exists already, and waits for its completion
+func (u *MinorUpdate) deploy(ctx context.Context, name string, nodeSets []string, service string) error {
+	if len(nodeSets) == 0 {
+		u.logf("No OpenStackDataPlaneNodeSet, the data plane is not updated")
+		return nil
+	}
+
+	existing, err := getCR(ctx, u.Runner, u.Namespace, "openstackdataplanedeployment", name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		manifest, _ := json.Marshal(map[string]any{
+			"apiVersion": "dataplane.openstack.org/v1beta1",
+			"kind":       "OpenStackDataPlaneDeployment",
+			"metadata": map[string]any{
+				"name":      name,
+				"namespace": u.Namespace,
+			},
+			"spec": map[string]any{
+				"nodeSets":         nodeSets,
+				"servicesOverride": []string{service},
+			},
+		})
+		res, err := u.Runner.Run(ctx, u.Namespace, manifest, "create", "-f", "-")
+		if err != nil {
+			return fmt.Errorf("could not create the OpenStackDataPlaneDeployment %s: %v: %s", name, err, strings.TrimSpace(res.Stderr))
+		}
+	} else {
+		u.logf("Monitoring the existing OpenStackDataPlaneDeployment %s", name)
+	}
+
+	return u.poll(ctx, func() (bool, error) {
+		cr, err := getCR(ctx, u.Runner, u.Namespace, "openstackdataplanedeployment", name)
+		if err != nil || cr == nil {
+			return false, err
+		}
+		ready := findCondition(cr.Status.Conditions, "Ready")
+		if ready.IsFailed() {
+			return false, fmt.Errorf("OpenStackDataPlaneDeployment %s failed%s", name, conditionDetails(ready))
+		}
+		return ready.IsTrue(), nil
+	})
+}
+
+// waitCondition waits for a condition of the OpenStackVersion to be met,
+// reporting the progress messages of the operator
+func (u *MinorUpdate) waitCondition(ctx context.Context, phase UpdatePhase, name string, condition string) error {
+	var last string
+	return u.poll(ctx, func() (bool, error) {
+		v, err := readOpenStackVersion(ctx, u.Runner, u.Namespace, name)
+		if err != nil {
+			return false, err
+		}
+		c := findCondition(v.Conditions, condition)
+		if c.IsFailed() {
+			return false, fmt.Errorf("%s failed%s", condition, conditionDetails(c))
+		}
+		if c != nil && c.Message != "" && c.Message != last {
+			u.logf("[%s] %s", phase, c.Message)
+			last = c.Message
+		}
+		return c.IsTrue(), nil
+	})
+}
+
+// poll calls check until it returns true or an error, or the phase times out
+func (u *MinorUpdate) poll(ctx context.Context, check func() (bool, error)) error {
+	interval := u.PollInterval
+	if interval <= 0 {
+		interval = DEFAULT_UPDATE_POLL_INTERVAL
+	}
+	timeout := u.PhaseTimeout
+	if timeout <= 0 {
+		timeout = DEFAULT_UPDATE_PHASE_TIMEOUT
+	}
+	deadline := time.After(timeout)
+
+	for {
+		done, err := check()
+		if err != nil || done {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("not completed after %s", timeout)
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (u *MinorUpdate) logf(format string, args ...any) {
+	if u.Logf != nil {
+		u.Logf(format, args...)
+	}
+}
+
+// conditionDetails formats the reason and the message of a condition
+func conditionDetails(c *Condition) string {
+	if c == nil {
+		return ""
+	}
+	details := ""
+	if c.Reason != "" {
+		details += " (" + c.Reason + ")"
+	}
+	if c.Message != "" {
+		details += ": " + c.Message
+	}
+	return details
+}
@@ -0,0 +1,337 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	deployedVersion  = "18.0.3-20240925.2"
+	availableVersion = "18.0.4-20241008.1"
+)
+
+// The conditions of the OpenStackVersion set by the operator, in order
+var updateConditions = []string{
+	"MinorUpdateOVNControlplane",
+	"MinorUpdateOVNDataplane",
+	"MinorUpdateControlplane",
+	"MinorUpdateDataplane",
+}
+
+// fakeOpenStack - a Runner scripting the OpenStack operators: a condition of
+// the OpenStackVersion is met on the second read after its prerequisites
+// hold, a dataplane deployment completes once it has been read
+type fakeOpenStack struct {
+	target   string
+	deployed string
+	nodeSets []string
+	// The OpenStackControlPlane is not ready
+	unready bool
+	// Conditions met, and number of reads since their prerequisites hold
+	met   map[string]bool
+	reads map[string]int
+	// Status of the dataplane deployments: Running, Succeeded or Failed
+	deployments map[string]string
+	// Deployment failing, condition never met
+	failing string
+	stuck   string
+	// The patch and create commands run
+	actions []string
+}
+
+func newFakeOpenStack() *fakeOpenStack {
+	return &fakeOpenStack{
+		target:      deployedVersion,
+		deployed:    deployedVersion,
+		nodeSets:    []string{"compute"},
+		met:         map[string]bool{},
+		reads:       map[string]int{},
+		deployments: map[string]string{},
+	}
+}
+
+func (f *fakeOpenStack) Run(ctx context.Context, ns string, input []byte, args ...string) (ToolResult, error) {
+	switch {
+	case args[0] == "get" && args[1] == "openstackversion":
+		f.operate()
+		return f.result(f.version())
+	case args[0] == "get" && args[1] == "openstackcontrolplane":
+		return f.result(list(cr("OpenStackControlPlane", "controlplane", ready(!f.unready))))
+	case args[0] == "get" && args[1] == "openstackdataplanenodeset":
+		var items []map[string]any
+		for _, name := range f.nodeSets {
+			items = append(items, cr("OpenStackDataPlaneNodeSet", name, ready(true)))
+		}
+		return f.result(list(items...))
+	case args[0] == "get" && args[1] == "openstackdataplanedeployment" && args[2] != "-o":
+		status, ok := f.deployments[args[2]]
+		if !ok {
+			return ToolResult{}, nil
+		}
+		if status == "Running" {
+			f.deployments[args[2]] = "Succeeded"
+			if args[2] == f.failing {
+				f.deployments[args[2]] = "Failed"
+			}
+		}
+		return f.result(deployment(args[2], status))
+	case args[0] == "get" && args[1] == "openstackdataplanedeployment":
+		var items []map[string]any
+		for name, status := range f.deployments {
+			items = append(items, deployment(name, status))
+		}
+		return f.result(list(items...))
+	case args[0] == "patch" && args[1] == "openstackversion":
+		var patch struct {
+			Spec struct {
+				TargetVersion string `json:"targetVersion"`
+			} `json:"spec"`
+		}
+		if err := json.Unmarshal([]byte(args[len(args)-1]), &patch); err != nil {
+			return ToolResult{Stderr: err.Error(), ExitCode: 1}, err
+		}
+		f.target = patch.Spec.TargetVersion
+		f.actions = append(f.actions, "patch "+f.target)
+		return ToolResult{}, nil
+	case args[0] == "create":
+		var manifest crStatus
+		if err := json.Unmarshal(input, &manifest); err != nil {
+			return ToolResult{Stderr: err.Error(), ExitCode: 1}, err
+		}
+		f.deployments[manifest.Metadata.Name] = "Running"
+		f.actions = append(f.actions, "create "+manifest.Metadata.Name)
+		return ToolResult{}, nil
+	}
+	err := fmt.Errorf("unexpected command %v", args)
+	return ToolResult{Stderr: err.Error(), ExitCode: 1}, err
+}
+
+func (f *fakeOpenStack) result(v any) (ToolResult, error) {
+	data, err := json.Marshal(v)
+	return ToolResult{Stdout: string(data)}, err
+}
+
+// operate updates the first condition of the OpenStackVersion not met yet
+func (f *fakeOpenStack) operate() {
+	for _, condition := range updateConditions {
+		if f.met[condition] {
+			continue
+		}
+		var prerequisites bool
+		switch condition {
+		case "MinorUpdateOVNControlplane":
+			prerequisites = f.target != f.deployed
+		case "MinorUpdateOVNDataplane":
+			prerequisites = f.deploymentDone("ovn")
+		case "MinorUpdateDataplane":
+			prerequisites = f.deploymentDone("update")
+		default:
+			prerequisites = true
+		}
+		if prerequisites && condition != f.stuck {
+			f.reads[condition]++
+			f.met[condition] = f.reads[condition] >= 2
+		}
+		break
+	}
+	if f.met["MinorUpdateDataplane"] {
+		f.deployed = f.target
+	}
+}
+
+func (f *fakeOpenStack) deploymentDone(service string) bool {
+	return len(f.nodeSets) == 0 || f.deployments[fmt.Sprintf("ocstack-%s-%s", service, f.target)] == "Succeeded"
+}
+
+func (f *fakeOpenStack) version() map[string]any {
+	conditions := []Condition{ready(f.target == f.deployed)}
+	for _, condition := range updateConditions {
+		conditions = append(conditions, Condition{Type: condition, Status: status(f.met[condition])})
+	}
+	return map[string]any{
+		"kind":     "OpenStackVersion",
+		"metadata": map[string]any{"name": "controlplane"},
+		"spec":     map[string]any{"targetVersion": f.target},
+		"status": map[string]any{
+			"deployedVersion":  f.deployed,
+			"availableVersion": availableVersion,
+			"conditions":       conditions,
+		},
+	}
+}
+
+func status(met bool) string {
+	if met {
+		return "True"
+	}
+	return "False"
+}
+
+func ready(met bool) Condition {
+	return Condition{Type: "Ready", Status: status(met)}
+}
+
+func cr(kind string, name string, conditions ...Condition) map[string]any {
+	return map[string]any{
+		"kind":     kind,
+		"metadata": map[string]any{"name": name},
+		"status":   map[string]any{"conditions": conditions},
+	}
+}
+
+func list(items ...map[string]any) map[string]any {
+	return map[string]any{"kind": "List", "items": items}
+}
+
+func deployment(name string, status string) map[string]any {
+	c := ready(status == "Succeeded")
+	switch status {
+	case "Running":
+		c.Severity, c.Reason = SeverityInfo, "Deploying"
+	case "Failed":
+		c.Severity, c.Reason, c.Message = SeverityError, "DeploymentError", "ansible-playbook failed"
+	}
+	return cr("OpenStackDataPlaneDeployment", name, c)
+}
+
+func TestMinorUpdate(t *testing.T) {
+	ovnDeployment := "ocstack-ovn-" + availableVersion
+	updateDeployment := "ocstack-update-" + availableVersion
+	allPhases := []UpdatePhase{PhaseOVNControlplane, PhaseOVNDataplane, PhaseControlplane, PhaseDataplane}
+
+	tests := []struct {
+		name string
+		// setup changes the initial state of the deployment
+		setup func(f *fakeOpenStack)
+		// decline is the phase declined by the operator
+		decline   UpdatePhase
+		wantErr   string
+		wantPhase []UpdatePhase
+		wantRun   []string
+	}{
+		{
+			name:      "update",
+			wantPhase: allPhases,
+			wantRun:   []string{"patch " + availableVersion, "create " + ovnDeployment, "create " + updateDeployment},
+		},
+		{
+			name:      "no node set",
+			setup:     func(f *fakeOpenStack) { f.nodeSets = nil },
+			wantPhase: allPhases,
+			wantRun:   []string{"patch " + availableVersion},
+		},
+		{
+			name:    "up to date",
+			setup:   func(f *fakeOpenStack) { f.target, f.deployed = availableVersion, availableVersion },
+			wantRun: nil,
+		},
+		{
+			name:    "preflight failure",
+			setup:   func(f *fakeOpenStack) { f.unready = true },
+			wantErr: "preflight checks failed: [OpenStackControlPlane controlplane is not ready]",
+		},
+		{
+			name:    "deployment still running",
+			setup:   func(f *fakeOpenStack) { f.deployments["edpm"] = "Running" },
+			wantErr: "OpenStackDataPlaneDeployment edpm is still running",
+		},
+		{
+			name:    "update to another version in progress",
+			setup:   func(f *fakeOpenStack) { f.target = "18.0.3-20240925.10" },
+			wantErr: "an update to 18.0.3-20240925.10 is already in progress",
+		},
+		{
+			name: "resume mid-update",
+			setup: func(f *fakeOpenStack) {
+				// Not ready, which does not fail the preflight checks
+				f.unready = true
+				f.target = availableVersion
+				f.met["MinorUpdateOVNControlplane"] = true
+				f.met["MinorUpdateOVNDataplane"] = true
+				f.deployments[ovnDeployment] = "Succeeded"
+			},
+			// The control plane is updated by the operator meanwhile
+			wantPhase: []UpdatePhase{PhaseDataplane},
+			wantRun:   []string{"create " + updateDeployment},
+		},
+		{
+			name: "resume a running deployment",
+			setup: func(f *fakeOpenStack) {
+				f.target = availableVersion
+				f.met["MinorUpdateOVNControlplane"] = true
+				f.deployments[ovnDeployment] = "Running"
+			},
+			wantPhase: []UpdatePhase{PhaseOVNDataplane, PhaseControlplane, PhaseDataplane},
+			wantRun:   []string{"create " + updateDeployment},
+		},
+		{
+			name:      "declined phase",
+			decline:   PhaseOVNDataplane,
+			wantErr:   ErrUpdateStopped.Error(),
+			wantPhase: []UpdatePhase{PhaseOVNControlplane, PhaseOVNDataplane},
+			wantRun:   []string{"patch " + availableVersion},
+		},
+		{
+			name:      "failed deployment",
+			setup:     func(f *fakeOpenStack) { f.failing = ovnDeployment },
+			wantErr:   "ovn-dataplane: OpenStackDataPlaneDeployment " + ovnDeployment + " failed (DeploymentError): ansible-playbook failed",
+			wantPhase: []UpdatePhase{PhaseOVNControlplane, PhaseOVNDataplane},
+			wantRun:   []string{"patch " + availableVersion, "create " + ovnDeployment},
+		},
+		{
+			name:      "phase timeout",
+			setup:     func(f *fakeOpenStack) { f.stuck = "MinorUpdateControlplane" },
+			wantErr:   "controlplane: not completed after 50ms",
+			wantPhase: []UpdatePhase{PhaseOVNControlplane, PhaseOVNDataplane, PhaseControlplane},
+			wantRun:   []string{"patch " + availableVersion, "create " + ovnDeployment},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeOpenStack()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			var phases []UpdatePhase
+			update := &MinorUpdate{
+				Runner:    f,
+				Namespace: "openstack",
+				Confirm: func(phase UpdatePhase, description string) bool {
+					phases = append(phases, phase)
+					return phase != tt.decline
+				},
+				Logf:         t.Logf,
+				PollInterval: time.Millisecond,
+				PhaseTimeout: 50 * time.Millisecond,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err := update.Run(ctx)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Run() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.decline != "" && !errors.Is(err, ErrUpdateStopped) {
+				t.Errorf("Run() error = %v, want ErrUpdateStopped", err)
+			}
+			if !reflect.DeepEqual(phases, tt.wantPhase) {
+				t.Errorf("confirmed phases = %v, want %v", phases, tt.wantPhase)
+			}
+			if !reflect.DeepEqual(f.actions, tt.wantRun) {
+				t.Errorf("commands = %v, want %v", f.actions, tt.wantRun)
+			}
+			if err == nil && f.deployed != f.target {
+				t.Errorf("deployed version = %s after the update, want %s", f.deployed, f.target)
+			}
+		})
+	}
+}
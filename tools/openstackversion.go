@@ -0,0 +1,169 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Condition - a condition of the status of an OpenStack CR
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Severity           string `json:"severity,omitempty"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// OpenStackVersion - the versions of an OpenStack deployment, as reported by
+// its OpenStackVersion CR
+type OpenStackVersion struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Version requested in the spec, the deployment is updated to it
+	TargetVersion string `json:"targetVersion"`
+	// Version running, and latest version provided by the operator
+	DeployedVersion  string `json:"deployedVersion"`
+	AvailableVersion string `json:"availableVersion"`
+	// Versions newer than the deployed one, oldest first
+	UpdatePaths []string `json:"updatePaths,omitempty"`
+	// An update to AvailableVersion can be triggered
+	UpdateAvailable bool `json:"updateAvailable"`
+	// The target version differs from the deployed one
+	UpdateInProgress bool        `json:"updateInProgress"`
+	Ready            bool        `json:"ready"`
+	Conditions       []Condition `json:"conditions,omitempty"`
+}
+
+// openStackVersionCR - the fields of the OpenStackVersion CR we read
+type openStackVersionCR struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		TargetVersion string `json:"targetVersion"`
+	} `json:"spec"`
+	Status struct {
+		DeployedVersion  string      `json:"deployedVersion"`
+		AvailableVersion string      `json:"availableVersion"`
+		Conditions       []Condition `json:"conditions"`
+		// Images of every version the operator can deploy, by version
+		ContainerImageVersionDefaults map[string]json.RawMessage `json:"containerImageVersionDefaults"`
+	} `json:"status"`
+}
+
+// GetOpenStackVersion reads the OpenStackVersion CR called name, or the only
+// one of the namespace when name is empty
+func GetOpenStackVersion(ctx context.Context, ns string, name string) (*OpenStackVersion, error) {
+	return readOpenStackVersion(ctx, OCRunner{}, ns, name)
+}
+
+func readOpenStackVersion(ctx context.Context, r Runner, ns string, name string) (*OpenStackVersion, error) {
+	args := []string{"get", "openstackversion"}
+	if name != "" {
+		args = append(args, name)
+	}
+	res, err := r.Run(ctx, ns, nil, append(args, "-o", "json")...)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve the OpenStackVersion: %v: %s", err, strings.TrimSpace(res.Stderr))
+	}
+	return ParseOpenStackVersion([]byte(res.Stdout))
+}
+
+// ParseOpenStackVersion decodes the JSON of an OpenStackVersion CR, or of a
+// list holding a single one
+func ParseOpenStackVersion(data []byte) (*OpenStackVersion, error) {
+	var list struct {
+		Kind  string               `json:"kind"`
+		Items []openStackVersionCR `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid OpenStackVersion: %w", err)
+	}
+
+	var cr openStackVersionCR
+	if list.Kind == "List" || list.Items != nil {
+		switch len(list.Items) {
+		case 0:
+			return nil, fmt.Errorf("no OpenStackVersion found")
+		case 1:
+			cr = list.Items[0]
+		default:
+			return nil, fmt.Errorf("%d OpenStackVersion found, a name is required", len(list.Items))
+		}
+	} else if err := json.Unmarshal(data, &cr); err != nil {
+		return nil, fmt.Errorf("invalid OpenStackVersion: %w", err)
+	}
+
+	v := &OpenStackVersion{
+		Name:             cr.Metadata.Name,
+		Namespace:        cr.Metadata.Namespace,
+		TargetVersion:    cr.Spec.TargetVersion,
+		DeployedVersion:  cr.Status.DeployedVersion,
+		AvailableVersion: cr.Status.AvailableVersion,
+		Conditions:       cr.Status.Conditions,
+	}
+	v.Ready = findCondition(v.Conditions, "Ready").IsTrue()
+	v.UpdateInProgress = v.TargetVersion != "" && v.DeployedVersion != "" && v.TargetVersion != v.DeployedVersion
+
+	versions := []string{v.AvailableVersion}
+	for version := range cr.Status.ContainerImageVersionDefaults {
+		versions = append(versions, version)
+	}
+	v.UpdatePaths = newerVersions(v.DeployedVersion, versions)
+	for _, version := range v.UpdatePaths {
+		if version == v.AvailableVersion {
+			v.UpdateAvailable = true
+		}
+	}
+	return v, nil
+}
+
+// CanUpdateTo returns an error when the deployment cannot be updated to
+// version: a downgrade, the deployed version or one the operator does not
+// provide
+func (v *OpenStackVersion) CanUpdateTo(version string) error {
+	for _, path := range v.UpdatePaths {
+		if path == version {
+			return nil
+		}
+	}
+	if c, err := CompareVersions(version, v.DeployedVersion); err == nil && c <= 0 {
+		return fmt.Errorf("version %s is not newer than the deployed version %s", version, v.DeployedVersion)
+	}
+	if len(v.UpdatePaths) == 0 {
+		return fmt.Errorf("no update available from version %s", v.DeployedVersion)
+	}
+	return fmt.Errorf("version %s is not available, the deployment can be updated to: %v", version, v.UpdatePaths)
+}
+
+// newerVersions returns the distinct versions newer than deployed, oldest
+// first. Versions that cannot be parsed are ignored
+func newerVersions(deployed string, versions []string) []string {
+	current, err := ParseVersion(deployed)
+	if err != nil {
+		return nil
+	}
+
+	var newer []string
+	parsed := map[string]Version{}
+	for _, version := range versions {
+		if _, seen := parsed[version]; seen || version == "" {
+			continue
+		}
+		v, err := ParseVersion(version)
+		if err != nil || v.Compare(current) <= 0 {
+			continue
+		}
+		parsed[version] = v
+		newer = append(newer, version)
+	}
+	sort.Slice(newer, func(i, j int) bool {
+		return parsed[newer[i]].Compare(parsed[newer[j]]) < 0
+	})
+	return newer
+}
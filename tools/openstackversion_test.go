@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const openStackVersionCRJSON = `{
+	"apiVersion": "core.openstack.org/v1beta1",
+	"kind": "OpenStackVersion",
+	"metadata": {"name": "controlplane", "namespace": "openstack"},
+	"spec": {"targetVersion": "18.0.3-20240925.2"},
+	"status": {
+		"deployedVersion": "18.0.3-20240925.2",
+		"availableVersion": "18.0.4-20241008.1",
+		"conditions": [{"type": "Ready", "status": "True"}],
+		"containerImageVersionDefaults": {
+			"18.0.2-20240801.1": {},
+			"18.0.3-20240925.2": {},
+			"18.0.3-20240925.10": {},
+			"18.0.4-20241008.1": {},
+			"latest": {}
+		}
+	}
+}`
+
+func TestParseOpenStackVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *OpenStackVersion
+		wantErr string
+	}{
+		{
+			name: "single CR",
+			data: openStackVersionCRJSON,
+			want: &OpenStackVersion{
+				Name:             "controlplane",
+				Namespace:        "openstack",
+				TargetVersion:    "18.0.3-20240925.2",
+				DeployedVersion:  "18.0.3-20240925.2",
+				AvailableVersion: "18.0.4-20241008.1",
+				// Sorted by precedence, without the deployed, older or
+				// unparsable versions, and without duplicates
+				UpdatePaths:     []string{"18.0.3-20240925.10", "18.0.4-20241008.1"},
+				UpdateAvailable: true,
+				Ready:           true,
+				Conditions:      []Condition{{Type: "Ready", Status: "True"}},
+			},
+		},
+		{
+			name: "list with one item",
+			data: `{"kind": "List", "items": [` + openStackVersionCRJSON + `]}`,
+			want: &OpenStackVersion{
+				Name:             "controlplane",
+				Namespace:        "openstack",
+				TargetVersion:    "18.0.3-20240925.2",
+				DeployedVersion:  "18.0.3-20240925.2",
+				AvailableVersion: "18.0.4-20241008.1",
+				UpdatePaths:      []string{"18.0.3-20240925.10", "18.0.4-20241008.1"},
+				UpdateAvailable:  true,
+				Ready:            true,
+				Conditions:       []Condition{{Type: "Ready", Status: "True"}},
+			},
+		},
+		{
+			name: "update in progress",
+			data: `{
+				"metadata": {"name": "controlplane"},
+				"spec": {"targetVersion": "18.0.4-20241008.1"},
+				"status": {
+					"deployedVersion": "18.0.3-20240925.2",
+					"availableVersion": "18.0.4-20241008.1",
+					"conditions": [{"type": "Ready", "status": "False", "reason": "MinorUpdateOVNControlplane"}]
+				}
+			}`,
+			want: &OpenStackVersion{
+				Name:             "controlplane",
+				TargetVersion:    "18.0.4-20241008.1",
+				DeployedVersion:  "18.0.3-20240925.2",
+				AvailableVersion: "18.0.4-20241008.1",
+				UpdatePaths:      []string{"18.0.4-20241008.1"},
+				UpdateAvailable:  true,
+				UpdateInProgress: true,
+				Conditions:       []Condition{{Type: "Ready", Status: "False", Reason: "MinorUpdateOVNControlplane"}},
+			},
+		},
+		{
+			name: "up to date",
+			data: `{
+				"metadata": {"name": "controlplane"},
+				"spec": {"targetVersion": "18.0.4"},
+				"status": {"deployedVersion": "18.0.4", "availableVersion": "18.0.4-20241008.1"}
+			}`,
+			want: &OpenStackVersion{
+				Name:             "controlplane",
+				TargetVersion:    "18.0.4",
+				DeployedVersion:  "18.0.4",
+				AvailableVersion: "18.0.4-20241008.1",
+			},
+		},
+		{
+			name: "not deployed yet",
+			data: `{
+				"metadata": {"name": "controlplane"},
+				"spec": {"targetVersion": "18.0.4-20241008.1"},
+				"status": {"availableVersion": "18.0.4-20241008.1"}
+			}`,
+			want: &OpenStackVersion{
+				Name:             "controlplane",
+				TargetVersion:    "18.0.4-20241008.1",
+				AvailableVersion: "18.0.4-20241008.1",
+			},
+		},
+		{
+			name:    "empty list",
+			data:    `{"kind": "List", "items": []}`,
+			wantErr: "no OpenStackVersion found",
+		},
+		{
+			name:    "several items",
+			data:    `{"kind": "List", "items": [` + openStackVersionCRJSON + `,` + openStackVersionCRJSON + `]}`,
+			wantErr: "2 OpenStackVersion found, a name is required",
+		},
+		{
+			name:    "invalid JSON",
+			data:    `error: the server doesn't have a resource type "openstackversion"`,
+			wantErr: "invalid OpenStackVersion",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOpenStackVersion([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseOpenStackVersion() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseOpenStackVersion() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOpenStackVersion() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanUpdateTo(t *testing.T) {
+	v := &OpenStackVersion{
+		DeployedVersion: "18.0.3-20240925.2",
+		UpdatePaths:     []string{"18.0.3-20240925.10", "18.0.4-20241008.1"},
+	}
+	tests := []struct {
+		version string
+		wantErr string
+	}{
+		{"18.0.4-20241008.1", ""},
+		{"18.0.3-20240925.10", ""},
+		{"18.0.3-20240925.2", "not newer than the deployed version"},
+		{"18.0.2-20240801.1", "not newer than the deployed version"},
+		{"18.0.5-20241101.1", "is not available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			err := v.CanUpdateTo(tt.version)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CanUpdateTo(%s) error = %v", tt.version, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CanUpdateTo(%s) error = %v, want %q", tt.version, err, tt.wantErr)
+			}
+		})
+	}
+
+	upToDate := &OpenStackVersion{DeployedVersion: "18.0.4"}
+	if err := upToDate.CanUpdateTo("18.0.5"); err == nil || !strings.Contains(err.Error(), "no update available") {
+		t.Errorf("CanUpdateTo() error = %v, want no update available", err)
+	}
+}
@@ -0,0 +1,252 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/fmount/ocstack/mcp"
+	"github.com/fmount/ocstack/mcp/server"
+)
+
+const (
+	// MCP_SERVER_NAME - name advertised by `ocstack mcp-serve`
+	MCP_SERVER_NAME    = "ocstack-mcp-server"
+	MCP_SERVER_VERSION = "1.0.0"
+)
+
+// openstackTool - an MCP tool backed by one of the OpenStack helpers
+type openstackTool struct {
+	definition mcp.MCPTool
+	run        func(ctx context.Context, f *FunctionCall, ns string) string
+}
+
+func hint(b bool) *bool {
+	return &b
+}
+
+// readOnly - annotations of tools that only query the cluster
+func readOnly(title string) *mcp.ToolAnnotations {
+	return &mcp.ToolAnnotations{
+		Title:          title,
+		ReadOnlyHint:   hint(true),
+		IdempotentHint: hint(true),
+		OpenWorldHint:  hint(false),
+	}
+}
+
+var namespaceProperty = map[string]interface{}{
+	"type":        "string",
+	"description": "The OpenStack namespace, the server default is used when omitted",
+}
+
+func openstackTools() []openstackTool {
+	return []openstackTool{
+		{
+			definition: mcp.MCPTool{
+				Name:        "hello",
+				Description: "Say hello to a given person with their name",
+				InputSchema: mcp.ToolSchema{
+					Type: "object",
+					Properties: map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "The name of the person",
+						},
+					},
+					Required: []string{"name"},
+				},
+				Annotations: readOnly("Hello"),
+			},
+			run: func(ctx context.Context, f *FunctionCall, ns string) string {
+				return Hello(f.Arguments)
+			},
+		},
+		{
+			definition: mcp.MCPTool{
+				Name:        "oc",
+				Description: "Runs the OpenShift client (oc) to interact with an OpenShift environment. " +
+					"The command runs in the namespace, unless it sets -n or -A",
+				InputSchema: mcp.ToolSchema{
+					Type: "object",
+					Properties: map[string]interface{}{
+						"command": map[string]interface{}{
+							"type":        "string",
+							"description": "The oc command to execute, e.g. 'get pods -n openstack'",
+						},
+						"namespace": namespaceProperty,
+					},
+					Required: []string{"command"},
+				},
+				// Any command can be run, including deletions
+				Annotations: &mcp.ToolAnnotations{
+					Title:           "OpenShift client",
+					ReadOnlyHint:    hint(false),
+					DestructiveHint: hint(true),
+					IdempotentHint:  hint(false),
+					OpenWorldHint:   hint(false),
+				},
+			},
+			run: OC,
+		},
+		{
+			definition: mcp.MCPTool{
+				Name:        "get_openstack_control_plane",
+				Description: "Get OpenStack control plane information",
+				InputSchema: mcp.ToolSchema{
+					Type: "object",
+					Properties: map[string]interface{}{
+						"namespace": namespaceProperty,
+					},
+				},
+				Annotations: readOnly("OpenStack control plane"),
+			},
+			run: Ctlplane,
+		},
+		{
+			definition: mcp.MCPTool{
+				Name:        "check_openstack_svc",
+				Description: "Check the status of an OpenStack service",
+				InputSchema: mcp.ToolSchema{
+					Type: "object",
+					Properties: map[string]interface{}{
+						"service": map[string]interface{}{
+							"type":        "string",
+							"description": "The service name to check",
+						},
+						"namespace": namespaceProperty,
+					},
+					Required: []string{"service"},
+				},
+				Annotations: readOnly("Check OpenStack service"),
+			},
+			run: CheckSvc,
+		},
+		{
+			definition: mcp.MCPTool{
+				Name:        "get_deployed_version",
+				Description: "Get the currently deployed OpenStack version",
+				InputSchema: mcp.ToolSchema{
+					Type: "object",
+					Properties: map[string]interface{}{
+						"namespace": namespaceProperty,
+					},
+				},
+				Annotations: readOnly("Deployed OpenStack version"),
+			},
+			run: GetDeployedVersion,
+		},
+		{
+			definition: mcp.MCPTool{
+				Name:        "get_available_version",
+				Description: "Get the available OpenStack version for update",
+				InputSchema: mcp.ToolSchema{
+					Type: "object",
+					Properties: map[string]interface{}{
+						"namespace": namespaceProperty,
+					},
+				},
+				Annotations: readOnly("Available OpenStack version"),
+			},
+			run: GetAvailableVersion,
+		},
+		{
+			definition: mcp.MCPTool{
+				Name:        "trigger_minor_update",
+				Description: "Patches the OpenStackVersion CR to trigger the OpenStack control plane minor update",
+				InputSchema: mcp.ToolSchema{
+					Type: "object",
+					Properties: map[string]interface{}{
+						"namespace": namespaceProperty,
+						"targetVersion": map[string]interface{}{
+							"type":        "string",
+							"description": "The targetVersion that we need to update to",
+						},
+						"openstackVersion": map[string]interface{}{
+							"type":        "string",
+							"description": "The name of the OpenStackVersion CR to patch",
+						},
+					},
+					Required: []string{"targetVersion", "openstackVersion"},
+				},
+				// Starts the update of the whole control plane
+				Annotations: &mcp.ToolAnnotations{
+					Title:           "Trigger minor update",
+					ReadOnlyHint:    hint(false),
+					DestructiveHint: hint(true),
+					IdempotentHint:  hint(true),
+					OpenWorldHint:   hint(false),
+				},
+			},
+			run: func(ctx context.Context, f *FunctionCall, ns string) string {
+				return TriggerUpdate(ctx, f, ns,
+					unpackArgs("openstackVersion", f.Arguments),
+					unpackArgs("targetVersion", f.Arguments))
+			},
+		},
+	}
+}
+
+// NewOpenStackServer - returns an MCP server exposing the OpenStack tools.
+// Tools run against ns unless the call provides a namespace
+func NewOpenStackServer(ns string) *server.Server {
+	s := server.New(MCP_SERVER_NAME, MCP_SERVER_VERSION)
+	for _, tool := range openstackTools() {
+		run := tool.run
+		name := tool.definition.Name
+		s.AddTool(tool.definition, func(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResponse, error) {
+			namespace := ns
+			if n := unpackArgs("namespace", args); n != "" {
+				namespace = n
+			}
+			f := &FunctionCall{
+				Name:      name,
+				Arguments: args,
+			}
+			f.Result = run(ctx, f, namespace)
+			return server.TextResult(f.Result), nil
+		})
+	}
+
+	// The version status is returned as structured content, which the LLM
+	// and the clients can rely on
+	err := server.AddStructuredTool(s, mcp.MCPTool{
+		Name: "needs_minor_update",
+		Description: "Check if OpenStack needs a minor update: returns the deployed, available " +
+			"and target versions, the versions the deployment can be updated to and the " +
+			"conditions of the OpenStackVersion CR",
+		Annotations: readOnly("Check for minor updates"),
+	}, func(ctx context.Context, args namespaceArgs) (*OpenStackVersion, error) {
+		namespace := ns
+		if args.Namespace != "" {
+			namespace = args.Namespace
+		}
+		return GetOpenStackVersion(ctx, namespace, "")
+	})
+	if err != nil {
+		// The types are static, this is a programming error
+		panic(err)
+	}
+
+	err = server.AddStructuredTool(s, mcp.MCPTool{
+		Name: "get_openstack_health",
+		Description: "Get the health of OpenStack: walks the OpenStackControlPlane and the CRs " +
+			"of its services, and returns their Ready status along with the conditions that " +
+			"are not met (reason, message, severity, lastTransitionTime), the most relevant " +
+			"failure first",
+		Annotations: readOnly("OpenStack health report"),
+	}, func(ctx context.Context, args namespaceArgs) (*HealthReport, error) {
+		namespace := ns
+		if args.Namespace != "" {
+			namespace = args.Namespace
+		}
+		return GetHealthReport(ctx, namespace)
+	})
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// namespaceArgs - arguments of the tools that only take a namespace
+type namespaceArgs struct {
+	Namespace string `json:"namespace,omitempty" description:"The OpenStack namespace, the server default is used when omitted"`
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fmount/ocstack/mcp"
+)
+
+// connectOpenStackServer connects an MCP client to the OpenStack server
+// through the in-process transport
+func connectOpenStackServer(t *testing.T) *mcp.MCPClient {
+	t.Helper()
+	// The client lives as long as the context given to Connect
+	client := mcp.NewClient(mcp.MCPConfig{InProcess: NewOpenStackServer("openstack").Serve})
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect() })
+	return client
+}
+
+func TestOpenStackServerTools(t *testing.T) {
+	client := connectOpenStackServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools() error = %v", err)
+	}
+	byName := map[string]mcp.MCPTool{}
+	for _, tool := range tools {
+		byName[tool.Name] = tool
+	}
+
+	tests := []struct {
+		name     string
+		readOnly bool
+	}{
+		{"hello", true},
+		{"oc", false},
+		{"get_openstack_control_plane", true},
+		{"check_openstack_svc", true},
+		{"get_deployed_version", true},
+		{"get_available_version", true},
+		{"trigger_minor_update", false},
+		{"needs_minor_update", true},
+		{"get_openstack_health", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool, ok := byName[tt.name]
+			if !ok {
+				t.Fatalf("tool %s not listed", tt.name)
+			}
+			if tool.Annotations == nil || tool.Annotations.ReadOnlyHint == nil {
+				t.Fatalf("tool %s has no readOnlyHint", tt.name)
+			}
+			if got := *tool.Annotations.ReadOnlyHint; got != tt.readOnly {
+				t.Errorf("readOnlyHint = %v, want %v", got, tt.readOnly)
+			}
+		})
+	}
+	if len(tools) != len(tests) {
+		t.Errorf("ListTools() returned %d tools, want %d", len(tools), len(tests))
+	}
+}
+
+func TestOpenStackServerCallTool(t *testing.T) {
+	client := connectOpenStackServer(t)
+
+	tests := []struct {
+		name string
+		tool string
+		args map[string]interface{}
+		want string
+	}{
+		{
+			name: "hello",
+			tool: "hello",
+			args: map[string]interface{}{"name": "ocstack"},
+			want: "Hello ocstack",
+		},
+		{
+			name: "flag as service name",
+			tool: "check_openstack_svc",
+			args: map[string]interface{}{"service": "--kubeconfig=/tmp/config"},
+			want: "Error: invalid service name",
+		},
+		{
+			name: "several resource types",
+			tool: "check_openstack_svc",
+			args: map[string]interface{}{"service": "pods secrets"},
+			want: "Error: invalid service name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			resp, err := client.CallTool(ctx, tt.tool, tt.args)
+			if err != nil {
+				t.Fatalf("CallTool() error = %v", err)
+			}
+			if len(resp.Content) != 1 {
+				t.Fatalf("CallTool() returned %d contents, want 1", len(resp.Content))
+			}
+			if got := resp.Content[0].Text; !strings.HasPrefix(got, tt.want) {
+				t.Errorf("CallTool() = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	tests := []struct {
+		service string
+		valid   bool
+	}{
+		{"keystoneapi", true},
+		{"keystoneapi/keystone", true},
+		{"deployments.apps", true},
+		{"ovndbcluster/ovndbcluster-nb", true},
+		{"", false},
+		{"-o", false},
+		{"--all-namespaces", false},
+		{"pods secrets", false},
+		{"pods,secrets", false},
+		{"keystoneapi/", false},
+		{"Pods", false},
+		{"pods/a/b", false},
+	}
+	for _, tt := range tests {
+		if got := serviceName.MatchString(tt.service); got != tt.valid {
+			t.Errorf("serviceName.MatchString(%q) = %v, want %v", tt.service, got, tt.valid)
+		}
+	}
+}
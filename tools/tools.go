@@ -4,9 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os/exec"
-	"strings"
 	"text/template"
+	"time"
 )
 
 // LOCAL_TOOLS constant removed - no longer using local tools
@@ -20,6 +19,22 @@ type FunctionCall struct {
 	Name      string         `json:"name"`
 	Arguments map[string]any `json:"arguments"`
 	Result    string         `json:"result"`
+	// Binary output (e.g. images) returned by the tool
+	Media []Media `json:"-"`
+}
+
+// Media - binary tool output that can be passed to multimodal providers
+type Media struct {
+	MimeType string
+	Data     []byte
+}
+
+// AddMedia - attaches binary output to the function call
+func (f *FunctionCall) AddMedia(mimeType string, data []byte) {
+	f.Media = append(f.Media, Media{
+		MimeType: mimeType,
+		Data:     data,
+	})
 }
 
 type Properties struct {
@@ -122,45 +137,26 @@ func RegisterToolsWithMCP(mcpRegistry interface{}) ([]byte, error) {
 	return json.Marshal([]map[string]any{})
 }
 
-// ToolResult -
+// ToolResult - outcome of a command run by Exec
 type ToolResult struct {
-	Stdout   string `json:"stdout,omitempty"`
-	Stderr   string `json:"stderr,omitempty"`
-	ExitCode int    `json:"exitcode,omitempty"`
+	Stdout   string        `json:"stdout,omitempty"`
+	Stderr   string        `json:"stderr,omitempty"`
+	ExitCode int           `json:"exitcode,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	// The command was killed when its timeout expired
+	TimedOut bool `json:"timedout,omitempty"`
+	// Part of the output was dropped, see ExecOptions.MaxOutput
+	Truncated bool `json:"truncated,omitempty"`
 }
 
-// ToString -
+// ToString - the output of the command, with its exit code when it failed
 func (t *ToolResult) ToString() string {
-	return fmt.Sprintf("out: %s\nerr: %s\n", t.Stdout, t.Stderr)
-}
-
-// ExecTool executes a command with arguments and returns the result
-func ExecTool(c string, args string) (ToolResult, error) {
-	// Split the args string into separate arguments
-	argSlice := strings.Fields(args)
-
-	cmd := exec.Command(c, argSlice...)
-
-	// Define stdout and stderr buffers to collect the execution result
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	t := ToolResult{}
-	err := cmd.Run()
-	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			t.ExitCode = exitError.ExitCode()
-		}
-		t.Stdout = stdout.String()
-		t.Stderr = stderr.String()
-		return t, fmt.Errorf("command failed with error: %s", err)
+	out := fmt.Sprintf("out: %s\nerr: %s\n", t.Stdout, t.Stderr)
+	switch {
+	case t.TimedOut:
+		out += fmt.Sprintf("timed out after %s\n", t.Duration.Round(time.Millisecond))
+	case t.ExitCode != 0:
+		out += fmt.Sprintf("exit code: %d\n", t.ExitCode)
 	}
-
-	t.Stdout = stdout.String()
-	t.Stderr = stderr.String()
-	fmt.Println(stdout.String())
-	t.ExitCode = 0
-	return t, nil
+	return out
 }
@@ -1,18 +1,36 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/fmount/ocstack/pkg/ocstack"
 	"os"
+	"path/filepath"
+	"regexp"
 )
 
 // LoadDefaultConfig -
 func LoadDefaultConfig() map[string]string {
 	c := make(map[string]string)
 	c[ocstack.NAMESPACE] = ocstack.DEFAULT_NAMESPACE
+	c[ocstack.WORKSPACE] = GetWorkspace()
 	return c
 }
 
+// GetWorkspace - returns the path where the assets used as input live. It can
+// be set via the OCSTACK_WORKSPACE env var and defaults to the current dir
+func GetWorkspace() string {
+	path := os.Getenv(ocstack.WORKSPACE_ENV)
+	if path == "" {
+		path, _ = os.Getwd()
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
 // GetKubeConfig -
 func GetKubeConfig() (string, error) {
 	path := os.Getenv("KUBECONFIG")
@@ -57,49 +75,79 @@ func unpackArgs(key string, args map[string]any) string {
 	return ""
 }
 
-// OC - Run openshift client tool
-func OC(f *FunctionCall) string {
-	args := unpackArgs("command", f.Arguments)
-	res, _ := ExecTool(f.Name, args)
+// OC - Run openshift client tool. The command is split like a shell would,
+// so quoted arguments (e.g. JSON patches) are passed as a single argument
+func OC(ctx context.Context, f *FunctionCall, ns string) string {
+	args, err := SplitArgs(unpackArgs("command", f.Arguments))
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	// -n or -A in the command take precedence over ns
+	res, _ := runOC(ctx, ns, args...)
 	return res.ToString()
 }
 
+// runOC - runs oc against the given namespace. Arguments are passed as they
+// are, without any shell splitting
+func runOC(ctx context.Context, ns string, args ...string) (ToolResult, error) {
+	return OCRunner{}.Run(ctx, ns, nil, args...)
+}
+
 // Ctlplane -
-func Ctlplane(f *FunctionCall, ns string) string {
-	//args := unpackArgs("command", f.Arguments)
-	res, _ := ExecTool("oc", fmt.Sprintf("-n %s get oscp", ns))
+func Ctlplane(ctx context.Context, f *FunctionCall, ns string) string {
+	res, _ := runOC(ctx, ns, "get", "oscp")
 	return res.ToString()
 }
 
+// serviceName matches a resource type, optionally followed by the name of a
+// resource, e.g. keystoneapi or keystoneapi/keystone
+var serviceName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?(/[a-z0-9]([-a-z0-9.]*[a-z0-9])?)?$`)
+
 // Check service -
-func CheckSvc(f *FunctionCall, ns string) string {
+func CheckSvc(ctx context.Context, f *FunctionCall, ns string) string {
 	svc := unpackArgs("service", f.Arguments)
-	res, _ := ExecTool("oc", fmt.Sprintf("-n %s get %s", ns, svc))
+	if !serviceName.MatchString(svc) {
+		return fmt.Sprintf("Error: invalid service name %q", svc)
+	}
+	res, _ := runOC(ctx, ns, "get", svc)
 	return res.ToString()
 }
 
-func GetDeployedVersion(f *FunctionCall, ns string) string {
-	options := "-o custom-columns=VERSION:.status.deployedVersion --no-headers"
-	res, _ := ExecTool("oc", fmt.Sprintf("-n %s get openstackversion %s", ns, options))
-	return res.ToString()
+// GetDeployedVersion - the OpenStack version running in ns
+func GetDeployedVersion(ctx context.Context, f *FunctionCall, ns string) string {
+	v, err := GetOpenStackVersion(ctx, ns, "")
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return v.DeployedVersion
 }
 
-func GetAvailableVersion(f *FunctionCall, ns string) string {
-	options := "-o custom-columns=.VERSION:.status.availableVersion --no-headers"
-	res, _ := ExecTool("oc", fmt.Sprintf("-n %s get openstackversion %s", ns, options))
-	return res.ToString()
+// GetAvailableVersion - the latest OpenStack version provided by the
+// operator in ns
+func GetAvailableVersion(ctx context.Context, f *FunctionCall, ns string) string {
+	v, err := GetOpenStackVersion(ctx, ns, "")
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return v.AvailableVersion
 }
 
-func MinorUpdate(f *FunctionCall, ns string) string {
-	av := GetAvailableVersion(f, ns)
-	dv := GetDeployedVersion(f, ns)
-	if av == dv {
-		return "OpenStack is up to date"
+// TriggerUpdate - sets the targetVersion of the OpenStackVersion CR called
+// name, once checked that the deployment can be updated to it
+func TriggerUpdate(ctx context.Context, f *FunctionCall, ns string, name string, targetVersion string) string {
+	v, err := GetOpenStackVersion(ctx, ns, name)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if err := v.CanUpdateTo(targetVersion); err != nil {
+		return fmt.Sprintf("Error: refusing to update %s: %v", name, err)
 	}
-	return "OpenStack control update available!"
-}
 
-func TriggerUpdate(f *FunctionCall, ns string, name string, targetVersion string) string {
-	res, _ := ExecTool("oc", fmt.Sprintf("-n %s patch openstackversion %s --type=json -p=\"[{'op': 'replace', 'path': '/spec/targetVersion', '%s'}]\"", ns, name, targetVersion))
-	return res.ToString()
+	patch, _ := json.Marshal(map[string]any{
+		"spec": map[string]any{
+			"targetVersion": targetVersion,
+		},
+	})
+	res, _ := runOC(ctx, ns, "patch", "openstackversion", name, "--type=merge", "-p", string(patch))
+	return fmt.Sprintf("Updating %s from %s to %s\n%s", name, v.DeployedVersion, targetVersion, res.ToString())
 }
@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version - a semantic version, e.g. 0.5.1-1764607270. The operator appends
+// the build timestamp as pre-release, which compares numerically
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease []string
+	Build      string
+}
+
+// ParseVersion parses a semantic version. A leading "v" is accepted, and the
+// minor and patch numbers default to zero when omitted
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	rest, v.Build, _ = strings.Cut(rest, "+")
+	core, pre, hasPre := strings.Cut(rest, "-")
+	if hasPre {
+		if pre == "" {
+			return Version{}, fmt.Errorf("invalid version %q: empty pre-release", s)
+		}
+		v.PreRelease = strings.Split(pre, ".")
+	}
+
+	numbers := strings.Split(core, ".")
+	if len(numbers) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q: too many components", s)
+	}
+	fields := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, number := range numbers {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		*fields[i] = n
+	}
+	return v, nil
+}
+
+// String returns the version in its canonical form
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.PreRelease) > 0 {
+		s += "-" + strings.Join(v.PreRelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 when v is older than, equal to or newer than o,
+// following the semver precedence: build metadata is ignored, and a
+// pre-release is older than the release
+func (v Version) Compare(o Version) int {
+	for _, c := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c[0] != c[1] {
+			return compareInts(c[0], c[1])
+		}
+	}
+
+	switch {
+	case len(v.PreRelease) == 0 && len(o.PreRelease) == 0:
+		return 0
+	case len(v.PreRelease) == 0:
+		return 1
+	case len(o.PreRelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.PreRelease) && i < len(o.PreRelease); i++ {
+		if c := compareIdentifiers(v.PreRelease[i], o.PreRelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(v.PreRelease), len(o.PreRelease))
+}
+
+// CompareVersions parses and compares two versions, see Version.Compare
+func CompareVersions(a string, b string) (int, error) {
+	va, err := ParseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := ParseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// compareIdentifiers compares pre-release identifiers: numbers numerically,
+// and lower than alphanumeric identifiers, which compare in ASCII order
+func compareIdentifiers(a string, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		if na == nb {
+			return 0
+		}
+		if na < nb {
+			return -1
+		}
+		return 1
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    Version
+		wantErr bool
+	}{
+		{version: "1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{version: "v1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{version: " 1.2 ", want: Version{Major: 1, Minor: 2}},
+		{version: "1", want: Version{Major: 1}},
+		{version: "0.5.1-1764607270", want: Version{Minor: 5, Patch: 1, PreRelease: []string{"1764607270"}}},
+		{version: "18.0.3-20240925.2", want: Version{Major: 18, Patch: 3, PreRelease: []string{"20240925", "2"}}},
+		{version: "1.0.0-rc.1+build.5", want: Version{Major: 1, PreRelease: []string{"rc", "1"}, Build: "build.5"}},
+		{version: "1.0.0+20240925", want: Version{Major: 1, Build: "20240925"}},
+		{version: "", wantErr: true},
+		{version: "1.2.3.4", wantErr: true},
+		{version: "1.x.3", wantErr: true},
+		{version: "1.-2.3", wantErr: true},
+		{version: "1.2.3-", wantErr: true},
+		{version: "latest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := ParseVersion(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVersion(%q) error = %v, wantErr %v", tt.version, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	tests := map[string]string{
+		"v1.2":                  "1.2.0",
+		"18.0.3-20240925.2":     "18.0.3-20240925.2",
+		"1.0.0-rc.1+build.5":    "1.0.0-rc.1+build.5",
+		"0.5.1-1764607270":      "0.5.1-1764607270",
+		"2.0.0+20240925.sha123": "2.0.0+20240925.sha123",
+	}
+	for version, want := range tests {
+		v, err := ParseVersion(version)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) error = %v", version, err)
+		}
+		if got := v.String(); got != want {
+			t.Errorf("ParseVersion(%q).String() = %q, want %q", version, got, want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{"equal", "1.2.3", "1.2.3", 0},
+		{"major", "2.0.0", "1.9.9", 1},
+		{"minor", "1.2.0", "1.10.0", -1},
+		{"patch", "1.2.10", "1.2.9", 1},
+		{"omitted components", "1.2", "1.2.0", 0},
+		{"leading v", "v1.2.3", "1.2.3", 0},
+		// Pre-releases
+		{"pre-release before release", "1.0.0-rc.1", "1.0.0", -1},
+		{"release after pre-release", "1.0.0", "1.0.0-alpha", 1},
+		{"numeric identifiers", "1.0.0-2", "1.0.0-10", -1},
+		{"alphanumeric identifiers", "1.0.0-alpha", "1.0.0-beta", -1},
+		{"numeric before alphanumeric", "1.0.0-1", "1.0.0-alpha", -1},
+		{"shorter pre-release first", "1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"semver precedence example", "1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"rc ordering", "1.0.0-rc.1", "1.0.0-beta.11", 1},
+		{"operator timestamps", "0.5.1-1764607270", "0.5.1-1764000000", 1},
+		// Build metadata is ignored
+		{"build metadata", "1.0.0+build.1", "1.0.0+build.2", 0},
+		{"build metadata and release", "1.0.0+20240925", "1.0.0", 0},
+		{"build metadata on pre-release", "1.0.0-rc.1+a", "1.0.0-rc.1+b", 0},
+		// OpenStack versions
+		{"same day builds", "18.0.3-20240925.2", "18.0.3-20240925.10", -1},
+		{"later day", "18.0.3-20241008.1", "18.0.3-20240925.10", 1},
+		{"next patch", "18.0.4-20241001.1", "18.0.3-20241008.1", 1},
+		{"openstack release", "18.0.3", "18.0.3-20241008.1", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompareVersions(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("CompareVersions(%q, %q) error = %v", tt.a, tt.b, err)
+			}
+			if got != tt.want {
+				t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			// The comparison is antisymmetric
+			if back, _ := CompareVersions(tt.b, tt.a); back != -tt.want {
+				t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, back, -tt.want)
+			}
+		})
+	}
+
+	if _, err := CompareVersions("1.0.0", "invalid"); err == nil {
+		t.Error("CompareVersions with an invalid version succeeded")
+	}
+}